@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ldeng7/go-fc/core"
+	"github.com/ldeng7/go-fc/core/disasm"
+)
+
+type conf struct {
+	romPath string
+	live    bool
+	nFrame  int
+	prgOfs  int
+	base    uint64
+	addr    uint64
+	end     uint64
+	nlPaths string
+	dbgPath string
+}
+
+func parseArgs() *conf {
+	c := &conf{}
+	flag.StringVar(&c.romPath, "rom", "", "rom path")
+	flag.BoolVar(&c.live, "live", false, "disassemble the cpu address space as mapped after a reset, instead of raw prg rom")
+	flag.IntVar(&c.nFrame, "frames", 0, "frames to run before disassembling, with -live")
+	flag.IntVar(&c.prgOfs, "prg", -1, "offset in the prg rom to disassemble from, -1=the last 16 KB")
+	flag.Uint64Var(&c.base, "base", 0xc000, "cpu address of the prg rom at -prg")
+	flag.Uint64Var(&c.addr, "addr", 0, "address to start from, 0=-base, or the reset vector with -live")
+	flag.Uint64Var(&c.end, "end", 0, "last address, 0=the end of the bank, or 256 bytes with -live")
+	flag.StringVar(&c.nlPaths, "nl", "", "comma separated fceux name list paths")
+	flag.StringVar(&c.dbgPath, "dbg", "", "ca65 debug file path")
+	flag.Parse()
+	if len(c.romPath) == 0 {
+		flag.PrintDefaults()
+		return nil
+	}
+	if c.base > 0xffff || c.addr > 0xffff || c.end > 0xffff {
+		println("invalid address")
+		return nil
+	}
+	return c
+}
+
+func loadSymbols(c *conf) (*disasm.Symbols, error) {
+	syms := disasm.NewSymbols()
+	for _, p := range strings.Split(c.nlPaths, ",") {
+		if len(p) == 0 {
+			continue
+		}
+		// game.nes.ram.nl, or game.nes.<bank>.nl
+		bank := -1
+		if s := strings.TrimSuffix(path.Base(p), ".nl"); !strings.HasSuffix(s, ".ram") {
+			var err error
+			if bank, err = strconv.Atoi(strings.TrimPrefix(path.Ext(s), ".")); err != nil {
+				return nil, fmt.Errorf("no bank in the name list name: %s", p)
+			}
+		}
+		if err := loadFile(p, func(f *os.File) error { return syms.LoadNl(f, bank) }); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.dbgPath) != 0 {
+		if err := loadFile(c.dbgPath, func(f *os.File) error { return syms.LoadDbg(f) }); err != nil {
+			return nil, err
+		}
+	}
+	return syms, nil
+}
+
+func loadFile(p string, load func(f *os.File) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return load(f)
+}
+
+// readPrg returns the prg rom of an ines image.
+func readPrg(p string) ([]byte, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 16 || string(b[:4]) != "NES\x1a" {
+		return nil, errors.New("not an ines image")
+	}
+	size := int(b[4])
+	if b[7]&0x0c == 0x08 {
+		size |= int(b[9]&0x0f) << 8
+	}
+	size <<= 14
+	trainer := b[6]&0x04 != 0
+	if b = b[16:]; trainer && len(b) >= 512 {
+		b = b[512:]
+	}
+	if size > len(b) {
+		return nil, errors.New("truncated prg rom")
+	}
+	return b[:size], nil
+}
+
+func newBus(c *conf) (disasm.Bus, error) {
+	if c.live {
+		f, err := os.Open(c.romPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sys, err := core.NewSys(f, &core.Conf{
+			RenderMode: core.RenderModeAuto, TvFormat: core.TvFormatAuto, AudioSampRate: 44100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sys.SetFrameBuffer(&core.FrameBuffer{})
+		for i := 0; i < c.nFrame; i++ {
+			sys.RunFrame()
+		}
+		if c.addr == 0 {
+			c.addr = uint64(sys.PeekCpu(0xfffc)) | uint64(sys.PeekCpu(0xfffd))<<8
+		}
+		if c.end == 0 {
+			if c.end = c.addr + 0xff; c.end > 0xffff {
+				c.end = 0xffff
+			}
+		}
+		return sys, nil
+	}
+
+	prg, err := readPrg(c.romPath)
+	if err != nil {
+		return nil, err
+	}
+	if c.prgOfs < 0 {
+		c.prgOfs = len(prg) - 0x4000
+		if c.prgOfs < 0 {
+			c.prgOfs = 0
+		}
+	}
+	if c.prgOfs >= len(prg) {
+		return nil, errors.New("offset out of the prg rom")
+	}
+	n := len(prg) - c.prgOfs
+	if n > 0x10000-int(c.base) {
+		n = 0x10000 - int(c.base)
+	}
+	if c.addr == 0 {
+		c.addr = c.base
+	}
+	if c.end == 0 {
+		c.end = c.base + uint64(n) - 1
+	}
+	page := -1
+	if c.prgOfs&0x1fff == 0 {
+		page = c.prgOfs >> 13
+	}
+	return disasm.NewPrgBank(prg[c.prgOfs:c.prgOfs+n], uint16(c.base), page), nil
+}
+
+func run(c *conf) error {
+	syms, err := loadSymbols(c)
+	if err != nil {
+		return err
+	}
+	bus, err := newBus(c)
+	if err != nil {
+		return err
+	}
+	if c.end > 0xffff || c.end < c.addr {
+		return errors.New("invalid address range")
+	}
+	w := bufio.NewWriter(os.Stdout)
+	if err = disasm.List(w, bus, syms, uint16(c.addr), uint16(c.end)); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func main() {
+	c := parseArgs()
+	if c == nil {
+		os.Exit(1)
+	}
+	if err := run(c); err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ldeng7/go-fc/core"
+	"github.com/ldeng7/go-fc/headless"
+)
+
+type conf struct {
+	romPath   string
+	dbPath    string
+	biosPath  string
+	patchTyp  uint64
+	tvFormat  int
+	dot       bool
+	nFrame    int
+	inputPath string
+	moviePath string
+	pngDir    string
+	dump      string
+	wavPath   string
+	stereo    bool
+	tracePath string
+	traceAddr string
+	traceFrms string
+}
+
+func parseArgs() *conf {
+	c := &conf{}
+	flag.StringVar(&c.romPath, "rom", "", "rom path")
+	flag.StringVar(&c.dbPath, "db", "", "extra rom database path")
+	flag.StringVar(&c.biosPath, "bios", "", "disk system bios path, for .fds images")
+	flag.Uint64Var(&c.patchTyp, "patch", 0, "patch type, 0=from rom database")
+	flag.IntVar(&c.tvFormat, "tv", -1, "tv format: -1=auto, 0=ntsc, 1=pal, 2=pal-china")
+	flag.BoolVar(&c.dot, "dot", false, "dot-accurate ppu rendering, slower")
+	flag.IntVar(&c.nFrame, "frames", 600, "number of frames to run")
+	flag.StringVar(&c.inputPath, "input", "", "input script path")
+	flag.StringVar(&c.moviePath, "movie", "", "fm2 movie path, instead of -input")
+	flag.StringVar(&c.pngDir, "png", "", "directory to write the dumped frames to")
+	flag.StringVar(&c.dump, "dump", "", "comma separated frames to dump, counted from 0, the last one if empty")
+	flag.StringVar(&c.wavPath, "wav", "", "path to write the audio to")
+	flag.BoolVar(&c.stereo, "stereo", false, "stereo audio")
+	flag.StringVar(&c.tracePath, "trace", "", "path to write the instruction trace to, in the nestest log format")
+	flag.StringVar(&c.traceAddr, "trace-addr", "", "pc range to trace, as 8000-80ff, all if empty")
+	flag.StringVar(&c.traceFrms, "trace-frames", "", "frames to trace, as 10-20 for 10 up to 19, all if empty")
+	flag.Parse()
+	if len(c.romPath) == 0 {
+		flag.PrintDefaults()
+		return nil
+	}
+	if c.tvFormat < -1 || c.tvFormat > 2 {
+		println("invalid tv format")
+		return nil
+	}
+	if len(c.inputPath) != 0 && len(c.moviePath) != 0 {
+		println("-input and -movie are exclusive")
+		return nil
+	}
+	return c
+}
+
+func loadRomDb(p string) (*core.RomDb, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db := core.NewRomDb()
+	if err = db.Load(f); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func loadInput(p string, parse func(r io.Reader) (*headless.Input, error)) (*headless.Input, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+func writePng(p string, img image.Image) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseRange parses "a-b".
+func parseRange(s string, base int, bitSize int) (uint64, uint64, error) {
+	fs := strings.SplitN(s, "-", 2)
+	if len(fs) == 2 {
+		a, err := strconv.ParseUint(fs[0], base, bitSize)
+		b, err1 := strconv.ParseUint(fs[1], base, bitSize)
+		if err == nil && err1 == nil && a <= b {
+			return a, b, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid range: %s", s)
+}
+
+func newTraceConf(c *conf) (*core.TraceConf, error) {
+	tc := &core.TraceConf{}
+	if len(c.traceAddr) != 0 {
+		a, b, err := parseRange(c.traceAddr, 16, 16)
+		if err != nil {
+			return nil, err
+		}
+		tc.Addr, tc.End = uint16(a), uint16(b)
+	}
+	if len(c.traceFrms) != 0 {
+		a, b, err := parseRange(c.traceFrms, 10, 31)
+		if err != nil {
+			return nil, err
+		} else if a == b {
+			// the end is not traced, and 0 would be no end
+			return nil, fmt.Errorf("empty frame range: %s", c.traceFrms)
+		}
+		tc.FrameFrom, tc.FrameTo = int(a), int(b)
+	}
+	return tc, nil
+}
+
+func newConf(c *conf) (*headless.Conf, error) {
+	hc := &headless.Conf{NFrame: c.nFrame}
+	var err error
+	hc.Sys = core.Conf{
+		PatchTyp:   c.patchTyp,
+		RenderMode: core.RenderModeAuto,
+		TvFormat:   byte(c.tvFormat),
+		AllSprite:  true,
+	}
+	hc.Sys.AudioStereo = c.stereo
+	if c.dot {
+		hc.Sys.RenderMode = core.RenderModeDot
+	}
+	if len(c.biosPath) != 0 {
+		if hc.Sys.FdsBios, err = ioutil.ReadFile(c.biosPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.dbPath) != 0 {
+		if hc.Sys.RomDb, err = loadRomDb(c.dbPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.inputPath) != 0 {
+		hc.Input, err = loadInput(c.inputPath, headless.ParseScript)
+	} else if len(c.moviePath) != 0 {
+		hc.Input, err = loadInput(c.moviePath, headless.ParseFm2)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hc.TraceConf, err = newTraceConf(c); err != nil {
+		return nil, err
+	}
+
+	if len(c.pngDir) != 0 {
+		if len(c.dump) == 0 {
+			hc.DumpFrames = []int{c.nFrame - 1}
+		}
+		for _, s := range strings.Split(c.dump, ",") {
+			if s = strings.TrimSpace(s); len(s) == 0 {
+				continue
+			}
+			i, err := strconv.Atoi(s)
+			if err != nil || i < 0 || i >= c.nFrame {
+				return nil, fmt.Errorf("invalid frame to dump: %s", s)
+			}
+			hc.DumpFrames = append(hc.DumpFrames, i)
+		}
+		hc.OnFrame = func(i int, img *image.RGBA) error {
+			return writePng(path.Join(c.pngDir, fmt.Sprintf("%06d.png", i)), img)
+		}
+	}
+	return hc, nil
+}
+
+// closeFile closes a file written to, its error being that of the run if
+// there is none before.
+func closeFile(f *os.File, err *error) {
+	if err1 := f.Close(); *err == nil {
+		*err = err1
+	}
+}
+
+func run(c *conf) (err error) {
+	hc, err := newConf(c)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(c.romPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if len(c.wavPath) != 0 {
+		var w *os.File
+		if w, err = os.Create(c.wavPath); err != nil {
+			return err
+		}
+		defer closeFile(w, &err)
+		hc.Audio = w
+	}
+	if len(c.tracePath) != 0 {
+		var w *os.File
+		if w, err = os.Create(c.tracePath); err != nil {
+			return err
+		}
+		defer closeFile(w, &err)
+		hc.Trace = w
+	}
+	res, err := headless.Run(f, hc)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d frames in %v, %.0f fps\n", res.NFrame, res.Elapsed, float64(res.NFrame)/res.Elapsed.Seconds())
+	fmt.Printf("last frame crc32: %08x\n", res.FrameHash)
+	return nil
+}
+
+func main() {
+	c := parseArgs()
+	if c == nil {
+		os.Exit(1)
+	}
+	if err := run(c); err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+}
@@ -11,7 +11,7 @@ type Audio struct {
 	source   *core.ApuDataQueue
 }
 
-func newAudio() (*Audio, error) {
+func newAudio(stereo bool) (*Audio, error) {
 	a := &Audio{}
 	var err error
 	defer func() {
@@ -27,6 +27,9 @@ func newAudio() (*Audio, error) {
 	}
 	p := portaudio.HighLatencyParameters(nil, hostApi.DefaultOutputDevice)
 	p.Output.Channels = 1
+	if stereo {
+		p.Output.Channels = 2
+	}
 	a.sampRate = uint16(p.SampleRate)
 
 	a.stream, err = portaudio.OpenStream(p, func(buf []float32) {
@@ -32,6 +32,14 @@ func (a *App) onKey(_ *glfw.Window, key glfw.Key, _ int, action glfw.Action, _ g
 		if action == glfw.Press {
 			a.sys.Reset()
 		}
+	case glfw.KeyF1:
+		if n := a.sys.FdsGetSideNum(); n != 0 && action == glfw.Press {
+			a.sys.FdsInsertDisk((a.sys.FdsGetSide() + 1) % n)
+		}
+	case glfw.KeyF2:
+		if action == glfw.Press {
+			a.sys.FdsEjectDisk()
+		}
 	default:
 		switch action {
 		case glfw.Press:
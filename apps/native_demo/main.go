@@ -2,9 +2,11 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 
 	"github.com/go-gl/glfw/v3.1/glfw"
 	"github.com/ldeng7/go-fc/core"
@@ -12,29 +14,42 @@ import (
 
 type conf struct {
 	romPath  string
+	dbPath   string
+	biosPath string
 	patchTyp uint64
-	tvFormat uint
+	tvFormat int
+	stereo   bool
+	dot      bool
 }
 
 func parseArgs() *conf {
 	c := &conf{}
 	flag.StringVar(&c.romPath, "rom", "", "rom path")
-	flag.Uint64Var(&c.patchTyp, "patch", 0, "patch type")
-	flag.UintVar(&c.tvFormat, "tv", 0, "tv format: 0=ntsc, 1=pal, 2=pal-china")
+	flag.StringVar(&c.dbPath, "db", "", "extra rom database path")
+	flag.StringVar(&c.biosPath, "bios", "", "disk system bios path, for .fds images")
+	flag.Uint64Var(&c.patchTyp, "patch", 0, "patch type, 0=from rom database")
+	flag.IntVar(&c.tvFormat, "tv", -1, "tv format: -1=auto, 0=ntsc, 1=pal, 2=pal-china")
+	flag.BoolVar(&c.stereo, "stereo", false, "stereo output")
+	flag.BoolVar(&c.dot, "dot", false, "dot-accurate ppu rendering, slower")
 	flag.Parse()
 	if len(c.romPath) == 0 {
 		flag.PrintDefaults()
 		return nil
 	}
-	if c.tvFormat > 2 {
+	if c.tvFormat < -1 || c.tvFormat > 2 {
 		println("invalid tv format")
 		return nil
 	}
 	return c
 }
 
+const savePeriod = 10.0
+
 type App struct {
 	t, te   float64
+	ts      float64
+	savPath string
+	fdsPath string
 	sys     *core.Sys
 	audio   *Audio
 	graphic *Graphic
@@ -54,30 +69,77 @@ func newApp(c *conf) (*App, error) {
 		return nil, err
 	}
 
-	if a.audio, err = newAudio(); err != nil {
+	if a.audio, err = newAudio(c.stereo); err != nil {
 		return nil, err
 	}
 
-	f, err := os.Open(c.romPath)
+	romPath := c.romPath
+	if strings.EqualFold(path.Ext(romPath), ".fds") {
+		// the disk as modified by the game is kept apart from the original
+		a.fdsPath = strings.TrimSuffix(romPath, path.Ext(romPath)) + ".sav.fds"
+		if _, err = os.Stat(a.fdsPath); err == nil {
+			romPath = a.fdsPath
+		}
+	}
+	f, err := os.Open(romPath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 	ac := &core.Conf{
 		PatchTyp:      c.patchTyp,
+		RenderMode:    core.RenderModeAuto,
 		TvFormat:      byte(c.tvFormat),
 		AllSprite:     true,
 		AudioSampRate: a.audio.sampRate,
 	}
+	ac.AudioTargetFill = a.audio.sampRate / 8
+	ac.AudioStereo = c.stereo
+	if c.dot {
+		ac.RenderMode = core.RenderModeDot
+	}
+	if len(c.biosPath) != 0 {
+		if ac.FdsBios, err = ioutil.ReadFile(c.biosPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.dbPath) != 0 {
+		if ac.RomDb, err = loadRomDb(c.dbPath); err != nil {
+			return nil, err
+		}
+	}
 	if a.sys, err = core.NewSys(f, ac); err != nil {
 		return nil, err
 	}
 	a.audio.source = a.sys.GetAudioDataQueue()
+	if c.stereo {
+		a.sys.SetAudioChanPan(core.AudioChanPulse1, -0.3)
+		a.sys.SetAudioChanPan(core.AudioChanPulse2, 0.3)
+	}
+	if a.sys.HasBatteryRam() {
+		a.savPath = strings.TrimSuffix(c.romPath, path.Ext(c.romPath)) + ".sav"
+		if err = a.loadSav(); err != nil {
+			return nil, err
+		}
+	}
 
 	a.graphic.window.SetKeyCallback(a.onKey)
 	return a, nil
 }
 
+func loadRomDb(p string) (*core.RomDb, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db := core.NewRomDb()
+	if err = db.Load(f); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
 func (a *App) deInit() {
 	if a.audio != nil {
 		a.audio.deInit()
@@ -87,6 +149,50 @@ func (a *App) deInit() {
 	}
 }
 
+func (a *App) loadSav() error {
+	f, err := os.Open(a.savPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return a.sys.LoadBatteryRam(f)
+}
+
+func (a *App) flushSav() error {
+	if err := a.flushFds(); err != nil {
+		return err
+	}
+	if len(a.savPath) == 0 || !a.sys.IsBatteryRamDirty() {
+		return nil
+	}
+	f, err := os.Create(a.savPath)
+	if err != nil {
+		return err
+	}
+	if err = a.sys.SaveBatteryRam(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (a *App) flushFds() error {
+	if len(a.fdsPath) == 0 || !a.sys.IsFdsImageDirty() {
+		return nil
+	}
+	f, err := os.Create(a.fdsPath)
+	if err != nil {
+		return err
+	}
+	if err = a.sys.SaveFdsImage(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func (a *App) run() error {
 	if err := a.audio.stream.Start(); err != nil {
 		return err
@@ -95,6 +201,7 @@ func (a *App) run() error {
 	sys, win := a.sys, a.graphic.window
 	p := float64(sys.GetFramePeriod()) / 1000.0
 	a.te = glfw.GetTime()
+	a.ts = a.te + savePeriod
 	for !win.ShouldClose() {
 		sys.SetFrameBuffer(a.graphic.fb)
 		a.t = glfw.GetTime()
@@ -103,8 +210,14 @@ func (a *App) run() error {
 		}
 		a.graphic.runFrame()
 		glfw.PollEvents()
+		if a.t >= a.ts {
+			a.ts = a.t + savePeriod
+			if err := a.flushSav(); err != nil {
+				println(err.Error())
+			}
+		}
 	}
-	return nil
+	return a.flushSav()
 }
 
 func init() {
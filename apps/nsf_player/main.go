@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ldeng7/go-fc/core"
+)
+
+type conf struct {
+	nsfPath  string
+	track    int
+	length   float64
+	tvFormat int
+	stereo   bool
+}
+
+func parseArgs() *conf {
+	c := &conf{}
+	flag.StringVar(&c.nsfPath, "nsf", "", "nsf or nsfe path")
+	flag.IntVar(&c.track, "track", 0, "first track to play, 0=default track of the file")
+	flag.Float64Var(&c.length, "len", 150, "seconds to play a track of unknown length")
+	flag.IntVar(&c.tvFormat, "tv", -1, "tv format: -1=auto, 0=ntsc, 1=pal, 2=pal-china")
+	flag.BoolVar(&c.stereo, "stereo", false, "stereo output")
+	flag.Parse()
+	if len(c.nsfPath) == 0 {
+		flag.PrintDefaults()
+		return nil
+	}
+	if c.tvFormat < -1 || c.tvFormat > 2 {
+		println("invalid tv format")
+		return nil
+	}
+	return c
+}
+
+type App struct {
+	length float64
+	sys    *core.Sys
+	audio  *Audio
+}
+
+func newApp(c *conf) (*App, error) {
+	a := &App{length: c.length * 1000}
+	var err error
+	defer func() {
+		if err != nil {
+			a.deInit()
+		}
+	}()
+
+	if a.audio, err = newAudio(c.stereo); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(c.nsfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ac := &core.Conf{
+		RenderMode:    core.RenderModeAuto,
+		TvFormat:      byte(c.tvFormat),
+		AudioSampRate: a.audio.sampRate,
+	}
+	ac.AudioTargetFill = a.audio.sampRate / 8
+	ac.AudioStereo = c.stereo
+	if a.sys, err = core.NewSys(f, ac); err != nil {
+		return nil, err
+	}
+	if !a.sys.IsNsf() {
+		err = fmt.Errorf("%s is not an nsf file", c.nsfPath)
+		return nil, err
+	}
+	if c.track > 0 {
+		if err = a.sys.NsfSelectTrack(c.track - 1); err != nil {
+			return nil, err
+		}
+	}
+	a.sys.SetFrameBuffer(&core.FrameBuffer{})
+	a.audio.source = a.sys.GetAudioDataQueue()
+	if c.stereo {
+		a.sys.SetAudioChanPan(core.AudioChanPulse1, -0.3)
+		a.sys.SetAudioChanPan(core.AudioChanPulse2, 0.3)
+	}
+	return a, nil
+}
+
+func (a *App) deInit() {
+	if a.audio != nil {
+		a.audio.deInit()
+	}
+}
+
+func (a *App) trackLen(info *core.NsfInfo, track int) float32 {
+	if track < len(info.TrackTimes) && info.TrackTimes[track] >= 0 {
+		return float32(info.TrackTimes[track])
+	}
+	return float32(a.length)
+}
+
+func (a *App) printTrack(info *core.NsfInfo, track int) {
+	name := ""
+	if track < len(info.TrackNames) {
+		name = info.TrackNames[track]
+	}
+	fmt.Printf("track %d/%d %s\n", track+1, info.NSong, name)
+}
+
+func (a *App) run() error {
+	if err := a.audio.stream.Start(); err != nil {
+		return err
+	}
+
+	sys := a.sys
+	info := sys.GetNsfInfo()
+	fmt.Printf("%s\n%s\n%s\n", info.Name, info.Artist, info.Copyright)
+	p := time.Duration(float64(sys.GetFramePeriod()) * float64(time.Millisecond))
+	tk := time.NewTicker(p)
+	defer tk.Stop()
+
+	track := sys.NsfGetTrack()
+	a.printTrack(&info, track)
+	for range tk.C {
+		sys.RunFrame()
+		if sys.NsfGetTrackTime() < a.trackLen(&info, track) {
+			continue
+		}
+		if track++; track >= int(info.NSong) {
+			break
+		}
+		if err := sys.NsfSelectTrack(track); err != nil {
+			return err
+		}
+		a.printTrack(&info, track)
+	}
+	return nil
+}
+
+func main() {
+	c := parseArgs()
+	if nil == c {
+		return
+	}
+	app, err := newApp(c)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	if err = app.run(); err != nil {
+		println(err.Error())
+	}
+	app.deInit()
+}
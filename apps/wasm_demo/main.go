@@ -36,7 +36,9 @@ func (ctx *Ctx) start(romFileArr js.Value, romFileLen int) interface{} {
 	ctx.copyFromJsArr.Invoke(romFileArr, romFilePtr)
 
 	sysConf := &core.Conf{
-		AllSprite: true,
+		AllSprite:  true,
+		RenderMode: core.RenderModeAuto,
+		TvFormat:   core.TvFormatAuto,
 	}
 	sys, err := core.NewSys(bytes.NewReader(romFile), sysConf)
 	if err != nil {
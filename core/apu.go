@@ -1,33 +1,109 @@
 package core
 
+import "sync/atomic"
+
 const (
 	apuEventQueueLen     = 8192
 	apuEventQueueLenMask = apuEventQueueLen - 1
+	apuDataQueueLen      = 65536
+	apuDataQueueLenMask  = apuDataQueueLen - 1
+
+	// the most the dynamic rate control changes the resampling ratio by
+	apuRateCtlMaxDelta = 0.005
 )
 
+// ApuDataQueue passes the samples from the emulation to the audio output,
+// without locking, for one goroutine running the emulation and one calling
+// Dequeue. In stereo, samples are interleaved left then right, a pair making
+// a frame; in mono a frame is a single sample. Frames which do not fit are
+// dropped, and a Dequeue finding too few pads with the last frame.
 type ApuDataQueue struct {
-	rp, wp uint16
-	data   [65536]float32
+	rp, wp    uint32
+	flush     uint32
+	nOverrun  uint32
+	nUnderrun uint32
+	nChan     uint32
+	last      [2]float32
+	data      [apuDataQueueLen]float32
 }
 
+// reset is called by the producer; the consumer drops what it finds queued
+// on its next Dequeue.
 func (q *ApuDataQueue) reset() {
-	q.rp, q.wp = 0, 0
+	atomic.StoreUint32(&q.flush, 1)
 }
 
 func (q *ApuDataQueue) enqueue(d float32) {
-	q.data[q.wp] = d
-	q.wp++
+	wp := q.wp
+	if wp-atomic.LoadUint32(&q.rp) >= apuDataQueueLen {
+		atomic.AddUint32(&q.nOverrun, 1)
+		return
+	}
+	q.data[wp&apuDataQueueLenMask] = d
+	atomic.StoreUint32(&q.wp, wp+1)
 }
 
-func (q *ApuDataQueue) Dequeue(buf []float32) {
-	b, e := q.rp, q.rp+uint16(len(buf))
-	if e >= b {
-		copy(buf, q.data[b:e])
-	} else {
-		copy(buf, q.data[b:])
-		copy(buf[65536-uint32(b):], q.data[:e])
+func (q *ApuDataQueue) enqueueStereo(l, r float32) {
+	wp := q.wp
+	if wp-atomic.LoadUint32(&q.rp) >= apuDataQueueLen-1 {
+		atomic.AddUint32(&q.nOverrun, 1)
+		return
+	}
+	q.data[wp&apuDataQueueLenMask] = l
+	q.data[(wp+1)&apuDataQueueLenMask] = r
+	atomic.StoreUint32(&q.wp, wp+2)
+}
+
+// Dequeue fills buf, and returns how many of the frames came from the queue.
+// In stereo, len(buf) is to be even.
+func (q *ApuDataQueue) Dequeue(buf []float32) int {
+	rp, wp := q.rp, atomic.LoadUint32(&q.wp)
+	if atomic.SwapUint32(&q.flush, 0) != 0 {
+		rp = wp
+	}
+	n := int(wp - rp)
+	if n > len(buf) {
+		n = len(buf)
 	}
-	q.rp = e
+	n -= n % int(q.nChan)
+	k := copy(buf[:n], q.data[rp&apuDataQueueLenMask:])
+	copy(buf[k:n], q.data[:])
+	if n != 0 {
+		copy(q.last[:q.nChan], buf[n-int(q.nChan):n])
+	}
+	if n < len(buf) {
+		atomic.AddUint32(&q.nUnderrun, 1)
+		for i := n; i < len(buf); i++ {
+			buf[i] = q.last[uint32(i-n)%q.nChan]
+		}
+	}
+	atomic.StoreUint32(&q.rp, rp+uint32(n))
+	return n / int(q.nChan)
+}
+
+// Len returns the number of frames queued.
+func (q *ApuDataQueue) Len() int {
+	return int(atomic.LoadUint32(&q.wp)-atomic.LoadUint32(&q.rp)) / int(q.nChan)
+}
+
+// Cap returns the number of frames the queue holds at most.
+func (q *ApuDataQueue) Cap() int {
+	return apuDataQueueLen / int(q.nChan)
+}
+
+// NChan returns the number of samples per frame: 1 for mono, 2 for stereo.
+func (q *ApuDataQueue) NChan() int {
+	return int(q.nChan)
+}
+
+// Overruns counts the frames dropped for a full queue.
+func (q *ApuDataQueue) Overruns() uint32 {
+	return atomic.LoadUint32(&q.nOverrun)
+}
+
+// Underruns counts the Dequeue calls padded for an empty queue.
+func (q *ApuDataQueue) Underruns() uint32 {
+	return atomic.LoadUint32(&q.nUnderrun)
 }
 
 type apuEventQueueNode struct {
@@ -69,20 +145,95 @@ func (q *apuEventQueue) forceDequeue(no *apuEventQueueNode) bool {
 	return true
 }
 
+func (q *apuEventQueue) serialize(s *stateBuf) {
+	n := (q.wp - q.rp) & apuEventQueueLenMask
+	s.u16(&n)
+	if s.load {
+		q.reset()
+	}
+	for i := uint16(0); i < n; i++ {
+		node := &q.data[(q.rp+i)&apuEventQueueLenMask]
+		s.u8(&node.data)
+		s.u16(&node.addr)
+		s.i64(&node.time)
+	}
+	if s.load {
+		q.wp = n & apuEventQueueLenMask
+	}
+}
+
+// apuExChan is an expansion sound chip on the cartridge side. Its register
+// writes travel through the event queue like the internal channels, so
+// writeAsync is called from render at the right time, as clock is on every
+// cpu cycle. output gets the 8.8 fixed point mixer gains of the voices listed
+// by chanNames.
+type apuExChan interface {
+	reset()
+	writeAsync(addr uint16, data byte)
+	clock()
+	output(gain []int32) int32
+	serialize(s *stateBuf)
+	chanNames() []string
+}
+
+// apuExMulti combines the chips of a multi chip nsf. Their registers do not
+// overlap, so each write is offered to every chip.
+type apuExMulti []apuExChan
+
+func (chs apuExMulti) reset() {
+	for _, ch := range chs {
+		ch.reset()
+	}
+}
+
+func (chs apuExMulti) writeAsync(addr uint16, data byte) {
+	for _, ch := range chs {
+		ch.writeAsync(addr, data)
+	}
+}
+
+func (chs apuExMulti) clock() {
+	for _, ch := range chs {
+		ch.clock()
+	}
+}
+
+func (chs apuExMulti) output(gain []int32) int32 {
+	var o int32
+	for _, ch := range chs {
+		n := len(ch.chanNames())
+		o += ch.output(gain[:n])
+		gain = gain[n:]
+	}
+	return o
+}
+
+func (chs apuExMulti) serialize(s *stateBuf) {
+	for _, ch := range chs {
+		ch.serialize(s)
+	}
+}
+
+func (chs apuExMulti) chanNames() []string {
+	var names []string
+	for _, ch := range chs {
+		names = append(names, ch.chanNames()...)
+	}
+	return names
+}
+
 type Apu struct {
 	sys *Sys
 
-	sampRate    uint16
-	renderLen   uint16
-	ratio       int32
-	frameNCycle int64
-	rate        float64
-	cutoff      float64
+	sampRate   uint16
+	targetFill int
+	stereo     bool
 
 	reg     byte
 	syncReg byte
-	time    float64
-	outTmp  float64
+	nCycle  int64 // the cpu cycle rendered up to
+	out     int32
+	outR    int32
 
 	frameIrqOccur bool
 	frameIrq      byte
@@ -94,21 +245,36 @@ type Apu struct {
 	ch2 *apuChanTri
 	ch3 *apuChanNoise
 	ch4 *apuChanDpcm
+	ex  apuExChan
 	dq  ApuDataQueue
 	eq  apuEventQueue
+
+	blip   *apuBlip
+	filter *apuOutFilter
+	mixer  apuMixer
+	rec    *apuRec
+
+	// the right channel in stereo, the above being the left one
+	blipR   *apuBlip
+	filterR *apuOutFilter
+	bufL    []int32
 }
 
 func newApu(sys *Sys) *Apu {
 	apu := &Apu{}
 	apu.sys = sys
 
-	tvFormat, conf := &sys.tvFormat, sys.conf
-	apu.sampRate = conf.AudioSampRate
-	apu.renderLen = apu.sampRate / 60
-	apu.ratio = int32(tvFormat.cpuRate * 65536.0 / float32(apu.sampRate))
-	apu.frameNCycle = tvFormat.nScanlineCycle * int64(tvFormat.nScanline)
-	apu.rate = float64(apu.frameNCycle) * 5 / float64(apu.sampRate)
-	apu.cutoff = 251.32741228718345 / float64(apu.sampRate)
+	apu.sampRate = sys.conf.AudioSampRate
+	apu.targetFill = int(sys.conf.AudioTargetFill)
+	apu.stereo = sys.conf.AudioStereo
+	apu.blip = newApuBlip(float64(sys.tvFormat.cpuRate), apu.sampRate)
+	apu.filter = newApuOutFilter(apu.sampRate)
+	apu.blipR = newApuBlip(float64(sys.tvFormat.cpuRate), apu.sampRate)
+	apu.filterR = newApuOutFilter(apu.sampRate)
+	apu.dq.nChan = 1
+	if apu.stereo {
+		apu.dq.nChan = 2
+	}
 
 	apu.ch0 = &apuChanRect{apu: apu, enMask: 0x01}
 	apu.ch1 = &apuChanRect{apu: apu, enMask: 0x02}
@@ -128,9 +294,13 @@ func (apu *Apu) reset(init bool) {
 	}
 
 	apu.reg, apu.syncReg = 0, 0
-	apu.time = 0
+	apu.nCycle, apu.out, apu.outR = apu.sys.cpu.nCycle, 0, 0
 	apu.dq.reset()
 	apu.eq.reset()
+	apu.blip.reset()
+	apu.filter.reset()
+	apu.blipR.reset()
+	apu.filterR.reset()
 	apu.ch3.shiftReg = 0x4000
 	for i := uint16(0x4000); i <= 0x4010; i++ {
 		apu.writeAsync(i, 0)
@@ -141,6 +311,35 @@ func (apu *Apu) reset(init bool) {
 		apu.write(i, 0)
 	}
 	apu.frameIrqOccur, apu.frameIrq, apu.frameCnt, apu.frameCycle = false, 0xc0, 0, 0
+	if apu.ex != nil {
+		apu.ex.reset()
+	}
+	apu.mixer.setChans(apu.chanNames())
+}
+
+func (apu *Apu) serialize(s *stateBuf) {
+	s.u8(&apu.reg)
+	s.u8(&apu.syncReg)
+	s.i64(&apu.nCycle)
+	s.i32(&apu.out)
+	s.i32(&apu.outR)
+	apu.blip.serialize(s)
+	apu.filter.serialize(s)
+	apu.blipR.serialize(s)
+	apu.filterR.serialize(s)
+	s.bool(&apu.frameIrqOccur)
+	s.u8(&apu.frameIrq)
+	s.u32(&apu.frameCnt)
+	s.i32(&apu.frameCycle)
+	apu.ch0.serialize(s)
+	apu.ch1.serialize(s)
+	apu.ch2.serialize(s)
+	apu.ch3.serialize(s)
+	apu.ch4.serialize(s)
+	if apu.ex != nil {
+		apu.ex.serialize(s)
+	}
+	apu.eq.serialize(s)
 }
 
 func (apu *Apu) read(addr uint16) byte {
@@ -224,6 +423,10 @@ func (apu *Apu) writeAsync(addr uint16, data byte) {
 		apu.ch1.updateAsync(data)
 		apu.ch2.updateAsync(data)
 		apu.ch3.updateAsync(data)
+	default:
+		if apu.ex != nil {
+			apu.ex.writeAsync(addr, data)
+		}
 	}
 }
 
@@ -262,7 +465,7 @@ func (apu *Apu) updateFrame() {
 		}
 	case 3:
 		if apu.frameIrq&0x80 != 0 {
-			apu.frameCycle += 14915
+			apu.frameCycle += apu.sys.tvFormat.apuFrameCycle
 		}
 	}
 	apu.write(0x4018, byte(apu.frameCnt))
@@ -290,7 +493,7 @@ func (apu *Apu) write(addr uint16, data byte) {
 		if data&0x80 != 0 {
 			apu.updateFrame()
 		}
-		apu.frameCnt, apu.frameCycle = 1, 14915
+		apu.frameCnt, apu.frameCycle = 1, apu.sys.tvFormat.apuFrameCycle
 	case 0x4018:
 		apu.ch0.update(data)
 		apu.ch1.update(data)
@@ -301,40 +504,154 @@ func (apu *Apu) write(addr uint16, data byte) {
 	apu.eq.enqueue(n)
 }
 
+func (apu *Apu) writeEx(addr uint16, data byte) {
+	n := &apuEventQueueNode{data, addr, apu.sys.cpu.nCycle}
+	apu.eq.enqueue(n)
+}
+
 func (apu *Apu) sync(nCycle int32) {
 	apu.frameCycle -= nCycle << 1
 	if apu.frameCycle <= 0 {
-		apu.frameCycle += 14915
+		apu.frameCycle += apu.sys.tvFormat.apuFrameCycle
 		apu.updateFrame()
 	}
 	apu.ch4.update(nCycle)
 }
 
+// clock runs the 2a03 channels for a cpu cycle, telling if their mix may
+// have changed.
+func (apu *Apu) clock() bool {
+	b := apu.ch0.clock()
+	b = apu.ch1.clock() || b
+	b = apu.ch2.clock() || b
+	b = apu.ch3.clock() || b
+	return apu.ch4.clock() || b
+}
+
+// rateCtl nudges the resampling ratio so that the queue stays around the
+// target fill, whatever the drift between the frame timing of the host and
+// its audio clock. The change is small enough not to be heard as pitch.
+func (apu *Apu) rateCtl() {
+	d := float64(apu.dq.Len()-apu.targetFill) / float64(apu.targetFill)
+	if d > 1 {
+		d = 1
+	} else if d < -1 {
+		d = -1
+	}
+	apu.blip.setRatio(1 - apuRateCtlMaxDelta*d)
+	apu.blipR.setRatio(1 - apuRateCtlMaxDelta*d)
+}
+
+// render runs the channels cycle by cycle up to the cpu, applying the
+// register writes on the way, and feeds every change of their mix to the
+// blip buffer, or in stereo to one per side with the panned gains. The
+// resulting samples go through the output filters.
 func (apu *Apu) render() {
-	cpuNCycle := apu.sys.cpu.nCycle
-	if int64(apu.time) > cpuNCycle {
-		n := apuEventQueueNode{}
-		for apu.eq.forceDequeue(&n) {
-			apu.writeAsync(n.addr, n.data)
+	mx := &apu.mixer
+	stereo := apu.stereo
+	gain, gainR := mx.gain, mx.gainR
+	if stereo {
+		gain = mx.gainL
+	}
+	var w, wR [5]int32
+	for i := range w {
+		w[i] = apuMixWeights[i] * gain[i] >> 8
+		wR[i] = apuMixWeights[i] * gainR[i] >> 8
+	}
+	gainEx, gainExR := gain[AudioChanEx:], gainR[AudioChanEx:]
+	vol := float64(mx.master) / 32768
+	var recMix *apuRecWriter
+	if apu.rec != nil {
+		recMix = apu.rec.mix
+	}
+	emit := func(o int32) {
+		d := float32(apu.filter.filter(float64(o)) * vol)
+		apu.dq.enqueue(d)
+		if recMix != nil {
+			recMix.put(d)
 		}
 	}
-
-	for i := uint16(0); i < apu.renderLen; i++ {
-		t := int64(apu.time)
-		n := apuEventQueueNode{}
-		for apu.eq.dequeue(t, &n) {
-			apu.writeAsync(n.addr, n.data)
+	iL := 0
+	emitStereo := func(o int32) {
+		l := float32(apu.filter.filter(float64(apu.bufL[iL])) * vol)
+		r := float32(apu.filterR.filter(float64(o)) * vol)
+		apu.dq.enqueueStereo(l, r)
+		if recMix != nil {
+			recMix.put(l)
+			recMix.put(r)
 		}
+		iL++
+	}
+	pushL := func(o int32) {
+		apu.bufL = append(apu.bufL, o)
+	}
+	bRecChans := apu.rec != nil && len(apu.rec.tracks) != 0
+	if apu.targetFill != 0 {
+		apu.rateCtl()
+	}
 
-		o := (apu.ch0.render()*0x00f0 + apu.ch1.render()*0x00f0 + apu.ch2.render()*0x0130 +
-			apu.ch3.render()*0x00c0 + apu.ch4.render()*0x00f0) >> 8
-		o1 := float64(o) - apu.outTmp
-		apu.outTmp += apu.cutoff * o1
-		o1 /= 32768
-		apu.dq.enqueue(float32(o1))
-		apu.time += apu.rate
+	end, mix, mixR, bMix := apu.sys.cpu.nCycle, int32(0), int32(0), true
+	for apu.nCycle < end {
+		nCycle := int32(apuBlipMaxCycle)
+		if d := end - apu.nCycle; d < int64(nCycle) {
+			nCycle = int32(d)
+		}
+		for t := int32(0); t < nCycle; t++ {
+			n := apuEventQueueNode{}
+			for apu.eq.dequeue(apu.nCycle, &n) {
+				apu.writeAsync(n.addr, n.data)
+				bMix = true
+			}
+			if apu.clock() || bMix {
+				if mx.nonlinear {
+					mix = apu.mixNonlinear(gain)
+					if stereo {
+						mixR = apu.mixNonlinear(gainR)
+					}
+				} else {
+					mix = apu.mixLinear(&w)
+					if stereo {
+						mixR = apu.mixLinear(&wR)
+					}
+				}
+				bMix = false
+			}
+			apu.nCycle++
+
+			o, oR := mix, mixR
+			if apu.ex != nil {
+				apu.ex.clock()
+				o += apu.ex.output(gainEx)
+				if stereo {
+					oR += apu.ex.output(gainExR)
+				}
+			}
+			if o != apu.out {
+				apu.blip.addDelta(t, o-apu.out)
+				apu.out = o
+			}
+			if oR != apu.outR {
+				apu.blipR.addDelta(t, oR-apu.outR)
+				apu.outR = oR
+			}
+			if bRecChans {
+				apu.recChans(t)
+			}
+		}
+		apu.blip.endFrame(nCycle)
+		if stereo {
+			apu.blipR.endFrame(nCycle)
+			apu.bufL, iL = apu.bufL[:0], 0
+			apu.blip.read(pushL)
+			apu.blipR.read(emitStereo)
+		} else {
+			apu.blip.read(emit)
+		}
+		if bRecChans {
+			apu.recEndFrame(nCycle)
+		}
 	}
-	if d := int64(apu.time) - cpuNCycle; d > apu.frameNCycle/24 || d < -apu.frameNCycle/6 {
-		apu.time = float64(cpuNCycle)
+	if apu.rec != nil {
+		apu.recFlush()
 	}
 }
@@ -0,0 +1,154 @@
+package core
+
+import "math"
+
+// apu5bVolLut follows the chip's dac, 1.5 dB per step with step 0 silent.
+// The loudest step of one channel matches a full 2a03 pulse.
+var apu5bVolLut = func() (lut [32]int32) {
+	for i := 1; i < 32; i++ {
+		lut[i] = int32(7200.0*math.Pow(10, -1.5*float64(31-i)/20.0) + 0.5)
+	}
+	return
+}()
+
+// apuChan5b is the Sunsoft 5B, a YM2149 clone: three square tones, one noise
+// source and an envelope, with registers written as $e000+index.
+type apuChan5b struct {
+	apu *Apu
+
+	reg       [16]byte
+	toneTimer [3]int32
+	toneOut   [3]bool
+
+	noiseTimer int32
+	noiseLfsr  uint32
+
+	envTimer   int32
+	envCnt     int8
+	envAttack  byte
+	envHold    bool
+	envAlt     bool
+	envHolding bool
+}
+
+func newApuChan5b(apu *Apu) *apuChan5b {
+	return &apuChan5b{apu: apu}
+}
+
+func (ch *apuChan5b) reset() {
+	*ch = apuChan5b{apu: ch.apu, noiseLfsr: 1}
+	ch.reg[7] = 0xff
+}
+
+func (ch *apuChan5b) serialize(s *stateBuf) {
+	s.bytes(ch.reg[:])
+	for i := 0; i < 3; i++ {
+		s.i32(&ch.toneTimer[i])
+		s.bool(&ch.toneOut[i])
+	}
+	s.i32(&ch.noiseTimer)
+	s.u32(&ch.noiseLfsr)
+	s.i32(&ch.envTimer)
+	envCnt := byte(ch.envCnt)
+	s.u8(&envCnt)
+	ch.envCnt = int8(envCnt)
+	s.u8(&ch.envAttack)
+	s.bool(&ch.envHold)
+	s.bool(&ch.envAlt)
+	s.bool(&ch.envHolding)
+}
+
+func (ch *apuChan5b) writeAsync(addr uint16, data byte) {
+	if addr&0xfff0 != 0xe000 {
+		return
+	}
+	r := addr & 0x0f
+	ch.reg[r] = data
+	if r == 0x0d {
+		// restart the envelope, as in the ay8910 core of mame
+		ch.envAttack, ch.envCnt, ch.envHolding = 0, 0x1f, false
+		if data&0x04 != 0 {
+			ch.envAttack = 0x1f
+		}
+		if data&0x08 == 0 {
+			ch.envHold, ch.envAlt = true, ch.envAttack != 0
+		} else {
+			ch.envHold, ch.envAlt = data&0x01 != 0, data&0x02 != 0
+		}
+	}
+}
+
+func (ch *apuChan5b) tonePeriod(i int) int32 {
+	p := int32(ch.reg[i<<1]) | (int32(ch.reg[i<<1|1]&0x0f) << 8)
+	if p == 0 {
+		p = 1
+	}
+	return p << 4
+}
+
+func (ch *apuChan5b) clock() {
+	for i := 0; i < 3; i++ {
+		if ch.toneTimer[i]--; ch.toneTimer[i] <= 0 {
+			ch.toneTimer[i] = ch.tonePeriod(i)
+			ch.toneOut[i] = !ch.toneOut[i]
+		}
+	}
+
+	if ch.noiseTimer--; ch.noiseTimer <= 0 {
+		p := int32(ch.reg[6] & 0x1f)
+		if p == 0 {
+			p = 1
+		}
+		ch.noiseTimer = p << 5
+		if (ch.noiseLfsr^(ch.noiseLfsr>>3))&0x01 != 0 {
+			ch.noiseLfsr |= 0x20000
+		}
+		ch.noiseLfsr >>= 1
+	}
+
+	if ch.envTimer--; ch.envTimer <= 0 {
+		p := int32(ch.reg[11]) | int32(ch.reg[12])<<8
+		if p == 0 {
+			p = 1
+		}
+		ch.envTimer = p << 4
+		if !ch.envHolding {
+			if ch.envCnt--; ch.envCnt < 0 {
+				if ch.envHold {
+					if ch.envAlt {
+						ch.envAttack ^= 0x1f
+					}
+					ch.envHolding, ch.envCnt = true, 0
+				} else {
+					if ch.envAlt {
+						ch.envAttack ^= 0x1f
+					}
+					ch.envCnt &= 0x1f
+				}
+			}
+		}
+	}
+}
+
+func (ch *apuChan5b) output(gain []int32) int32 {
+	var o int32
+	mixer, noise := ch.reg[7], ch.noiseLfsr&0x01 != 0
+	for i := uint(0); i < 3; i++ {
+		if (!ch.toneOut[i] && mixer&(0x01<<i) == 0) || (!noise && mixer&(0x08<<i) == 0) {
+			continue
+		}
+		v := ch.reg[8+i]
+		if v&0x10 != 0 {
+			o += apu5bVolLut[byte(ch.envCnt)^ch.envAttack] * gain[i]
+		} else if v &= 0x0f; v != 0 {
+			o += apu5bVolLut[v<<1|1] * gain[i]
+		}
+	}
+	return o >> 8
+}
+
+var apu5bChanNames = []string{"5b a", "5b b", "5b c"}
+
+func (ch *apuChan5b) chanNames() []string {
+	return apu5bChanNames
+}
@@ -0,0 +1,156 @@
+package core
+
+import "math"
+
+const (
+	apuBlipPhaseBits  = 6
+	apuBlipPhaseNum   = 1 << apuBlipPhaseBits
+	apuBlipHalfWidth  = 8
+	apuBlipWidth      = apuBlipHalfWidth * 2
+	apuBlipKernelBits = 15
+	apuBlipFracBits   = 32
+	apuBlipMaxCycle   = 0x8000
+	apuBlipMaxRatio   = 1.01
+)
+
+// apuBlipKernel holds, for each fraction of a sample, the band-limited
+// impulse a step is spread by: a blackman windowed sinc cut off a bit below
+// the nyquist frequency, each summing to 1 in apuBlipKernelBits fixed point.
+var apuBlipKernel = func() (k [apuBlipPhaseNum][apuBlipWidth]int64) {
+	const fc = 0.45
+	for p := range k {
+		var f [apuBlipWidth]float64
+		var sum float64
+		for i := range f {
+			x := float64(i-apuBlipHalfWidth+1) - float64(p)/apuBlipPhaseNum
+			v := 2 * fc
+			if x != 0 {
+				v = math.Sin(2*math.Pi*fc*x) / (math.Pi * x)
+			}
+			w := x / apuBlipHalfWidth
+			v *= 0.42 + 0.5*math.Cos(math.Pi*w) + 0.08*math.Cos(2*math.Pi*w)
+			f[i], sum = v, sum+v
+		}
+		var rem int64 = 1 << apuBlipKernelBits
+		for i := range f {
+			k[p][i] = int64(math.Floor(f[i]/sum*(1<<apuBlipKernelBits) + 0.5))
+			rem -= k[p][i]
+		}
+		k[p][apuBlipHalfWidth-1] += rem
+	}
+	return
+}()
+
+// apuBlip turns output changes stamped in cpu cycles into samples. Changes
+// are kept as band-limited deltas, summed up when the samples are read.
+type apuBlip struct {
+	factor  uint64 // samples per cpu cycle, apuBlipFracBits fixed point
+	factor0 uint64 // factor at the nominal ratio
+	offset  uint64 // start of the current frame, in samples likewise
+	integ   int64
+	buf     []int64
+}
+
+func newApuBlip(cpuRate float64, sampRate uint16) *apuBlip {
+	b := &apuBlip{}
+	b.factor = uint64(float64(sampRate)/cpuRate*(1<<apuBlipFracBits) + 0.5)
+	b.factor0 = b.factor
+	n := uint64(apuBlipMaxCycle*apuBlipMaxRatio*float64(b.factor))>>apuBlipFracBits + apuBlipWidth + 1
+	b.buf = make([]int64, n)
+	return b
+}
+
+// setRatio scales the number of samples per cycle from the nominal one, by
+// at most apuBlipMaxRatio.
+func (b *apuBlip) setRatio(r float64) {
+	if r > apuBlipMaxRatio {
+		r = apuBlipMaxRatio
+	}
+	b.factor = uint64(float64(b.factor0)*r + 0.5)
+}
+
+func (b *apuBlip) reset() {
+	b.offset, b.integ = 0, 0
+	for i := range b.buf {
+		b.buf[i] = 0
+	}
+}
+
+// serialize keeps the kernel tails not yet read.
+func (b *apuBlip) serialize(s *stateBuf) {
+	s.u64(&b.offset)
+	s.i64(&b.integ)
+	for i := 0; i < apuBlipWidth; i++ {
+		s.i64(&b.buf[i])
+	}
+}
+
+// addDelta adds a change of the output at t cpu cycles in the frame.
+func (b *apuBlip) addDelta(t int32, delta int32) {
+	pos := b.offset + uint64(t)*b.factor
+	k := &apuBlipKernel[(pos>>(apuBlipFracBits-apuBlipPhaseBits))&(apuBlipPhaseNum-1)]
+	buf := b.buf[pos>>apuBlipFracBits:][:apuBlipWidth]
+	d := int64(delta)
+	for i := range buf {
+		buf[i] += k[i] * d
+	}
+}
+
+// endFrame ends a frame of nCycle cycles, at most apuBlipMaxCycle.
+func (b *apuBlip) endFrame(nCycle int32) {
+	b.offset += uint64(nCycle) * b.factor
+}
+
+// read passes every complete sample to f, and drops them.
+func (b *apuBlip) read(f func(o int32)) {
+	n := int(b.offset >> apuBlipFracBits)
+	for i := 0; i < n; i++ {
+		b.integ += b.buf[i]
+		f(int32(b.integ >> apuBlipKernelBits))
+	}
+	copy(b.buf, b.buf[n:n+apuBlipWidth])
+	for i := apuBlipWidth; i < n+apuBlipWidth; i++ {
+		b.buf[i] = 0
+	}
+	b.offset -= uint64(n) << apuBlipFracBits
+}
+
+// apuOutFilter is the output stage of the NES: first order high-passes at 90
+// and 440 Hz, then a first order low-pass at 14 kHz.
+type apuOutFilter struct {
+	hp0, hp1, lp float64
+	hp0X, hp0Y   float64
+	hp1X, hp1Y   float64
+	lpY          float64
+}
+
+func newApuOutFilter(sampRate uint16) *apuOutFilter {
+	dt := 1 / float64(sampRate)
+	rc := func(f float64) float64 { return 1 / (2 * math.Pi * f) }
+	return &apuOutFilter{
+		hp0: rc(90) / (rc(90) + dt),
+		hp1: rc(440) / (rc(440) + dt),
+		lp:  dt / (rc(14000) + dt),
+	}
+}
+
+func (f *apuOutFilter) reset() {
+	f.hp0X, f.hp0Y, f.hp1X, f.hp1Y, f.lpY = 0, 0, 0, 0, 0
+}
+
+func (f *apuOutFilter) serialize(s *stateBuf) {
+	s.f64(&f.hp0X)
+	s.f64(&f.hp0Y)
+	s.f64(&f.hp1X)
+	s.f64(&f.hp1Y)
+	s.f64(&f.lpY)
+}
+
+func (f *apuOutFilter) filter(x float64) float64 {
+	f.hp0Y = f.hp0 * (f.hp0Y + x - f.hp0X)
+	f.hp0X = x
+	f.hp1Y = f.hp1 * (f.hp1Y + f.hp0Y - f.hp1X)
+	f.hp1X = f.hp0Y
+	f.lpY += f.lp * (f.hp1Y - f.lpY)
+	return f.lpY
+}
@@ -13,9 +13,15 @@ var apuDutyLut = [4]byte{
 var apuNoiseFreq = [16]int32{
 	4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068,
 }
+var apuNoiseFreqPal = [16]int32{
+	4, 8, 14, 30, 60, 88, 118, 148, 188, 236, 354, 472, 708, 944, 1890, 3778,
+}
 var apuDpcmCycles = [16]uint16{
 	428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 85, 72, 54,
 }
+var apuDpcmCyclesPal = [16]uint16{
+	398, 354, 316, 298, 276, 236, 210, 198, 176, 148, 132, 118, 98, 78, 66, 50,
+}
 
 type apuChanRect struct {
 	apu    *Apu
@@ -51,6 +57,33 @@ type apuChanRect struct {
 	syncReg      [4]byte
 }
 
+func (ch *apuChanRect) serialize(s *stateBuf) {
+	s.bool(&ch.en)
+	s.bool(&ch.holdnote)
+	s.u8(&ch.volume)
+	s.bytes(ch.reg[:])
+	s.u8(&ch.adder)
+	s.u8(&ch.duty)
+	s.u8(&ch.lenCount)
+	s.i32(&ch.freq)
+	s.i32(&ch.freqLimit)
+	s.i32(&ch.curVolume)
+	s.i32(&ch.phaseAcc)
+	s.bool(&ch.envFixed)
+	s.u8(&ch.envDecay)
+	s.u8(&ch.envCount)
+	s.u8(&ch.envVol)
+	s.bool(&ch.swpOn)
+	s.bool(&ch.swpInc)
+	s.u8(&ch.swpShift)
+	s.u8(&ch.swpDecay)
+	s.u8(&ch.swpCount)
+	s.bool(&ch.syncEn)
+	s.bool(&ch.syncHoldnote)
+	s.u8(&ch.syncLenCount)
+	s.bytes(ch.syncReg[:])
+}
+
 func (ch *apuChanRect) writeAsync(addr uint16, data byte) {
 	i := addr & 0x03
 	ch.reg[i] = data
@@ -139,38 +172,37 @@ func (ch *apuChanRect) update(typ byte) {
 	}
 }
 
-func (ch *apuChanRect) render() int32 {
-	if !ch.en || ch.lenCount == 0 {
-		return 0
-	}
-	if ch.freq < 8 || (!ch.swpInc && ch.freq > ch.freqLimit) {
+func (ch *apuChanRect) audible() bool {
+	return ch.en && ch.lenCount != 0 && ch.freq >= 8 && (ch.swpInc || ch.freq <= ch.freqLimit)
+}
+
+// amp is the swing of output around 0.
+func (ch *apuChanRect) amp() int32 {
+	if !ch.audible() {
 		return 0
 	}
 	if ch.envFixed {
-		ch.curVolume = int32(ch.volume) << 8
-	}
-	r, w, f := ch.apu.ratio, ch.phaseAcc, (ch.freq+1)<<16
-	if w > r {
-		w = r
-	}
-	s := int64(w)
-	if ch.adder >= ch.duty {
-		s = int64(-w)
-	}
-	ch.phaseAcc -= r
-	for ch.phaseAcc < 0 {
-		ch.phaseAcc += f
-		ch.adder, w = (ch.adder+1)&0x0f, f
-		if ch.phaseAcc > 0 {
-			w -= ch.phaseAcc
-		}
-		if ch.adder < ch.duty {
-			s += int64(w)
-		} else {
-			s -= int64(w)
-		}
+		return int32(ch.volume) << 8
+	}
+	return ch.curVolume
+}
+
+// clock runs the timer for a cpu cycle, and tells if the output may have
+// changed, as do those of the other channels.
+func (ch *apuChanRect) clock() bool {
+	if ch.phaseAcc--; ch.phaseAcc >= 0 {
+		return false
+	}
+	ch.phaseAcc = ch.freq
+	ch.adder = (ch.adder + 1) & 0x0f
+	return ch.audible()
+}
+
+func (ch *apuChanRect) output() int32 {
+	if ch.adder < ch.duty {
+		return ch.amp()
 	}
-	return int32(int64(ch.curVolume) * s / int64(r))
+	return -ch.amp()
 }
 
 type apuChanTri struct {
@@ -196,6 +228,25 @@ type apuChanTri struct {
 	syncReg      [4]byte
 }
 
+func (ch *apuChanTri) serialize(s *stateBuf) {
+	s.bool(&ch.en)
+	s.bool(&ch.holdnote)
+	s.bool(&ch.cntStart)
+	s.bytes(ch.reg[:])
+	s.u8(&ch.adder)
+	s.u8(&ch.lenCount)
+	s.u8(&ch.linCount)
+	s.i32(&ch.freq)
+	s.i32(&ch.curVolume)
+	s.i32(&ch.phaseAcc)
+	s.bool(&ch.syncEn)
+	s.bool(&ch.syncHoldnote)
+	s.bool(&ch.syncCntStart)
+	s.u8(&ch.syncLenCount)
+	s.u8(&ch.syncLinCount)
+	s.bytes(ch.syncReg[:])
+}
+
 func (ch *apuChanTri) writeAsync(addr uint16, data byte) {
 	i := addr & 0x03
 	ch.reg[i] = data
@@ -203,9 +254,9 @@ func (ch *apuChanTri) writeAsync(addr uint16, data byte) {
 	case 0x00:
 		ch.holdnote = data&0x80 != 0
 	case 0x02:
-		ch.freq = ((int32(ch.reg[3]&0x07) << 8) + int32(data) + 1) << 16
+		ch.freq = (int32(ch.reg[3]&0x07) << 8) + int32(data) + 1
 	case 0x03:
-		ch.freq = ((int32(data&0x07) << 8) + int32(ch.reg[2]) + 1) << 16
+		ch.freq = (int32(data&0x07) << 8) + int32(ch.reg[2]) + 1
 		ch.lenCount = apuVblLen[data>>3] << 1
 		ch.cntStart = true
 		if ch.apu.reg&0x04 != 0 {
@@ -263,37 +314,31 @@ func (ch *apuChanTri) update(typ byte) {
 	}
 }
 
-func (ch *apuChanTri) renderSub() {
-	ch.phaseAcc += ch.freq
+func (ch *apuChanTri) clock() bool {
+	if !ch.en || ch.lenCount == 0 || ch.linCount == 0 || ch.freq < 8 {
+		return false
+	}
+	if ch.phaseAcc--; ch.phaseAcc > 0 {
+		return false
+	}
+	ch.phaseAcc = ch.freq
 	ch.adder = (ch.adder + 1) & 0x1f
 	if ch.adder < 0x10 {
 		ch.curVolume = int32(ch.adder&0x0f) << 9
 	} else {
 		ch.curVolume = int32(0x0f-(ch.adder&0x0f)) << 9
 	}
+	return true
 }
 
-func (ch *apuChanTri) render() int32 {
-	chd := ch.apu.ch4
-	var vol int32 = 256 - (int32(chd.dpcmValue) << 1) - (int32(chd.reg[1]) & 0x01)
-	if !ch.en || ch.lenCount == 0 || ch.linCount == 0 || ch.freq < 0x00080000 {
-		return ch.curVolume * vol / 256
-	}
-	ch.phaseAcc -= ch.apu.ratio
-	if ch.phaseAcc >= 0 {
-		return ch.curVolume * vol / 256
-	}
-	if ch.freq > ch.apu.ratio {
-		ch.renderSub()
-		return ch.curVolume * vol / 256
-	}
-	var c, s int32
-	for ch.phaseAcc < 0 {
-		ch.renderSub()
-		s += ch.curVolume
-		c++
-	}
-	return (s / c) * vol / 256
+// level is the raw step of the sequencer, in 1/256.
+func (ch *apuChanTri) level() int32 {
+	return ch.curVolume >> 1
+}
+
+// output is the level pulled down by the dmc, as through the shared dac.
+func (ch *apuChanTri) output() int32 {
+	return ch.curVolume * ch.apu.ch4.dacVol() >> 8
 }
 
 type apuChanNoise struct {
@@ -309,7 +354,6 @@ type apuChanNoise struct {
 	lenCount  byte
 	freq      int32
 	curVolume int32
-	output    int32
 	phaseAcc  int32
 
 	envFixed bool
@@ -323,6 +367,27 @@ type apuChanNoise struct {
 	syncReg      [4]byte
 }
 
+func (ch *apuChanNoise) serialize(s *stateBuf) {
+	s.bool(&ch.en)
+	s.bool(&ch.holdnote)
+	s.u8(&ch.volume)
+	s.u8(&ch.xorTap)
+	s.u16(&ch.shiftReg)
+	s.bytes(ch.reg[:])
+	s.u8(&ch.lenCount)
+	s.i32(&ch.freq)
+	s.i32(&ch.curVolume)
+	s.i32(&ch.phaseAcc)
+	s.bool(&ch.envFixed)
+	s.u8(&ch.envDecay)
+	s.u8(&ch.envCount)
+	s.u8(&ch.envVol)
+	s.bool(&ch.syncEn)
+	s.bool(&ch.syncHoldnote)
+	s.u8(&ch.syncLenCount)
+	s.bytes(ch.syncReg[:])
+}
+
 func (ch *apuChanNoise) writeAsync(addr uint16, data byte) {
 	i := addr & 0x03
 	ch.reg[i] = data
@@ -331,7 +396,7 @@ func (ch *apuChanNoise) writeAsync(addr uint16, data byte) {
 		ch.holdnote, ch.envFixed = data&0x20 != 0, data&0x10 != 0
 		ch.volume, ch.envDecay = data&0x0f, (data&0x0f)+1
 	case 0x02:
-		ch.freq = apuNoiseFreq[data&0x0f] << 16
+		ch.freq = ch.apu.sys.tvFormat.apuNoiseFreq[data&0x0f]
 		ch.xorTap = 0x40
 		if data&0x80 == 0 {
 			ch.xorTap = 0x02
@@ -391,46 +456,32 @@ func (ch *apuChanNoise) update(typ byte) {
 	}
 }
 
-func (ch *apuChanNoise) renderSub() {
-	ch.phaseAcc += ch.freq
+func (ch *apuChanNoise) clock() bool {
+	if ch.phaseAcc--; ch.phaseAcc > 0 {
+		return false
+	}
+	ch.phaseAcc = ch.freq
 	b := ch.shiftReg & 0x01
-	b1 := b
 	if ch.shiftReg&uint16(ch.xorTap) != 0 {
-		b1 ^= 0x01
-	}
-	ch.shiftReg >>= 1
-	ch.shiftReg |= b1 << 14
-	if b == 0 {
-		ch.output = ch.curVolume
-	} else {
-		ch.output -= ch.curVolume
+		b ^= 0x01
 	}
+	ch.shiftReg = (ch.shiftReg >> 1) | (b << 14)
+	return ch.en && ch.lenCount != 0
 }
 
-func (ch *apuChanNoise) render() int32 {
-	if !ch.en || ch.lenCount == 0 {
+// level is the dac input, in 1/256 steps.
+func (ch *apuChanNoise) level() int32 {
+	if !ch.en || ch.lenCount == 0 || ch.shiftReg&0x01 != 0 {
 		return 0
 	}
 	if ch.envFixed {
-		ch.curVolume = int32(ch.volume) << 8
-	}
-	chd := ch.apu.ch4
-	var vol int32 = 256 - (int32(chd.dpcmValue) << 1) - (int32(chd.reg[1]) & 0x01)
-	ch.phaseAcc -= ch.apu.ratio
-	if ch.phaseAcc >= 0 {
-		return ch.output * vol / 256
-	}
-	if ch.freq > ch.apu.ratio {
-		ch.renderSub()
-		return ch.output * vol / 256
+		return int32(ch.volume) << 8
 	}
-	var c, s int32
-	for ch.phaseAcc < 0 {
-		ch.renderSub()
-		s += ch.output
-		c++
-	}
-	return (s / c) * vol / 256
+	return ch.curVolume
+}
+
+func (ch *apuChanNoise) output() int32 {
+	return ch.level() * ch.apu.ch4.dacVol() >> 8
 }
 
 type apuChanDpcm struct {
@@ -447,9 +498,6 @@ type apuChanDpcm struct {
 	dmaLen      uint16
 	dmaLenCache uint16
 	freq        int32
-	outputReal  int32
-	outputFake  int32
-	output      int32
 	phaseAcc    int32
 
 	syncEn          bool
@@ -462,12 +510,34 @@ type apuChanDpcm struct {
 	syncNCycle      int32
 }
 
+func (ch *apuChanDpcm) serialize(s *stateBuf) {
+	s.bool(&ch.en)
+	s.bool(&ch.looping)
+	s.u8(&ch.curByte)
+	s.u8(&ch.dpcmValue)
+	s.bytes(ch.reg[:])
+	s.u16(&ch.addr)
+	s.u16(&ch.addrCache)
+	s.u16(&ch.dmaLen)
+	s.u16(&ch.dmaLenCache)
+	s.i32(&ch.freq)
+	s.i32(&ch.phaseAcc)
+	s.bool(&ch.syncEn)
+	s.bool(&ch.syncLooping)
+	s.bool(&ch.syncIrqGen)
+	s.bool(&ch.syncIrqEn)
+	s.u16(&ch.syncDmaLenCache)
+	s.u16(&ch.syncNCycleCache)
+	s.u16(&ch.syncDmaLen)
+	s.i32(&ch.syncNCycle)
+}
+
 func (ch *apuChanDpcm) writeAsync(addr uint16, data byte) {
 	i := addr & 0x03
 	ch.reg[i] = data
 	switch i {
 	case 0x00:
-		ch.freq = int32(apuDpcmCycles[data&0x0f]) << 16
+		ch.freq = int32(ch.apu.sys.tvFormat.apuDpcmCycles[data&0x0f])
 		ch.looping = data&0x40 != 0
 	case 0x01:
 		ch.dpcmValue = (data & 0x7f) >> 1
@@ -483,7 +553,7 @@ func (ch *apuChanDpcm) write(addr uint16, data byte) {
 	ch.reg[i] = data
 	switch i {
 	case 0x00:
-		ch.syncNCycleCache = apuDpcmCycles[data&0x0f] << 3
+		ch.syncNCycleCache = ch.apu.sys.tvFormat.apuDpcmCycles[data&0x0f] << 3
 		ch.syncLooping, ch.syncIrqGen = data&0x40 != 0, data&0x80 != 0
 		if !ch.syncIrqGen {
 			ch.syncIrqEn = false
@@ -520,50 +590,54 @@ func (ch *apuChanDpcm) update(nCycle int32) {
 	}
 }
 
-func (ch *apuChanDpcm) render() int32 {
-	if ch.dmaLen != 0 {
-		ch.phaseAcc -= ch.apu.ratio
-		sys := ch.apu.sys
-		for ch.phaseAcc < 0 {
-			ch.phaseAcc += ch.freq
-			if ch.dmaLen&0x07 == 0 {
-				ch.curByte = sys.read(ch.addr)
-				if ch.addr == 0xffff {
-					ch.addr = 0x8000
-				} else {
-					ch.addr++
-				}
-			}
-			ch.dmaLen--
-			if ch.dmaLen == 0 {
-				if ch.looping {
-					ch.addr, ch.dmaLen = ch.addrCache, ch.dmaLenCache
-				} else {
-					ch.en = false
-					break
-				}
-			}
-			if ch.curByte&(1<<((ch.dmaLen&0x07)^0x07)) != 0 {
-				if ch.dpcmValue < 0x3f {
-					ch.dpcmValue++
-				}
-			} else {
-				if ch.dpcmValue > 1 {
-					ch.dpcmValue--
-				}
-			}
+func (ch *apuChanDpcm) clock() bool {
+	if ch.dmaLen == 0 {
+		return false
+	}
+	if ch.phaseAcc--; ch.phaseAcc > 0 {
+		return false
+	}
+	ch.phaseAcc = ch.freq
+	if ch.dmaLen&0x07 == 0 {
+		ch.curByte = ch.apu.sys.read(ch.addr)
+		if ch.addr == 0xffff {
+			ch.addr = 0x8000
+		} else {
+			ch.addr++
 		}
 	}
-	ch.outputReal = (int32(ch.dpcmValue) << 1) + (int32(ch.reg[1]) & 0x01) - 64
-	if d := ch.outputReal - ch.outputFake; d <= 8 && d >= -8 {
-		ch.outputFake, ch.output = ch.outputReal, ch.outputReal<<8
-	} else {
-		if ch.outputReal > ch.outputFake {
-			ch.outputFake += 8
+	ch.dmaLen--
+	if ch.dmaLen == 0 {
+		if ch.looping {
+			ch.addr, ch.dmaLen = ch.addrCache, ch.dmaLenCache
 		} else {
-			ch.outputFake -= 8
+			ch.en = false
+			return false
+		}
+	}
+	if ch.curByte&(1<<((ch.dmaLen&0x07)^0x07)) != 0 {
+		if ch.dpcmValue < 0x3f {
+			ch.dpcmValue++
+		}
+	} else {
+		if ch.dpcmValue > 1 {
+			ch.dpcmValue--
 		}
-		ch.output = ch.outputFake << 8
 	}
-	return ch.output
+	return true
+}
+
+// level is the dac input, in 1/256 steps.
+func (ch *apuChanDpcm) level() int32 {
+	return ((int32(ch.dpcmValue) << 1) + (int32(ch.reg[1]) & 0x01)) << 8
+}
+
+func (ch *apuChanDpcm) output() int32 {
+	return ch.level() - 64<<8
+}
+
+// dacVol is how much of the triangle and noise gets through the dac shared
+// with the dmc, in 1/256.
+func (ch *apuChanDpcm) dacVol() int32 {
+	return 256 - (int32(ch.dpcmValue) << 1) - (int32(ch.reg[1]) & 0x01)
 }
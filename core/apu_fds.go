@@ -0,0 +1,258 @@
+package core
+
+var apuFdsMasterVol = [4]int32{36, 24, 17, 14}
+var apuFdsModLut = [8]int32{0, 1, 2, 4, 0, -4, -2, -1}
+
+type apuFdsEnv struct {
+	off    bool
+	inc    bool
+	speed  byte
+	gain   byte
+	master byte
+	timer  int32
+	freq   int32
+}
+
+func (e *apuFdsEnv) serialize(s *stateBuf) {
+	s.bool(&e.off)
+	s.bool(&e.inc)
+	s.u8(&e.speed)
+	s.u8(&e.gain)
+	s.u8(&e.master)
+	s.i32(&e.timer)
+	s.i32(&e.freq)
+}
+
+func (e *apuFdsEnv) resetTimer() {
+	e.timer = 8 * (int32(e.speed) + 1) * int32(e.master)
+}
+
+func (e *apuFdsEnv) writeCtrl(data byte) {
+	e.speed, e.inc, e.off = data&0x3f, data&0x40 != 0, data&0x80 != 0
+	e.resetTimer()
+	if e.off {
+		e.gain = e.speed
+	}
+}
+
+func (e *apuFdsEnv) tick() bool {
+	if e.off || e.master == 0 {
+		return false
+	}
+	if e.timer--; e.timer > 0 {
+		return false
+	}
+	e.resetTimer()
+	if e.inc && e.gain < 32 {
+		e.gain++
+	} else if !e.inc && e.gain > 0 {
+		e.gain--
+	}
+	return true
+}
+
+// apuChanFds is the disk system wavetable channel: a 64 step 6-bit wave
+// whose pitch is bent by a 64 step modulation table.
+type apuChanFds struct {
+	apu *Apu
+
+	wave    [64]byte
+	wavePos byte
+	waveAcc uint16
+	waveWr  bool
+	halt    bool
+	envHalt bool
+	vol     byte
+	volEnv  apuFdsEnv
+
+	modEnv apuFdsEnv
+	modTbl [64]byte
+	modPos byte
+	modAcc uint16
+	modCnt int32
+	modOff bool
+	modOut int32
+
+	out int32
+}
+
+func newApuChanFds(apu *Apu) *apuChanFds {
+	return &apuChanFds{apu: apu}
+}
+
+func (ch *apuChanFds) reset() {
+	*ch = apuChanFds{apu: ch.apu}
+	ch.volEnv.master, ch.modEnv.master = 0xe8, 0xe8
+	ch.modOff, ch.halt = true, true
+}
+
+func (ch *apuChanFds) serialize(s *stateBuf) {
+	s.bytes(ch.wave[:])
+	s.u8(&ch.wavePos)
+	s.u16(&ch.waveAcc)
+	s.bool(&ch.waveWr)
+	s.bool(&ch.halt)
+	s.bool(&ch.envHalt)
+	s.u8(&ch.vol)
+	ch.volEnv.serialize(s)
+	ch.modEnv.serialize(s)
+	s.bytes(ch.modTbl[:])
+	s.u8(&ch.modPos)
+	s.u16(&ch.modAcc)
+	s.i32(&ch.modCnt)
+	s.bool(&ch.modOff)
+	s.i32(&ch.modOut)
+	s.i32(&ch.out)
+}
+
+func (ch *apuChanFds) setModCnt(v int32) {
+	if v >= 64 {
+		v -= 128
+	} else if v < -64 {
+		v += 128
+	}
+	ch.modCnt = v
+}
+
+func (ch *apuChanFds) writeAsync(addr uint16, data byte) {
+	if addr < 0x4080 {
+		if ch.waveWr {
+			ch.wave[addr&0x3f] = data & 0x3f
+		}
+		return
+	}
+	switch addr {
+	case 0x4080:
+		ch.volEnv.writeCtrl(data)
+	case 0x4082:
+		ch.volEnv.freq = (ch.volEnv.freq & 0x0f00) | int32(data)
+	case 0x4083:
+		ch.volEnv.freq = (ch.volEnv.freq & 0x00ff) | (int32(data&0x0f) << 8)
+		ch.halt, ch.envHalt = data&0x80 != 0, data&0x40 != 0
+		if ch.halt {
+			ch.wavePos, ch.waveAcc = 0, 0
+		}
+		if ch.envHalt {
+			ch.volEnv.resetTimer()
+			ch.modEnv.resetTimer()
+		}
+	case 0x4084:
+		ch.modEnv.writeCtrl(data)
+	case 0x4085:
+		ch.setModCnt(int32(data & 0x7f))
+	case 0x4086:
+		ch.modEnv.freq = (ch.modEnv.freq & 0x0f00) | int32(data)
+	case 0x4087:
+		ch.modEnv.freq = (ch.modEnv.freq & 0x00ff) | (int32(data&0x0f) << 8)
+		ch.modOff = data&0x80 != 0
+		if ch.modOff {
+			ch.modAcc = 0
+		}
+	case 0x4088:
+		if ch.modOff {
+			ch.modTbl[ch.modPos&0x3f] = data & 0x07
+			ch.modTbl[(ch.modPos+1)&0x3f] = data & 0x07
+			ch.modPos = (ch.modPos + 2) & 0x3f
+		}
+	case 0x4089:
+		ch.vol, ch.waveWr = data&0x03, data&0x80 != 0
+	case 0x408a:
+		ch.volEnv.master, ch.modEnv.master = data, data
+	}
+}
+
+// read serves $4040-$407f and the gain ports from the render side state,
+// which lags the cpu by at most a frame.
+func (ch *apuChanFds) read(addr uint16) byte {
+	switch {
+	case addr < 0x4080:
+		return ch.wave[addr&0x3f] | 0x40
+	case addr == 0x4090:
+		return ch.volEnv.gain | 0x40
+	case addr == 0x4092:
+		return ch.modEnv.gain | 0x40
+	}
+	return byte(addr >> 8)
+}
+
+func (ch *apuChanFds) updateModOut() {
+	// pitch bend as described on the nesdev wiki
+	t := ch.modCnt * int32(ch.modEnv.gain)
+	rem := t & 0x0f
+	t >>= 4
+	if rem > 0 && t&0x80 == 0 {
+		if ch.modCnt < 0 {
+			t--
+		} else {
+			t += 2
+		}
+	}
+	if t >= 192 {
+		t -= 256
+	} else if t < -64 {
+		t += 256
+	}
+	t *= ch.volEnv.freq
+	rem = t & 0x3f
+	t >>= 6
+	if rem >= 32 {
+		t++
+	}
+	ch.modOut = t
+}
+
+func (ch *apuChanFds) clock() {
+	if !ch.halt && !ch.envHalt {
+		ch.volEnv.tick()
+		if ch.modEnv.tick() {
+			ch.updateModOut()
+		}
+	}
+	if !ch.modOff && ch.modEnv.freq > 0 {
+		prev := ch.modAcc
+		ch.modAcc += uint16(ch.modEnv.freq)
+		if ch.modAcc < prev {
+			v := ch.modTbl[ch.modPos]
+			if v == 4 {
+				ch.setModCnt(0)
+			} else {
+				ch.setModCnt(ch.modCnt + apuFdsModLut[v])
+			}
+			ch.modPos = (ch.modPos + 1) & 0x3f
+			ch.updateModOut()
+		}
+	}
+
+	if ch.halt {
+		ch.wavePos = 0
+	} else {
+		f := ch.volEnv.freq
+		if !ch.modOff && ch.modEnv.freq > 0 {
+			f += ch.modOut
+		}
+		if f > 0 && !ch.waveWr {
+			prev := ch.waveAcc
+			ch.waveAcc += uint16(f)
+			if ch.waveAcc < prev {
+				ch.wavePos = (ch.wavePos + 1) & 0x3f
+			}
+		}
+	}
+	if !ch.waveWr {
+		g := int32(ch.volEnv.gain)
+		if g > 32 {
+			g = 32
+		}
+		ch.out = int32(ch.wave[ch.wavePos]) * g * apuFdsMasterVol[ch.vol] / 1152
+	}
+}
+
+var apuFdsChanNames = []string{"fds"}
+
+func (ch *apuChanFds) chanNames() []string {
+	return apuFdsChanNames
+}
+
+func (ch *apuChanFds) output(gain []int32) int32 {
+	return ch.out * gain[0] >> 1
+}
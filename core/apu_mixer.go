@@ -0,0 +1,197 @@
+package core
+
+const (
+	AudioChanPulse1 = iota
+	AudioChanPulse2
+	AudioChanTriangle
+	AudioChanNoise
+	AudioChanDmc
+	AudioChanEx // the first expansion channel, if any
+)
+
+const apuMixerMaxGain = 4
+
+// the linear mix weights of the 2a03 channels, in 1/256
+var apuMixWeights = [5]int32{0x00f0, 0x00f0, 0x0130, 0x00c0, 0x00f0}
+
+// apuNonlinearScale brings the output of the nonlinear dac formulas to the
+// level of the linear mix: one pulse step, 95.88/8128 there, is 480 here.
+const apuNonlinearScale = 480 * 8128 / 95.88
+
+// apuMixer holds the user's channel controls; they are not part of the
+// machine state, so survive resets and state loads. gain is what render
+// uses: the set gains in 8.8 fixed point, with mute and solo applied, and
+// gainL and gainR the same panned, for stereo.
+type apuMixer struct {
+	names     []string
+	mute      []bool
+	solo      []bool
+	setGain   []float32
+	pan       []float32
+	gain      []int32
+	gainL     []int32
+	gainR     []int32
+	master    float32
+	nonlinear bool
+}
+
+func (mx *apuMixer) setChans(names []string) {
+	if len(names) == len(mx.names) {
+		mx.names = names
+		return
+	}
+	n := len(names)
+	mx.names = names
+	mx.mute, mx.solo = make([]bool, n), make([]bool, n)
+	mx.setGain, mx.pan = make([]float32, n), make([]float32, n)
+	mx.gain, mx.gainL, mx.gainR = make([]int32, n), make([]int32, n), make([]int32, n)
+	for i := range mx.setGain {
+		mx.setGain[i] = 1
+	}
+	if mx.master == 0 {
+		mx.master = 1
+	}
+	mx.update()
+}
+
+func (mx *apuMixer) update() {
+	bSolo := false
+	for _, s := range mx.solo {
+		bSolo = bSolo || s
+	}
+	for i := range mx.gain {
+		if mx.mute[i] || (bSolo && !mx.solo[i]) {
+			mx.gain[i], mx.gainL[i], mx.gainR[i] = 0, 0, 0
+			continue
+		}
+		// a channel is at full level on both sides at the center, and fades
+		// out on the one opposite to where it is panned
+		g, l, r := mx.setGain[i]*256, 1-mx.pan[i], 1+mx.pan[i]
+		if l > 1 {
+			l = 1
+		}
+		if r > 1 {
+			r = 1
+		}
+		mx.gain[i] = int32(g + 0.5)
+		mx.gainL[i], mx.gainR[i] = int32(g*l+0.5), int32(g*r+0.5)
+	}
+}
+
+func (apu *Apu) chanNames() []string {
+	names := []string{"pulse1", "pulse2", "triangle", "noise", "dmc"}
+	if apu.ex != nil {
+		names = append(names, apu.ex.chanNames()...)
+	}
+	return names
+}
+
+// mixLinear sums the 2a03 channels with the weights scaled by the gains.
+func (apu *Apu) mixLinear(w *[5]int32) int32 {
+	return (apu.ch0.output()*w[0] + apu.ch1.output()*w[1] + apu.ch2.output()*w[2] +
+		apu.ch3.output()*w[3] + apu.ch4.output()*w[4]) >> 8
+}
+
+// mixNonlinear feeds the channel levels, in 1/256 dac steps, to the formulas
+// of the pulse and tnd dacs.
+func (apu *Apu) mixNonlinear(gain []int32) int32 {
+	ch0, ch1 := apu.ch0, apu.ch1
+	p0, p1 := (ch0.output()+ch0.amp())>>1, (ch1.output()+ch1.amp())>>1
+	t, n, d := apu.ch2.level(), apu.ch3.level(), apu.ch4.level()
+
+	var o float64
+	if p := float64(p0*gain[0]+p1*gain[1]) / 65536; p > 0 {
+		o += 95.88 / (8128/p + 100)
+	}
+	tnd := float64(t*gain[2])/8227 + float64(n*gain[3])/12241 + float64(d*gain[4])/22638
+	if tnd /= 65536; tnd > 0 {
+		o += 159.79 / (1/tnd + 100)
+	}
+	return int32(o * apuNonlinearScale)
+}
+
+// GetAudioChanNames lists the mixer channels: the 2a03 ones, indexed by the
+// AudioChan constants, followed by those of the expansion sound if any.
+func (sys *Sys) GetAudioChanNames() []string {
+	return append([]string(nil), sys.apu.mixer.names...)
+}
+
+func (sys *Sys) SetAudioChanMute(i int, mute bool) {
+	mx := &sys.apu.mixer
+	if i >= 0 && i < len(mx.mute) {
+		mx.mute[i] = mute
+		mx.update()
+	}
+}
+
+// SetAudioChanSolo solos a channel; while any channel is soloed, only the
+// soloed ones are heard.
+func (sys *Sys) SetAudioChanSolo(i int, solo bool) {
+	mx := &sys.apu.mixer
+	if i >= 0 && i < len(mx.solo) {
+		mx.solo[i] = solo
+		mx.update()
+	}
+}
+
+// SetAudioChanGain sets the gain of a channel, 1 by default, up to 4.
+func (sys *Sys) SetAudioChanGain(i int, gain float32) {
+	mx := &sys.apu.mixer
+	if i >= 0 && i < len(mx.setGain) {
+		if gain < 0 {
+			gain = 0
+		} else if gain > apuMixerMaxGain {
+			gain = apuMixerMaxGain
+		}
+		mx.setGain[i] = gain
+		mx.update()
+	}
+}
+
+func (sys *Sys) GetAudioChanGain(i int) float32 {
+	mx := &sys.apu.mixer
+	if i < 0 || i >= len(mx.setGain) {
+		return 0
+	}
+	return mx.setGain[i]
+}
+
+// SetAudioChanPan sets where a channel is heard in stereo, from -1, left
+// only, to 1, right only; 0, the center, by default.
+func (sys *Sys) SetAudioChanPan(i int, pan float32) {
+	mx := &sys.apu.mixer
+	if i >= 0 && i < len(mx.pan) {
+		if pan < -1 {
+			pan = -1
+		} else if pan > 1 {
+			pan = 1
+		}
+		mx.pan[i] = pan
+		mx.update()
+	}
+}
+
+func (sys *Sys) GetAudioChanPan(i int) float32 {
+	mx := &sys.apu.mixer
+	if i < 0 || i >= len(mx.pan) {
+		return 0
+	}
+	return mx.pan[i]
+}
+
+func (sys *Sys) SetAudioMasterVolume(vol float32) {
+	if vol < 0 {
+		vol = 0
+	}
+	sys.apu.mixer.master = vol
+}
+
+func (sys *Sys) GetAudioMasterVolume() float32 {
+	return sys.apu.mixer.master
+}
+
+// SetAudioNonlinearMix switches the 2a03 channels from a linear sum to the
+// nonlinear mixing of the real dacs. Expansion sound is always added linearly.
+func (sys *Sys) SetAudioNonlinearMix(b bool) {
+	sys.apu.mixer.nonlinear = b
+}
@@ -0,0 +1,199 @@
+package core
+
+// 8-step sequences of the 2a03 duty cycles, bit i being step i
+var apuMmc5Duty = [4]byte{0x02, 0x06, 0x1e, 0xf9}
+
+const apuMmc5FrameCycle = 7457
+
+// apuMmc5Pulse is a 2a03 pulse without the sweep unit, nor the muting of
+// short periods.
+type apuMmc5Pulse struct {
+	en       bool
+	halt     bool
+	constVol bool
+	vol      byte
+	duty     byte
+	step     byte
+	lenCount byte
+	envStart bool
+	envDiv   byte
+	envVol   byte
+	freq     int32
+	timer    int32
+}
+
+func (p *apuMmc5Pulse) serialize(s *stateBuf) {
+	s.bool(&p.en)
+	s.bool(&p.halt)
+	s.bool(&p.constVol)
+	s.u8(&p.vol)
+	s.u8(&p.duty)
+	s.u8(&p.step)
+	s.u8(&p.lenCount)
+	s.bool(&p.envStart)
+	s.u8(&p.envDiv)
+	s.u8(&p.envVol)
+	s.i32(&p.freq)
+	s.i32(&p.timer)
+}
+
+func (p *apuMmc5Pulse) write(reg uint16, data byte) {
+	switch reg {
+	case 0:
+		p.duty, p.halt, p.constVol, p.vol = data>>6, data&0x20 != 0, data&0x10 != 0, data&0x0f
+	case 2:
+		p.freq = (p.freq & 0x0700) | int32(data)
+	case 3:
+		p.freq = (p.freq & 0x00ff) | (int32(data&0x07) << 8)
+		if p.en {
+			p.lenCount = apuVblLen[data>>3] << 1
+		}
+		p.envStart, p.step = true, 0
+	}
+}
+
+func (p *apuMmc5Pulse) setEn(en bool) {
+	if p.en = en; !en {
+		p.lenCount = 0
+	}
+}
+
+// clockFrame runs the envelope and the length counter, both at 240 Hz.
+func (p *apuMmc5Pulse) clockFrame() {
+	if p.envStart {
+		p.envStart, p.envVol, p.envDiv = false, 15, p.vol
+	} else if p.envDiv != 0 {
+		p.envDiv--
+	} else {
+		p.envDiv = p.vol
+		if p.envVol != 0 {
+			p.envVol--
+		} else if p.halt {
+			p.envVol = 15
+		}
+	}
+	if !p.halt && p.lenCount != 0 {
+		p.lenCount--
+	}
+}
+
+func (p *apuMmc5Pulse) clock() {
+	if p.timer--; p.timer < 0 {
+		p.timer = (p.freq+1)<<1 - 1
+		p.step = (p.step + 1) & 0x07
+	}
+}
+
+func (p *apuMmc5Pulse) output() int32 {
+	if p.lenCount == 0 || apuMmc5Duty[p.duty]&(1<<p.step) == 0 {
+		return 0
+	}
+	if p.constVol {
+		return int32(p.vol)
+	}
+	return int32(p.envVol)
+}
+
+// apuChanMmc5 is the MMC5 sound: two pulses and an 8-bit pcm, with registers
+// at $5000-$5015. Like the 2a03 channels, the pulses are kept twice, the
+// sync copy only to answer the length counter status of $5015.
+type apuChanMmc5 struct {
+	apu *Apu
+
+	p0, p1     apuMmc5Pulse
+	pcm        byte
+	frameTimer int32
+
+	syncP0, syncP1 apuMmc5Pulse
+	syncFrameTimer int32
+}
+
+func newApuChanMmc5(apu *Apu) *apuChanMmc5 {
+	return &apuChanMmc5{apu: apu}
+}
+
+func (ch *apuChanMmc5) reset() {
+	*ch = apuChanMmc5{apu: ch.apu}
+}
+
+func (ch *apuChanMmc5) serialize(s *stateBuf) {
+	ch.p0.serialize(s)
+	ch.p1.serialize(s)
+	s.u8(&ch.pcm)
+	s.i32(&ch.frameTimer)
+	ch.syncP0.serialize(s)
+	ch.syncP1.serialize(s)
+	s.i32(&ch.syncFrameTimer)
+}
+
+func (ch *apuChanMmc5) writeAsync(addr uint16, data byte) {
+	switch addr {
+	case 0x5000, 0x5001, 0x5002, 0x5003:
+		ch.p0.write(addr&0x03, data)
+	case 0x5004, 0x5005, 0x5006, 0x5007:
+		ch.p1.write(addr&0x03, data)
+	case 0x5011:
+		if data != 0 {
+			ch.pcm = data
+		}
+	case 0x5015:
+		ch.p0.setEn(data&0x01 != 0)
+		ch.p1.setEn(data&0x02 != 0)
+	}
+}
+
+// write is the cpu side of a register write.
+func (ch *apuChanMmc5) write(addr uint16, data byte) {
+	switch addr {
+	case 0x5000, 0x5001, 0x5002, 0x5003:
+		ch.syncP0.write(addr&0x03, data)
+	case 0x5004, 0x5005, 0x5006, 0x5007:
+		ch.syncP1.write(addr&0x03, data)
+	case 0x5015:
+		ch.syncP0.setEn(data&0x01 != 0)
+		ch.syncP1.setEn(data&0x02 != 0)
+	}
+	ch.apu.writeEx(addr, data)
+}
+
+func (ch *apuChanMmc5) status() byte {
+	var data byte
+	if ch.syncP0.lenCount != 0 {
+		data |= 0x01
+	}
+	if ch.syncP1.lenCount != 0 {
+		data |= 0x02
+	}
+	return data
+}
+
+func (ch *apuChanMmc5) sync(nCycle int32) {
+	ch.syncFrameTimer -= nCycle
+	for ch.syncFrameTimer <= 0 {
+		ch.syncFrameTimer += apuMmc5FrameCycle
+		ch.syncP0.clockFrame()
+		ch.syncP1.clockFrame()
+	}
+}
+
+func (ch *apuChanMmc5) clock() {
+	if ch.frameTimer--; ch.frameTimer <= 0 {
+		ch.frameTimer = apuMmc5FrameCycle
+		ch.p0.clockFrame()
+		ch.p1.clockFrame()
+	}
+	ch.p0.clock()
+	ch.p1.clock()
+}
+
+// output scales the pulses as the 2a03 ones, and the pcm to the range of
+// the dmc.
+func (ch *apuChanMmc5) output(gain []int32) int32 {
+	return ((ch.p0.output()*gain[0]+ch.p1.output()*gain[1])*240 + int32(ch.pcm)*120*gain[2]) >> 8
+}
+
+var apuMmc5ChanNames = []string{"mmc5 pulse1", "mmc5 pulse2", "mmc5 pcm"}
+
+func (ch *apuChanMmc5) chanNames() []string {
+	return apuMmc5ChanNames
+}
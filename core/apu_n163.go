@@ -0,0 +1,108 @@
+package core
+
+// apuN163Port is the cpu side of the Namco 163 sound ram: $f800 selects an
+// address, optionally auto-incremented, and $4800 reads or writes it. Every
+// write is also queued as $4800+address for the render side copy.
+type apuN163Port struct {
+	ram  [0x80]byte
+	addr byte
+}
+
+func (p *apuN163Port) serialize(s *stateBuf) {
+	s.bytes(p.ram[:])
+	s.u8(&p.addr)
+}
+
+func (p *apuN163Port) step() {
+	if p.addr&0x80 != 0 {
+		p.addr = (p.addr + 1) | 0x80
+	}
+}
+
+func (p *apuN163Port) read() byte {
+	data := p.ram[p.addr&0x7f]
+	p.step()
+	return data
+}
+
+func (p *apuN163Port) write(apu *Apu, data byte) {
+	p.ram[p.addr&0x7f] = data
+	apu.writeEx(0x4800|uint16(p.addr&0x7f), data)
+	p.step()
+}
+
+// apuChanN163 is the Namco 163 wavetable sound. Up to 8 channels keep their
+// registers at the top of the sound ram and play 4-bit samples from it; one
+// channel is updated every 15 cycles, so the more are enabled, the lower
+// each one's rate.
+type apuChanN163 struct {
+	apu *Apu
+
+	ram     [0x80]byte
+	iChan   byte
+	timer   byte
+	outChan byte
+	out     int32
+}
+
+func newApuChanN163(apu *Apu) *apuChanN163 {
+	return &apuChanN163{apu: apu}
+}
+
+// reset keeps the sound ram, which like the cpu side copy survives a reset.
+func (ch *apuChanN163) reset() {
+	*ch = apuChanN163{apu: ch.apu, ram: ch.ram, iChan: 7}
+}
+
+func (ch *apuChanN163) serialize(s *stateBuf) {
+	s.bytes(ch.ram[:])
+	s.u8(&ch.iChan)
+	s.u8(&ch.timer)
+	s.u8(&ch.outChan)
+	s.i32(&ch.out)
+}
+
+func (ch *apuChanN163) writeAsync(addr uint16, data byte) {
+	if addr&0xff80 == 0x4800 {
+		ch.ram[addr&0x7f] = data
+	}
+}
+
+func (ch *apuChanN163) update() {
+	r := ch.ram[0x40+uint16(ch.iChan)<<3:][:8]
+	freq := uint32(r[0]) | uint32(r[2])<<8 | uint32(r[4]&0x03)<<16
+	phase := uint32(r[1]) | uint32(r[3])<<8 | uint32(r[5])<<16
+	length := (256 - uint32(r[4]&0xfc)) << 16
+	phase = (phase + freq) % length
+	r[1], r[3], r[5] = byte(phase), byte(phase>>8), byte(phase>>16)
+
+	i := byte(phase>>16) + r[6]
+	sample := (ch.ram[(i>>1)&0x7f] >> ((i & 0x01) << 2)) & 0x0f
+	ch.out, ch.outChan = (int32(sample)-8)*int32(r[7]&0x0f), ch.iChan
+}
+
+func (ch *apuChanN163) clock() {
+	if ch.timer++; ch.timer < 15 {
+		return
+	}
+	ch.timer = 0
+	ch.update()
+	n := (ch.ram[0x7f] >> 4) & 0x07
+	if ch.iChan--; ch.iChan < 7-n || ch.iChan > 7 {
+		ch.iChan = 7
+	}
+}
+
+var apuN163ChanNames = []string{"n163 1", "n163 2", "n163 3", "n163 4", "n163 5", "n163 6", "n163 7", "n163 8"}
+
+// chanNames lists the channels in the order of their registers; the last one
+// is the first to be enabled.
+func (ch *apuChanN163) chanNames() []string {
+	return apuN163ChanNames
+}
+
+// output is the channel last updated; a single one at full volume comes out
+// as loud as a 2a03 pulse.
+func (ch *apuChanN163) output(gain []int32) int32 {
+	return ch.out * 30 * gain[ch.outChan] >> 8
+}
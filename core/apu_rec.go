@@ -0,0 +1,227 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	AudioRecordWav byte = iota
+	AudioRecordRaw      // headerless 16-bit little endian pcm
+)
+
+type wavHeader struct {
+	Riff     [4]byte
+	Size     uint32
+	Wave     [4]byte
+	Fmt      [4]byte
+	FmtSize  uint32
+	Format   uint16
+	NChan    uint16
+	SampRate uint32
+	ByteRate uint32
+	Align    uint16
+	Bits     uint16
+	Data     [4]byte
+	DataSize uint32
+}
+
+const wavHeaderSize = 44
+
+// apuRecWriter writes 16-bit samples, a frame's worth at once. Sizes in the
+// wav header are patched when the writer can seek, and left at their maximum
+// otherwise, which most readers take as "up to the end of the file".
+type apuRecWriter struct {
+	w        io.Writer
+	format   byte
+	nChan    uint16
+	sampRate uint16
+	nSamp    uint32
+	buf      []byte
+	err      error
+}
+
+func newApuRecWriter(w io.Writer, format byte, nChan uint16, sampRate uint16) *apuRecWriter {
+	rw := &apuRecWriter{w: w, format: format, nChan: nChan, sampRate: sampRate}
+	if format == AudioRecordWav {
+		rw.err = binary.Write(w, binary.LittleEndian, rw.header(math.MaxUint32-wavHeaderSize+8))
+	}
+	return rw
+}
+
+func (rw *apuRecWriter) header(dataSize uint32) *wavHeader {
+	align := rw.nChan * 2
+	return &wavHeader{
+		Riff: [4]byte{'R', 'I', 'F', 'F'}, Size: dataSize + wavHeaderSize - 8,
+		Wave: [4]byte{'W', 'A', 'V', 'E'}, Fmt: [4]byte{'f', 'm', 't', ' '}, FmtSize: 16,
+		Format: 1, NChan: rw.nChan, SampRate: uint32(rw.sampRate), ByteRate: uint32(rw.sampRate) * uint32(align),
+		Align: align, Bits: 16, Data: [4]byte{'d', 'a', 't', 'a'}, DataSize: dataSize,
+	}
+}
+
+func (rw *apuRecWriter) put(d float32) {
+	v := int32(d * 32768)
+	if v > math.MaxInt16 {
+		v = math.MaxInt16
+	} else if v < math.MinInt16 {
+		v = math.MinInt16
+	}
+	rw.buf = append(rw.buf, byte(v), byte(v>>8))
+}
+
+func (rw *apuRecWriter) flush() {
+	if rw.err == nil && len(rw.buf) != 0 {
+		_, rw.err = rw.w.Write(rw.buf)
+		rw.nSamp += uint32(len(rw.buf) >> 1)
+	}
+	rw.buf = rw.buf[:0]
+}
+
+func (rw *apuRecWriter) close() error {
+	rw.flush()
+	ws, ok := rw.w.(io.WriteSeeker)
+	if rw.err != nil || rw.format != AudioRecordWav || !ok {
+		return rw.err
+	}
+	pos, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = ws.Seek(pos-int64(rw.nSamp)*2-wavHeaderSize, io.SeekStart); err != nil {
+		return err
+	}
+	if err = binary.Write(ws, binary.LittleEndian, rw.header(rw.nSamp*2)); err != nil {
+		return err
+	}
+	_, err = ws.Seek(pos, io.SeekStart)
+	return err
+}
+
+// apuRecTrack is a single mixer channel, resampled and filtered on its own.
+type apuRecTrack struct {
+	rw     *apuRecWriter
+	iChan  int
+	gain   []int32 // picks the channel out of the expansion sound
+	out    int32
+	blip   *apuBlip
+	filter *apuOutFilter
+}
+
+// apuRec taps the output of render, and if asked, each channel alone at
+// unity gain, whatever the mixer settings.
+type apuRec struct {
+	mix    *apuRecWriter
+	tracks []*apuRecTrack
+}
+
+func (apu *Apu) chanOutput(tr *apuRecTrack) int32 {
+	switch tr.iChan {
+	case AudioChanPulse1:
+		return apu.ch0.output() * apuMixWeights[0] >> 8
+	case AudioChanPulse2:
+		return apu.ch1.output() * apuMixWeights[1] >> 8
+	case AudioChanTriangle:
+		return apu.ch2.output() * apuMixWeights[2] >> 8
+	case AudioChanNoise:
+		return apu.ch3.output() * apuMixWeights[3] >> 8
+	case AudioChanDmc:
+		return apu.ch4.output() * apuMixWeights[4] >> 8
+	}
+	return apu.ex.output(tr.gain)
+}
+
+// recChans feeds the channel outputs at cycle t of the blip frame.
+func (apu *Apu) recChans(t int32) {
+	for _, tr := range apu.rec.tracks {
+		if o := apu.chanOutput(tr); o != tr.out {
+			tr.blip.addDelta(t, o-tr.out)
+			tr.out = o
+		}
+	}
+}
+
+func (apu *Apu) recEndFrame(nCycle int32) {
+	for _, tr := range apu.rec.tracks {
+		tr.blip.setRatio(float64(apu.blip.factor) / float64(apu.blip.factor0))
+		tr.blip.endFrame(nCycle)
+		tr.blip.read(func(o int32) {
+			tr.rw.put(float32(tr.filter.filter(float64(o)) / 32768))
+		})
+	}
+}
+
+func (apu *Apu) recFlush() {
+	if apu.rec.mix != nil {
+		apu.rec.mix.flush()
+	}
+	for _, tr := range apu.rec.tracks {
+		tr.rw.flush()
+	}
+}
+
+// StartAudioRecord records the audio from the next frame on, in the given
+// format, the mix to w and each channel indexed as in GetAudioChanNames to
+// the matching writer of chanWs. Any of them may be nil. The mix is in
+// stereo if the output is, the single channels always in mono.
+func (sys *Sys) StartAudioRecord(w io.Writer, chanWs []io.Writer, format byte) error {
+	apu := sys.apu
+	if apu.rec != nil {
+		return errors.New("already recording")
+	}
+	if format > AudioRecordRaw {
+		return errors.New("invalid record format")
+	}
+	if len(chanWs) > len(apu.mixer.names) {
+		return errors.New("invalid channel")
+	}
+	rec := &apuRec{}
+	if w != nil {
+		rec.mix = newApuRecWriter(w, format, uint16(apu.dq.nChan), apu.sampRate)
+	}
+	for i, cw := range chanWs {
+		if cw == nil {
+			continue
+		}
+		tr := &apuRecTrack{
+			rw:     newApuRecWriter(cw, format, 1, apu.sampRate),
+			iChan:  i,
+			blip:   newApuBlip(float64(sys.tvFormat.cpuRate), apu.sampRate),
+			filter: newApuOutFilter(apu.sampRate),
+		}
+		if i >= AudioChanEx {
+			tr.gain = make([]int32, len(apu.mixer.names)-AudioChanEx)
+			tr.gain[i-AudioChanEx] = 256
+		}
+		tr.out = apu.chanOutput(tr)
+		tr.blip.addDelta(0, tr.out)
+		rec.tracks = append(rec.tracks, tr)
+	}
+	apu.rec = rec
+	return nil
+}
+
+// StopAudioRecord ends the recording at the end of the last frame run, and
+// returns the first error met writing.
+func (sys *Sys) StopAudioRecord() error {
+	apu := sys.apu
+	if apu.rec == nil {
+		return errors.New("not recording")
+	}
+	var err error
+	if apu.rec.mix != nil {
+		err = apu.rec.mix.close()
+	}
+	for _, tr := range apu.rec.tracks {
+		if e := tr.rw.close(); err == nil {
+			err = e
+		}
+	}
+	apu.rec = nil
+	return err
+}
+
+func (sys *Sys) IsAudioRecording() bool {
+	return sys.apu.rec != nil
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+// seekBuf is an in-memory io.WriteSeeker.
+type seekBuf struct {
+	b   []byte
+	pos int
+}
+
+func (sb *seekBuf) Write(p []byte) (int, error) {
+	if n := sb.pos + len(p); n > len(sb.b) {
+		sb.b = append(sb.b, make([]byte, n-len(sb.b))...)
+	}
+	sb.pos += copy(sb.b[sb.pos:], p)
+	return len(p), nil
+}
+
+func (sb *seekBuf) Seek(ofs int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		ofs += int64(sb.pos)
+	case io.SeekEnd:
+		ofs += int64(len(sb.b))
+	}
+	if ofs < 0 {
+		return 0, errors.New("negative position")
+	}
+	sb.pos = int(ofs)
+	return ofs, nil
+}
+
+func TestApuRecWav(t *testing.T) {
+	tests := []struct {
+		name     string
+		nChan    uint16
+		sampRate uint16
+		seek     bool
+		samps    []float32
+		pcm      []int16
+		dataSize uint32
+	}{
+		{"mono", 1, 44100, true, []float32{0, 0.5, -1, 2}, []int16{0, 16384, -32768, 32767}, 8},
+		{"stereo", 2, 48000, true, []float32{0.25, -0.25}, []int16{8192, -8192}, 4},
+		{"empty", 2, 22050, true, nil, []int16{}, 0},
+		{"no seek", 1, 44100, false, []float32{0.5}, []int16{16384}, math.MaxUint32 - wavHeaderSize + 8},
+	}
+	for _, tt := range tests {
+		var w io.Writer
+		sb, buf := &seekBuf{}, &bytes.Buffer{}
+		if tt.seek {
+			w = sb
+		} else {
+			w = buf
+		}
+		rw := newApuRecWriter(w, AudioRecordWav, tt.nChan, tt.sampRate)
+		for _, d := range tt.samps {
+			rw.put(d)
+		}
+		if err := rw.close(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		b := buf.Bytes()
+		if tt.seek {
+			b = sb.b
+		}
+		if n := len(b); n != wavHeaderSize+len(tt.samps)*2 {
+			t.Fatalf("%s: %d bytes written", tt.name, n)
+		}
+		h := &wavHeader{}
+		binary.Read(bytes.NewReader(b), binary.LittleEndian, h)
+		want := wavHeader{
+			Riff: [4]byte{'R', 'I', 'F', 'F'}, Size: tt.dataSize + 36,
+			Wave: [4]byte{'W', 'A', 'V', 'E'}, Fmt: [4]byte{'f', 'm', 't', ' '}, FmtSize: 16,
+			Format: 1, NChan: tt.nChan, SampRate: uint32(tt.sampRate),
+			ByteRate: uint32(tt.sampRate) * uint32(tt.nChan) * 2, Align: tt.nChan * 2, Bits: 16,
+			Data: [4]byte{'d', 'a', 't', 'a'}, DataSize: tt.dataSize,
+		}
+		if *h != want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *h, want)
+		}
+		pcm := make([]int16, len(tt.pcm))
+		binary.Read(bytes.NewReader(b[wavHeaderSize:]), binary.LittleEndian, pcm)
+		if !equalInt16s(pcm, tt.pcm) {
+			t.Errorf("%s: samples %v, want %v", tt.name, pcm, tt.pcm)
+		}
+	}
+}
+
+func equalInt16s(a, b []int16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
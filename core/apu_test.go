@@ -0,0 +1,90 @@
+package core
+
+import "testing"
+
+func TestApuDataQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		nChan   uint32
+		start   uint32 // where rp and wp start
+		in      []float32
+		nBuf    int
+		n       int
+		out     []float32
+		nUnder  uint32
+		nOver   uint32
+		enqFull bool // enqueue until full before in
+	}{
+		{"mono wrap", 1, apuDataQueueLen - 2, []float32{1, 2, 3, 4}, 4, 4, []float32{1, 2, 3, 4}, 0, 0, false},
+		{"stereo wrap", 2, apuDataQueueLen - 1, []float32{1, 2, 3, 4}, 4, 2, []float32{1, 2, 3, 4}, 0, 0, false},
+		{"counter wrap", 1, 0xfffffffe, []float32{1, 2, 3}, 3, 3, []float32{1, 2, 3}, 0, 0, false},
+		{"mono pad", 1, 0, []float32{1, 2}, 4, 2, []float32{1, 2, 2, 2}, 1, 0, false},
+		{"stereo pad", 2, 7, []float32{1, 2}, 6, 1, []float32{1, 2, 1, 2, 1, 2}, 1, 0, false},
+		{"mono full", 1, 0, []float32{9}, 1, 1, []float32{0}, 0, 1, true},
+		{"stereo full", 2, 0, []float32{8, 9}, 2, 1, []float32{0, 0}, 0, 1, true},
+	}
+	for _, tt := range tests {
+		q := &ApuDataQueue{nChan: tt.nChan, rp: tt.start, wp: tt.start}
+		if tt.enqFull {
+			for q.Len() < q.Cap() {
+				if tt.nChan == 2 {
+					q.enqueueStereo(0, 0)
+				} else {
+					q.enqueue(0)
+				}
+			}
+		}
+		for i := 0; i < len(tt.in); i += int(tt.nChan) {
+			if tt.nChan == 2 {
+				q.enqueueStereo(tt.in[i], tt.in[i+1])
+			} else {
+				q.enqueue(tt.in[i])
+			}
+		}
+		buf := make([]float32, tt.nBuf)
+		n := q.Dequeue(buf)
+		if n != tt.n {
+			t.Errorf("%s: dequeued %d frames, want %d", tt.name, n, tt.n)
+		}
+		for i := range buf {
+			if buf[i] != tt.out[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, buf, tt.out)
+				break
+			}
+		}
+		if q.Underruns() != tt.nUnder || q.Overruns() != tt.nOver {
+			t.Errorf("%s: %d underruns, %d overruns", tt.name, q.Underruns(), q.Overruns())
+		}
+	}
+
+	q := &ApuDataQueue{nChan: 1}
+	q.enqueue(1)
+	q.reset()
+	if n := q.Dequeue(make([]float32, 1)); n != 0 {
+		t.Errorf("dequeued %d frames after a reset", n)
+	}
+}
+
+func TestApuRateCtl(t *testing.T) {
+	tests := []struct {
+		fill  int
+		ratio float64
+	}{
+		{1000, 1},
+		{1500, 1 - apuRateCtlMaxDelta/2},
+		{500, 1 + apuRateCtlMaxDelta/2},
+		{0, 1 + apuRateCtlMaxDelta},
+		{5000, 1 - apuRateCtlMaxDelta},
+	}
+	sys := newTestRomSys(t, testRom(0, testProg))
+	apu := sys.apu
+	apu.targetFill = 1000
+	for _, tt := range tests {
+		apu.dq.rp, apu.dq.wp = 0, uint32(tt.fill)*apu.dq.nChan
+		apu.rateCtl()
+		want := uint64(float64(apu.blip.factor0)*tt.ratio + 0.5)
+		if apu.blip.factor != want {
+			t.Errorf("fill %d: factor %d, want %d", tt.fill, apu.blip.factor, want)
+		}
+	}
+}
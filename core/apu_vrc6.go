@@ -0,0 +1,155 @@
+package core
+
+type apuVrc6Pulse struct {
+	en    bool
+	mode  bool
+	duty  byte
+	vol   byte
+	step  byte
+	freq  int32
+	timer int32
+}
+
+func (p *apuVrc6Pulse) serialize(s *stateBuf) {
+	s.bool(&p.en)
+	s.bool(&p.mode)
+	s.u8(&p.duty)
+	s.u8(&p.vol)
+	s.u8(&p.step)
+	s.i32(&p.freq)
+	s.i32(&p.timer)
+}
+
+func (p *apuVrc6Pulse) write(reg uint16, data byte) {
+	switch reg {
+	case 0:
+		p.mode, p.duty, p.vol = data&0x80 != 0, (data>>4)&0x07, data&0x0f
+	case 1:
+		p.freq = (p.freq & 0x0f00) | int32(data)
+	case 2:
+		p.freq = (p.freq & 0x00ff) | (int32(data&0x0f) << 8)
+		if p.en = data&0x80 != 0; !p.en {
+			p.step = 15
+		}
+	}
+}
+
+func (p *apuVrc6Pulse) clock(shift byte) {
+	if !p.en {
+		return
+	}
+	if p.timer--; p.timer < 0 {
+		p.timer = p.freq >> shift
+		p.step = (p.step - 1) & 0x0f
+	}
+}
+
+func (p *apuVrc6Pulse) output() int32 {
+	if !p.en || (!p.mode && p.step > p.duty) {
+		return 0
+	}
+	return int32(p.vol)
+}
+
+// apuChanVrc6 is the Konami VRC6 sound: two pulses with 8 duty steps and a
+// sawtooth, addressed as on mapper 24 at $9000-$b002.
+type apuChanVrc6 struct {
+	apu *Apu
+
+	p0, p1 apuVrc6Pulse
+
+	sawEn    bool
+	sawRate  byte
+	sawAcc   byte
+	sawStep  byte
+	sawFreq  int32
+	sawTimer int32
+
+	halt  bool
+	shift byte
+}
+
+func newApuChanVrc6(apu *Apu) *apuChanVrc6 {
+	return &apuChanVrc6{apu: apu}
+}
+
+func (ch *apuChanVrc6) reset() {
+	*ch = apuChanVrc6{apu: ch.apu}
+}
+
+func (ch *apuChanVrc6) serialize(s *stateBuf) {
+	ch.p0.serialize(s)
+	ch.p1.serialize(s)
+	s.bool(&ch.sawEn)
+	s.u8(&ch.sawRate)
+	s.u8(&ch.sawAcc)
+	s.u8(&ch.sawStep)
+	s.i32(&ch.sawFreq)
+	s.i32(&ch.sawTimer)
+	s.bool(&ch.halt)
+	s.u8(&ch.shift)
+}
+
+func (ch *apuChanVrc6) writeAsync(addr uint16, data byte) {
+	switch addr {
+	case 0x9000, 0x9001, 0x9002:
+		ch.p0.write(addr&0x03, data)
+	case 0x9003:
+		ch.halt = data&0x01 != 0
+		switch {
+		case data&0x04 != 0:
+			ch.shift = 8
+		case data&0x02 != 0:
+			ch.shift = 4
+		default:
+			ch.shift = 0
+		}
+	case 0xa000, 0xa001, 0xa002:
+		ch.p1.write(addr&0x03, data)
+	case 0xb000:
+		ch.sawRate = data & 0x3f
+	case 0xb001:
+		ch.sawFreq = (ch.sawFreq & 0x0f00) | int32(data)
+	case 0xb002:
+		ch.sawFreq = (ch.sawFreq & 0x00ff) | (int32(data&0x0f) << 8)
+		if ch.sawEn = data&0x80 != 0; !ch.sawEn {
+			ch.sawAcc, ch.sawStep = 0, 0
+		}
+	}
+}
+
+func (ch *apuChanVrc6) clock() {
+	if ch.halt {
+		return
+	}
+	ch.p0.clock(ch.shift)
+	ch.p1.clock(ch.shift)
+	if !ch.sawEn {
+		return
+	}
+	if ch.sawTimer--; ch.sawTimer < 0 {
+		ch.sawTimer = ch.sawFreq >> ch.shift
+		// the accumulator grows on every other step and is cleared on
+		// the 14th
+		if ch.sawStep++; ch.sawStep >= 14 {
+			ch.sawAcc, ch.sawStep = 0, 0
+		} else if ch.sawStep&0x01 == 0 {
+			ch.sawAcc += ch.sawRate
+		}
+	}
+}
+
+// output scales a pulse step to match a 2a03 pulse of the same volume.
+func (ch *apuChanVrc6) output(gain []int32) int32 {
+	o := ch.p0.output()*gain[0] + ch.p1.output()*gain[1]
+	if ch.sawEn {
+		o += int32(ch.sawAcc>>3) * gain[2]
+	}
+	return o * 480 >> 8
+}
+
+var apuVrc6ChanNames = []string{"vrc6 pulse1", "vrc6 pulse2", "vrc6 saw"}
+
+func (ch *apuChanVrc6) chanNames() []string {
+	return apuVrc6ChanNames
+}
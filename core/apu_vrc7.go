@@ -0,0 +1,329 @@
+package core
+
+import "math"
+
+// apuVrc7Patches is the built-in instrument set of the VRC7, which differs
+// from the one of the ym2413 it derives from. Instrument 0 is the custom one.
+var apuVrc7Patches = [16][8]byte{
+	{},
+	{0x03, 0x21, 0x05, 0x06, 0xe8, 0x81, 0x42, 0x27},
+	{0x13, 0x41, 0x14, 0x0d, 0xd8, 0xf6, 0x23, 0x12},
+	{0x11, 0x11, 0x08, 0x08, 0xfa, 0xb2, 0x20, 0x12},
+	{0x31, 0x61, 0x0c, 0x07, 0xa8, 0x64, 0x61, 0x27},
+	{0x32, 0x21, 0x1e, 0x06, 0xe1, 0x76, 0x01, 0x28},
+	{0x02, 0x01, 0x06, 0x00, 0xa3, 0xe2, 0xf4, 0xf4},
+	{0x21, 0x61, 0x1d, 0x07, 0x82, 0x81, 0x11, 0x07},
+	{0x23, 0x21, 0x22, 0x17, 0xa2, 0x72, 0x01, 0x17},
+	{0x35, 0x11, 0x25, 0x00, 0x40, 0x73, 0x72, 0x01},
+	{0xb5, 0x01, 0x0f, 0x0f, 0xa8, 0xa5, 0x51, 0x02},
+	{0x17, 0xc1, 0x24, 0x07, 0xf8, 0xf8, 0x22, 0x12},
+	{0x71, 0x23, 0x11, 0x06, 0x65, 0x74, 0x18, 0x16},
+	{0x01, 0x02, 0xd3, 0x05, 0xc9, 0x95, 0x03, 0x02},
+	{0x61, 0x63, 0x0c, 0x00, 0x94, 0xc0, 0x33, 0xf6},
+	{0x21, 0x72, 0x0d, 0x00, 0xc1, 0xd5, 0x56, 0x06},
+}
+
+// multipliers doubled, to keep the 1/2 of the first one integral
+var apuVrc7Mult = [16]uint32{1, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 20, 24, 24, 30, 30}
+
+// key scale levels in 1/8 dB by the top 4 bits of fnum, at block 7
+var apuVrc7Ksl = [16]int32{0, 72, 96, 111, 120, 129, 135, 141, 144, 150, 153, 156, 159, 162, 165, 168}
+
+var apuVrc7Pm = [8]int32{0, 1, 2, 1, 0, -1, -2, -1}
+
+var apuVrc7EgInc = [4][8]int32{
+	{0, 1, 0, 1, 0, 1, 0, 1},
+	{0, 1, 0, 1, 1, 1, 0, 1},
+	{0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 1, 1, 1, 1, 1, 1, 1},
+}
+
+// apuVrc7LogSin is -log2(sin) of a quarter wave and apuVrc7Exp the inverse,
+// both with 256 steps per octave, as in the real chip.
+var apuVrc7LogSin, apuVrc7Exp = func() (ls [256]int32, ex [256]int32) {
+	for i := 0; i < 256; i++ {
+		ls[i] = int32(-math.Log2(math.Sin((float64(i)+0.5)*math.Pi/512.0))*256.0 + 0.5)
+		ex[i] = int32(math.Pow(2, -float64(i)/256.0)*4095.0 + 0.5)
+	}
+	return
+}()
+
+const (
+	apuVrc7EgAttack byte = iota
+	apuVrc7EgDecay
+	apuVrc7EgSustain
+	apuVrc7EgRelease
+	apuVrc7EgOff
+
+	apuVrc7EgMax     = 127
+	apuVrc7RegBase   = 0x9100
+	apuVrc7SampCycle = 36
+)
+
+type apuVrc7Op struct {
+	phase   uint32
+	egState byte
+	egLevel int32
+	out     int32
+	prevOut int32
+}
+
+func (op *apuVrc7Op) serialize(s *stateBuf) {
+	s.u32(&op.phase)
+	s.u8(&op.egState)
+	s.i32(&op.egLevel)
+	s.i32(&op.out)
+	s.i32(&op.prevOut)
+}
+
+// apuChanVrc7 is the six channel, two operator fm synth of the VRC7, run at
+// its own 49.7 kHz rate (one sample per 36 cpu cycles). Registers are
+// written as $9100+index.
+type apuChanVrc7 struct {
+	apu *Apu
+
+	reg   [0x40]byte
+	ops   [12]apuVrc7Op
+	egCnt uint32
+	amCnt uint32
+	pmCnt uint32
+	out   [6]int32
+	timer int32
+}
+
+func newApuChanVrc7(apu *Apu) *apuChanVrc7 {
+	return &apuChanVrc7{apu: apu}
+}
+
+func (ch *apuChanVrc7) reset() {
+	*ch = apuChanVrc7{apu: ch.apu}
+	for i := range ch.ops {
+		ch.ops[i].egState, ch.ops[i].egLevel = apuVrc7EgOff, apuVrc7EgMax
+	}
+}
+
+func (ch *apuChanVrc7) serialize(s *stateBuf) {
+	s.bytes(ch.reg[:])
+	for i := range ch.ops {
+		ch.ops[i].serialize(s)
+	}
+	s.u32(&ch.egCnt)
+	s.u32(&ch.amCnt)
+	s.u32(&ch.pmCnt)
+	for i := range ch.out {
+		s.i32(&ch.out[i])
+	}
+	s.i32(&ch.timer)
+}
+
+func (ch *apuChanVrc7) writeAsync(addr uint16, data byte) {
+	if addr&0xffc0 != apuVrc7RegBase {
+		return
+	}
+	r := byte(addr & 0x3f)
+	if r >= 0x20 && r <= 0x25 {
+		i := r & 0x07
+		on, wasOn := data&0x10 != 0, ch.reg[r]&0x10 != 0
+		if on && !wasOn {
+			for j := i << 1; j < i<<1+2; j++ {
+				ch.ops[j].egState, ch.ops[j].phase = apuVrc7EgAttack, 0
+			}
+		} else if !on && wasOn {
+			ch.ops[i<<1].egState = apuVrc7EgRelease
+			ch.ops[i<<1|1].egState = apuVrc7EgRelease
+		}
+	}
+	ch.reg[r] = data
+}
+
+func (ch *apuChanVrc7) patch(i byte) []byte {
+	if n := ch.reg[0x30+i] >> 4; n != 0 {
+		return apuVrc7Patches[n][:]
+	}
+	return ch.reg[:8]
+}
+
+// egStep returns the level increment of an envelope rate at the current
+// envelope counter.
+func (ch *apuChanVrc7) egStep(rate int32) int32 {
+	if rate <= 0 {
+		return 0
+	}
+	if rate > 63 {
+		rate = 63
+	}
+	rm, rl := uint32(rate>>2), rate&0x03
+	if rm < 13 {
+		shift := 13 - rm
+		if ch.egCnt&(1<<shift-1) != 0 {
+			return 0
+		}
+		return apuVrc7EgInc[rl][(ch.egCnt>>shift)&0x07]
+	}
+	return apuVrc7EgInc[rl][ch.egCnt&0x07] << (rm - 12)
+}
+
+func (ch *apuChanVrc7) updateEg(op *apuVrc7Op, p []byte, car uint32, rks int32, sus bool) {
+	ar, dr := int32(p[4+car]>>4), int32(p[4+car]&0x0f)
+	sl, rr := int32(p[6+car]>>4), int32(p[6+car]&0x0f)
+	sustained := p[car]&0x20 != 0
+	switch op.egState {
+	case apuVrc7EgAttack:
+		if ar == 15 {
+			op.egLevel = 0
+		} else if ar != 0 {
+			if inc := ch.egStep(ar<<2 + rks); inc != 0 {
+				op.egLevel -= (op.egLevel*inc)>>3 + 1
+			}
+		}
+		if op.egLevel <= 0 {
+			op.egLevel, op.egState = 0, apuVrc7EgDecay
+		}
+	case apuVrc7EgDecay:
+		if dr != 0 {
+			op.egLevel += ch.egStep(dr<<2 + rks)
+		}
+		if op.egLevel >= sl<<3 {
+			op.egState = apuVrc7EgSustain
+		}
+	case apuVrc7EgSustain:
+		// percussive instruments keep on decaying at the release rate
+		if !sustained && rr != 0 {
+			op.egLevel += ch.egStep(rr<<2 + rks)
+		}
+	case apuVrc7EgRelease:
+		r := int32(7)
+		switch {
+		case sus:
+			r = 5
+		case sustained:
+			r = rr
+		}
+		if r != 0 {
+			op.egLevel += ch.egStep(r<<2 + rks)
+		}
+	}
+	if op.egLevel >= apuVrc7EgMax {
+		op.egLevel = apuVrc7EgMax
+		if op.egState == apuVrc7EgRelease {
+			op.egState = apuVrc7EgOff
+		}
+	}
+}
+
+// opOut turns a 10-bit phase and an attenuation in 1/256 octave into a
+// signed 12-bit sample.
+func apuVrc7OpOut(phase uint32, att int32, rectify bool) int32 {
+	neg := phase&0x200 != 0
+	if neg && rectify {
+		return 0
+	}
+	i := phase & 0xff
+	if phase&0x100 != 0 {
+		i = 0xff - i
+	}
+	att += apuVrc7LogSin[i]
+	if att >= 12<<8 {
+		return 0
+	}
+	o := apuVrc7Exp[att&0xff] >> uint(att>>8)
+	if neg {
+		return -o
+	}
+	return o
+}
+
+func (ch *apuChanVrc7) sample() {
+	ch.egCnt++
+	ch.amCnt++
+	ch.pmCnt++
+	// tremolo is a 3.7 Hz triangle up to 4.875 dB, vibrato a 6.1 Hz one
+	am := int32((ch.amCnt >> 9) % 26)
+	if am > 13 {
+		am = 26 - am
+	}
+	pmStep := (ch.pmCnt >> 10) & 0x07
+
+	for i := byte(0); i < 6; i++ {
+		p := ch.patch(i)
+		fnum := uint32(ch.reg[0x10+i]) | uint32(ch.reg[0x20+i]&0x01)<<8
+		block := uint32(ch.reg[0x20+i]>>1) & 0x07
+		sus := ch.reg[0x20+i]&0x20 != 0
+
+		// key scale level, in envelope steps of 0.375 dB
+		ksl := apuVrc7Ksl[fnum>>5] - 24*int32(7-block)
+		if ksl < 0 {
+			ksl = 0
+		}
+		ksl = ksl / 3
+
+		var modOut int32
+		for car := uint32(0); car < 2; car++ {
+			op := &ch.ops[i<<1|byte(car)]
+			f := fnum
+			if p[car]&0x40 != 0 {
+				f = uint32(int32(fnum) + int32(fnum>>6)*apuVrc7Pm[pmStep]/2)
+			}
+			op.phase = (op.phase + (f*apuVrc7Mult[p[car]&0x0f]<<block)>>2) & 0x3ffff
+
+			rks := int32(block<<1 | fnum>>8)
+			if p[car]&0x10 == 0 {
+				rks >>= 2
+			}
+			ch.updateEg(op, p, car, rks, sus)
+
+			att := op.egLevel
+			if k := p[2+car] >> 6; k != 0 {
+				att += ksl >> (3 - k)
+			}
+			if p[car]&0x80 != 0 {
+				att += am
+			}
+			phase := op.phase >> 8
+			if car == 0 {
+				att += int32(p[2]&0x3f) << 1
+				if fb := p[3] & 0x07; fb != 0 {
+					phase += uint32((op.out + op.prevOut) >> (9 - fb))
+				}
+			} else {
+				att += int32(ch.reg[0x30+i]&0x0f) << 3
+				phase += uint32(modOut)
+			}
+			if op.egState == apuVrc7EgOff {
+				att = apuVrc7EgMax
+			}
+			if att > apuVrc7EgMax {
+				att = apuVrc7EgMax
+			}
+			out := apuVrc7OpOut(phase&0x3ff, att<<4, p[3]&(0x08<<car) != 0)
+			op.prevOut, op.out = op.out, out
+			if car == 0 {
+				modOut = out >> 1
+			} else {
+				ch.out[i] = out
+			}
+		}
+	}
+}
+
+var apuVrc7ChanNames = []string{"vrc7 1", "vrc7 2", "vrc7 3", "vrc7 4", "vrc7 5", "vrc7 6"}
+
+func (ch *apuChanVrc7) chanNames() []string {
+	return apuVrc7ChanNames
+}
+
+func (ch *apuChanVrc7) clock() {
+	if ch.timer--; ch.timer <= 0 {
+		ch.timer = apuVrc7SampCycle
+		ch.sample()
+	}
+}
+
+// output brings six carriers at full level somewhat below the 2a03 at full.
+func (ch *apuChanVrc7) output(gain []int32) int32 {
+	var o int32
+	for i, out := range ch.out {
+		o += out * gain[i]
+	}
+	return o * 7 >> 11
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// batteryRam returns a copy of the battery ram: the save ram of the mapper,
+// then the ram of its chips kept with it. It is nil if there is no save ram.
+func (sys *Sys) batteryRam() []byte {
+	ram := sys.mapper.saveRam()
+	if len(ram) == 0 {
+		return nil
+	}
+	return append(append([]byte(nil), ram...), sys.mapper.saveRamEx()...)
+}
+
+func (sys *Sys) HasBatteryRam() bool {
+	return len(sys.mapper.saveRam()) != 0
+}
+
+func (sys *Sys) IsBatteryRamDirty() bool {
+	return !bytes.Equal(sys.batteryRam(), sys.saveRamSnap)
+}
+
+func (sys *Sys) LoadBatteryRam(r io.Reader) error {
+	ram, ex := sys.mapper.saveRam(), sys.mapper.saveRamEx()
+	if len(ram) == 0 {
+		return errors.New("no battery ram")
+	}
+	buf := make([]byte, len(ram)+len(ex))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	copy(ex, buf[copy(ram, buf):])
+	sys.saveRamSnap = buf
+	return nil
+}
+
+func (sys *Sys) SaveBatteryRam(w io.Writer) error {
+	snap := sys.batteryRam()
+	if len(snap) == 0 {
+		return errors.New("no battery ram")
+	}
+	if _, err := w.Write(snap); err != nil {
+		return err
+	}
+	sys.saveRamSnap = snap
+	return nil
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBatteryRamN163(t *testing.T) {
+	rom := testRom(19, testProg)
+	rom[6] |= 0x02
+	sys0 := newTestRomSys(t, rom)
+	if !sys0.HasBatteryRam() || sys0.IsBatteryRamDirty() {
+		t.Fatal("no clean battery ram")
+	}
+	sys0.write(0x6000, 0x5a)
+	sys0.write(0xf800, 0x80)
+	for i := 0; i < 0x80; i++ {
+		sys0.write(0x4800, byte(i)^0xa5)
+	}
+	if !sys0.IsBatteryRamDirty() {
+		t.Error("battery ram not dirty")
+	}
+	var buf bytes.Buffer
+	if err := sys0.SaveBatteryRam(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := buf.Len(); n != 0x2000+0x80 {
+		t.Fatalf("saved %#x bytes", n)
+	}
+
+	sys1 := newTestRomSys(t, rom)
+	if err := sys1.LoadBatteryRam(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if sys1.IsBatteryRamDirty() {
+		t.Error("battery ram dirty after load")
+	}
+	if b := sys1.read(0x6000); b != 0x5a {
+		t.Errorf("read %#x from $6000", b)
+	}
+	sys1.write(0xf800, 0x80)
+	for i := 0; i < 0x80; i++ {
+		if b := sys1.read(0x4800); b != byte(i)^0xa5 {
+			t.Fatalf("read %#x from the sound ram at %#x", b, i)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package core
+
+// The conformance tests run the community test roms, which are not part of
+// the repository: they are looked for in the directory named by the
+// GOFC_TEST_ROMS environment variable, and skipped if it is not set.
+//
+//	GOFC_TEST_ROMS=~/nes-test-roms go test ./core -run Conformance -v
+//
+// nestest.nes is run from its automation entry at $C000, its log compared
+// with nestest.log if beside it. Any other rom under a directory or file named
+// after one of blarggTestSuites is run as a test of blargg, its result read
+// from $6000, or from the screen for the older ones. GOFC_TEST_DOT=1 runs
+// them in the dot render mode.
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var blarggTestSuites = []string{"instr_test", "cpu_timing_test", "ppu_vbl_nmi", "apu_test", "mmc3_test"}
+
+const (
+	// the status at $6000, else the result code, 0 for a pass
+	blarggStatusRunning = 0x80
+	blarggStatusReset   = 0x81
+
+	blarggMaxFrame   = 60 * 60
+	blarggResetFrame = 10 // the reset is to wait at least 100 ms
+)
+
+var blarggSignature = []byte{0xde, 0xb0, 0x61}
+
+func testRomDir(t *testing.T) string {
+	dir := os.Getenv("GOFC_TEST_ROMS")
+	if len(dir) == 0 {
+		t.Skip("GOFC_TEST_ROMS not set")
+	}
+	return dir
+}
+
+func newTestSys(t *testing.T, p string) *Sys {
+	rom, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &Conf{RenderMode: RenderModeAuto, AudioSampRate: 44100}
+	if os.Getenv("GOFC_TEST_DOT") == "1" {
+		conf.RenderMode = RenderModeDot
+	}
+	sys, err := NewSys(bytes.NewReader(rom), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys.SetFrameBuffer(&FrameBuffer{})
+	return sys
+}
+
+// the end of nestest run from $C000, and a loop in ram to park the cpu in
+// after it
+const (
+	nestestEnd  = 0xc66e
+	nestestPark = 0x0700
+)
+
+func TestConformanceNestest(t *testing.T) {
+	dir := testRomDir(t)
+	p := filepath.Join(dir, "nestest.nes")
+	if _, err := os.Stat(p); err != nil {
+		t.Skip("nestest.nes not found")
+	}
+	sys := newTestSys(t, p)
+	cpu := sys.cpu
+	// as at the start of nestest.log
+	cpu.regPC, cpu.regS, cpu.regP, cpu.nCycle = 0xc000, 0xfd, 0x24, 7
+	var trace bytes.Buffer
+	if err := sys.StartTrace(&trace, nil); err != nil {
+		t.Fatal(err)
+	}
+	done := false
+	d := sys.AttachDebugger(func(d *Debugger, ev *BreakEvent) {
+		// the last instruction is traced too
+		if ev.Reason == BreakReasonBreakpoint {
+			d.StepInto()
+			return
+		}
+		done = true
+		sys.StopTrace()
+		sys.DetachDebugger()
+		copy(cpu.ram[nestestPark:], []byte{0x4c, nestestPark & 0xff, nestestPark >> 8})
+		cpu.regPC = nestestPark
+	})
+	d.AddBreakpoint(Breakpoint{Typ: BreakExec, Addr: nestestEnd})
+	for i := 0; i < 10 && !done; i++ {
+		sys.RunFrame()
+	}
+	if !done {
+		t.Fatal("not ended")
+	}
+
+	if log, err := ioutil.ReadFile(filepath.Join(dir, "nestest.log")); err == nil {
+		compareNestestLog(t, log, trace.Bytes())
+	}
+	if r0, r1 := cpu.ram[0x02], cpu.ram[0x03]; r0 != 0 || r1 != 0 {
+		t.Errorf("failed: official %02x, unofficial %02x", r0, r1)
+	}
+}
+
+// compareNestestLog compares the pc, the instruction bytes and the registers,
+// the rest differing with the ppu timing and the peeks at registers.
+func compareNestestLog(t *testing.T, log, trace []byte) {
+	key := func(line string) string {
+		i := strings.Index(line, "A:")
+		if len(line) < 15 || i < 0 || len(line) < i+25 {
+			return line
+		}
+		return line[:15] + line[i:i+25]
+	}
+	sc0, sc1 := bufio.NewScanner(bytes.NewReader(log)), bufio.NewScanner(bytes.NewReader(trace))
+	for n := 1; sc0.Scan(); n++ {
+		if !sc1.Scan() {
+			t.Errorf("log line %d: trace ended", n)
+			return
+		}
+		if l0, l1 := sc0.Text(), sc1.Text(); key(l0) != key(l1) {
+			t.Errorf("log line %d:\nwant %s\ngot  %s", n, l0, l1)
+			return
+		}
+	}
+}
+
+func isBlarggTest(rel string) bool {
+	for _, s := range strings.Split(filepath.ToSlash(rel), "/") {
+		for _, suite := range blarggTestSuites {
+			if strings.HasPrefix(s, suite) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestConformanceBlargg(t *testing.T) {
+	dir := testRomDir(t)
+	var roms []string
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel, _ := filepath.Rel(dir, p); !fi.IsDir() && strings.EqualFold(filepath.Ext(p), ".nes") && isBlarggTest(rel) {
+			roms = append(roms, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roms) == 0 {
+		t.Skip("no test roms found")
+	}
+	for _, rel := range roms {
+		p := filepath.Join(dir, rel)
+		t.Run(filepath.ToSlash(rel), func(t *testing.T) {
+			t.Parallel()
+			runBlarggTest(t, newTestSys(t, p))
+		})
+	}
+}
+
+func runBlarggTest(t *testing.T, sys *Sys) {
+	iReset := -1
+	for i := 0; i < blarggMaxFrame; i++ {
+		sys.RunFrame()
+		if i == iReset {
+			sys.Reset()
+		}
+		if !blarggHasSignature(sys) {
+			// the older roms only print the result
+			if res := blarggScreenResult(sys); len(res) != 0 {
+				if res != "passed" {
+					t.Error(res)
+				}
+				return
+			}
+			continue
+		}
+		switch st := sys.PeekCpu(0x6000); {
+		case st == blarggStatusRunning:
+		case st == blarggStatusReset:
+			if iReset < i {
+				iReset = i + blarggResetFrame
+			}
+		case st < blarggStatusRunning:
+			if st != 0 {
+				t.Errorf("failed with code %d: %s", st, blarggText(sys))
+			}
+			return
+		}
+	}
+	t.Errorf("timed out: %s", blarggText(sys))
+}
+
+func blarggHasSignature(sys *Sys) bool {
+	for i, b := range blarggSignature {
+		if sys.PeekCpu(0x6001+uint16(i)) != b {
+			return false
+		}
+	}
+	return true
+}
+
+// blarggText returns the text the rom writes from $6004 on.
+func blarggText(sys *Sys) string {
+	var b []byte
+	for addr := uint16(0x6004); addr < 0x8000; addr++ {
+		c := sys.PeekCpu(addr)
+		if c == 0 {
+			break
+		}
+		b = append(b, c)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// blarggScreenResult looks for the result in the first name table, of tiles
+// in ascii.
+func blarggScreenResult(sys *Sys) string {
+	nt := sys.mem.ppuBanks[8]
+	if len(nt) < 0x3c0 {
+		return ""
+	}
+	s := strings.ToLower(string(nt[:0x3c0]))
+	if strings.Contains(s, "passed") {
+		return "passed"
+	}
+	if i := strings.Index(s, "failed"); i >= 0 {
+		// with the rest of the row, as "failed #2"
+		return strings.TrimRight(s[i:(i/32+1)*32], "\x00 ")
+	}
+	return ""
+}
@@ -36,6 +36,31 @@ type Cpu struct {
 	nCycle    int64
 	nCycleDma int64
 	znTable   [256]byte
+
+	// the instruction being run and the cpu cycle it started at
+	opcode   byte
+	nCycleOp int64
+}
+
+// cpuOpCycles is the base cycle count of each opcode, page crossings and
+// taken branches left out.
+var cpuOpCycles = [256]byte{
+	7, 6, 2, 8, 3, 3, 5, 5, 3, 2, 2, 2, 4, 4, 6, 6,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	6, 6, 2, 8, 3, 3, 5, 5, 4, 2, 2, 2, 4, 4, 6, 6,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	6, 6, 2, 8, 3, 3, 5, 5, 3, 2, 2, 2, 3, 4, 6, 6,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	6, 6, 2, 8, 3, 3, 5, 5, 4, 2, 2, 2, 5, 4, 6, 6,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	2, 6, 2, 6, 4, 4, 4, 4, 2, 5, 2, 5, 5, 5, 5, 5,
+	2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	2, 5, 2, 5, 4, 4, 4, 4, 2, 4, 2, 4, 4, 4, 4, 4,
+	2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
 }
 
 func newCpu(sys *Sys) *Cpu {
@@ -63,6 +88,18 @@ func (cpu *Cpu) reset() {
 	cpu.nCycle, cpu.nCycleDma = 0, 0
 }
 
+func (cpu *Cpu) serialize(s *stateBuf) {
+	s.u16(&cpu.regPC)
+	s.u8(&cpu.regA)
+	s.u8(&cpu.regX)
+	s.u8(&cpu.regY)
+	s.u8(&cpu.regP)
+	s.u8(&cpu.regS)
+	s.u8(&cpu.intr)
+	s.i64(&cpu.nCycle)
+	s.i64(&cpu.nCycleDma)
+}
+
 func (cpu *Cpu) readWord(addr uint16) uint16 {
 	return uint16(cpu.sys.read(addr)) | (uint16(cpu.sys.read(addr+1)) << 8)
 }
@@ -77,6 +114,9 @@ func (cpu *Cpu) run(nCycleReq int64) int64 {
 				cpu.nCycleDma -= nCycleReq
 				cpu.nCycle += nCycleReq
 				cpu.sys.mapper.clock(nCycleReq)
+				if sys.renderMode == RenderModeDot {
+					sys.ppu.dotSync(cpu.nCycle * 12)
+				}
 				return cpu.nCycle - nCyclePrev
 			} else {
 				nCycleExec += cpu.nCycleDma
@@ -84,7 +124,18 @@ func (cpu *Cpu) run(nCycleReq int64) int64 {
 			}
 		}
 
+		cpu.nCycleOp = cpu.nCycle + nCycleExec
+		if sys.dbg != nil {
+			sys.dbg.check()
+		}
+		if sys.trace != nil {
+			sys.trace.trace(sys)
+		}
+		if sys.watch != nil {
+			sys.watch.opStart(cpu)
+		}
 		opcode := sys.read(cpu.regPC)
+		cpu.opcode = opcode
 		cpu.regPC++
 		intrNmi, intrIrq := false, false
 		if cpu.intr&cpuIntrTypNmi != 0 {
@@ -1748,7 +1799,7 @@ func (cpu *Cpu) run(nCycleReq int64) int64 {
 		case 0xe3:
 			data = sys.read(cpu.regPC) + cpu.regX
 			cpu.regPC++
-			addr = uint16(cpu.ram[data+1]<<8) | uint16(cpu.ram[data])
+			addr = uint16(cpu.ram[data+1])<<8 | uint16(cpu.ram[data])
 			data = sys.read(addr) + 1
 			word = uint16(cpu.regA) - uint16(data) - uint16(^cpu.regP&cpuRegC)
 			cpu.regP &^= cpuRegV | cpuRegC | cpuRegZ | cpuRegN
@@ -1765,7 +1816,7 @@ func (cpu *Cpu) run(nCycleReq int64) int64 {
 		case 0xf3:
 			data = sys.read(cpu.regPC)
 			cpu.regPC++
-			addr = uint16(cpu.ram[data+1]<<8) | uint16(cpu.ram[data]) + uint16(cpu.regY)
+			addr = uint16(cpu.ram[data+1])<<8 | uint16(cpu.ram[data]) + uint16(cpu.regY)
 			data = sys.read(addr) + 1
 			word = uint16(cpu.regA) - uint16(data) - uint16(^cpu.regP&cpuRegC)
 			cpu.regP &^= cpuRegV | cpuRegC | cpuRegZ | cpuRegN
@@ -2431,6 +2482,9 @@ func (cpu *Cpu) run(nCycleReq int64) int64 {
 			panic("cpu jammed") //ldeng7
 		}
 
+		if sys.watch != nil {
+			sys.watch.opEnd(cpu)
+		}
 		if intrNmi || intrIrq {
 			addr := 0x0100 | uint16(cpu.regS)
 			cpu.ram[addr] = byte(cpu.regPC >> 8)
@@ -2448,16 +2502,10 @@ func (cpu *Cpu) run(nCycleReq int64) int64 {
 		}
 		nCycleReq -= nCycleExec
 		cpu.nCycle += nCycleExec
-		/*
-			if cpu.nCycle < 700001 {
-				ppu := cpu.sys.ppu
-				cpu.sys.logger.WriteString(fmt.Sprintf("%d %d %d %d %d %d %d loo:%d %d %d %d %d %d ppureg:%d %d %d %d %d\r\n",
-					opcode, cpu.regX, cpu.regY, cpu.regA, cpu.regP,
-					cpu.regS, cpu.regPC, ppu.loopyT, ppu.loopyV, ppu.loopyX,
-					ppu.loopyY, ppu.loopySh, ppu.readBuf, ppu.reg0, ppu.reg1, ppu.reg2, ppu.reg3, cpu.nCycle))
-			}
-		*/
 		cpu.sys.mapper.clock(nCycleExec)
+		if sys.renderMode == RenderModeDot {
+			sys.ppu.dotSync(cpu.nCycle * 12)
+		}
 	}
 	return cpu.nCycle - nCyclePrev
 }
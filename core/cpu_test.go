@@ -0,0 +1,82 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/ldeng7/go-fc/core/disasm"
+)
+
+// Every opcode but those jumping or halting runs as long as the table of the
+// disassembler says.
+func TestCpuOpLens(t *testing.T) {
+	sys := newTestRomSys(t, testRom(0, testProg))
+	cpu := sys.cpu
+	for i := range disasm.Ops {
+		op := &disasm.Ops[i]
+		switch op.Name {
+		case "BRK", "JSR", "RTI", "RTS", "JMP", "KIL":
+			continue
+		}
+		if op.Mode == disasm.ModeRel {
+			continue
+		}
+		copy(sys.mem.ram[0x200:], []byte{byte(i), 0x10, 0x03})
+		sys.mem.ram[0x10], sys.mem.ram[0x11] = 0x00, 0x03
+		cpu.regPC, cpu.regS, cpu.regP, cpu.intr = 0x0200, 0xff, cpuRegI, 0
+		cpu.run(1)
+		if want := 0x0200 + uint16(disasm.ModeLens[op.Mode]); cpu.regPC != want {
+			t.Errorf("%02x %s: pc %04x, want %04x", i, op.Name, cpu.regPC, want)
+		}
+	}
+}
+
+func TestCpuUnofficialStores(t *testing.T) {
+	tests := []struct {
+		opcode  byte
+		a, x, y byte
+		addr    uint16
+		data    byte
+		s       byte
+	}{
+		{0x9b, 0xf7, 0x5f, 0x04, 0x0304, 0x04, 0x57}, // TAS $0300,Y
+		{0x9f, 0xf7, 0x5f, 0x04, 0x0304, 0x04, 0xfd}, // AHX $0300,Y
+		{0x9e, 0x00, 0x5f, 0x04, 0x0304, 0x04, 0xfd}, // SHX $0300,Y
+		{0x9c, 0x00, 0x04, 0x5f, 0x0304, 0x04, 0xfd}, // SHY $0300,X
+	}
+	sys := newTestRomSys(t, testRom(0, testProg))
+	cpu := sys.cpu
+	for _, tt := range tests {
+		copy(sys.mem.ram[0x200:], []byte{tt.opcode, 0x00, 0x03})
+		sys.mem.ram[tt.addr] = 0xff
+		cpu.regPC, cpu.regS, cpu.intr = 0x0200, 0xfd, 0
+		cpu.regA, cpu.regX, cpu.regY = tt.a, tt.x, tt.y
+		cpu.run(1)
+		if b := sys.mem.ram[tt.addr]; b != tt.data || cpu.regS != tt.s {
+			t.Errorf("%02x: wrote %#x, s %#x, want %#x, %#x", tt.opcode, b, cpu.regS, tt.data, tt.s)
+		}
+	}
+}
+
+func TestCpuIsbIndirect(t *testing.T) {
+	tests := []struct {
+		opcode byte
+		x, y   byte
+		addr   uint16
+	}{
+		{0xe3, 0x02, 0x00, 0x0300}, // ISB ($0e,X)
+		{0xf3, 0x00, 0x04, 0x0304}, // ISB ($10),Y
+	}
+	sys := newTestRomSys(t, testRom(0, testProg))
+	cpu := sys.cpu
+	for _, tt := range tests {
+		copy(sys.mem.ram[0x200:], []byte{tt.opcode, 0x10 - tt.x})
+		sys.mem.ram[0x10], sys.mem.ram[0x11] = 0x00, 0x03
+		sys.mem.ram[tt.addr] = 0x41
+		cpu.regPC, cpu.regS, cpu.regP, cpu.intr = 0x0200, 0xfd, cpuRegI|cpuRegC, 0
+		cpu.regA, cpu.regX, cpu.regY = 0x50, tt.x, tt.y
+		cpu.run(1)
+		if b := sys.mem.ram[tt.addr]; b != 0x42 || cpu.regA != 0x0e {
+			t.Errorf("%02x: wrote %#x, a %#x, want 0x42, 0xe", tt.opcode, b, cpu.regA)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package core
+
+import "github.com/ldeng7/go-fc/core/disasm"
+
+// the interrupt lines, as flags of CpuRegs.Intr
+const (
+	CpuIntrNmi    = cpuIntrTypNmi
+	CpuIntrIrq    = cpuIntrTypIrq // the external line, as of the fds
+	CpuIntrFrame  = cpuIntrTypFrame
+	CpuIntrDpcm   = cpuIntrTypDpcm
+	CpuIntrMapper = cpuIntrTypMapper
+)
+
+// CpuRegs is a snapshot of the cpu.
+type CpuRegs struct {
+	PC            uint16
+	A, X, Y, P, S byte
+	Intr          byte  // the pending interrupts, of the CpuIntr flags
+	Cycle         int64 // the cpu cycles since the reset
+}
+
+func (sys *Sys) GetCpuRegs() CpuRegs {
+	cpu := sys.cpu
+	return CpuRegs{
+		PC: cpu.regPC, A: cpu.regA, X: cpu.regX, Y: cpu.regY, P: cpu.regP, S: cpu.regS,
+		Intr:  cpu.intr & (CpuIntrNmi | CpuIntrIrq | CpuIntrFrame | CpuIntrDpcm | CpuIntrMapper),
+		Cycle: cpu.nCycle,
+	}
+}
+
+// GetScanline returns the scanline being run, from 0, the first visible one.
+func (sys *Sys) GetScanline() uint16 {
+	return sys.scanline
+}
+
+// PeekCpu reads the cpu address space without side effects: the ram and the
+// cartridge space from $4018 on as the cpu would see it, the ppu and apu
+// registers as 0.
+func (sys *Sys) PeekCpu(addr uint16) byte {
+	if addr < 0x2000 {
+		return sys.mem.ram[addr&0x07ff]
+	} else if addr < 0x4018 {
+		return 0
+	}
+	if b, ok := sys.mapper.peek(addr); ok {
+		return b
+	} else if addr < 0x4100 {
+		return sys.mapper.readEx(addr)
+	} else if addr < 0x8000 {
+		return sys.mapper.readLow(addr)
+	}
+	if bank := sys.mem.cpuBanks[addr>>13]; len(bank) != 0 {
+		return bank[addr&0x1fff]
+	}
+	return 0
+}
+
+// the disassembler reads the live mapping through Sys
+var _ disasm.Bus = (*Sys)(nil)
+
+// GetPrgPage returns the 8 KB page of prg rom mapped at an address, -1 if
+// none.
+func (sys *Sys) GetPrgPage(addr uint16) int {
+	bank := sys.mem.cpuBanks[addr>>13]
+	if region, ofs, _ := sys.mem.bankRef(bank, 0x2000); region == memRegionProm {
+		return int(ofs >> 13)
+	}
+	return -1
+}
+
+// the types of breakpoints, and of the access hitting one
+const (
+	BreakExec byte = 0x01 << iota
+	BreakRead
+	BreakWrite
+)
+
+// the reasons of a break
+const (
+	BreakReasonBreakpoint byte = iota
+	BreakReasonStep
+	BreakReasonScanline
+)
+
+// Breakpoint breaks before an instruction at an address in [Addr, End], or
+// before one reading or writing its operand there. That operand address is
+// worked out from the addressing mode, so the other accesses of an
+// instruction never hit: those to the stack, the dummy ones of the indexed
+// and read-modify-write modes and the pointer reads of the indirect ones; nor
+// do the reads of the vectors and of the dma, the latter reported to watches.
+// With Banked, it only hits while the 8 KB page Bank of the prg rom is mapped
+// at the address, which tells apart the code of the banks sharing a window.
+// Cond, if not nil, is to accept the registers too.
+type Breakpoint struct {
+	Typ    byte // of the Break flags
+	Addr   uint16
+	End    uint16 // Addr if less than it
+	Banked bool
+	Bank   int
+	Cond   func(regs *CpuRegs) bool
+}
+
+// BreakEvent tells why the emulation stopped.
+type BreakEvent struct {
+	Reason byte
+	Id     int    // of the breakpoint hit
+	Typ    byte   // the access that hit it
+	Addr   uint16 // and its address
+	Regs   CpuRegs
+}
+
+const (
+	dbgStepNone byte = iota
+	dbgStepInto
+	dbgStepOver
+	dbgStepOut
+	dbgStepLine
+)
+
+type dbgBreakpoint struct {
+	Breakpoint
+	id int
+}
+
+// Debugger stops the cpu on breakpoints and steps. It is checked before each
+// instruction; with none attached, the cpu runs as usual.
+type Debugger struct {
+	sys     *Sys
+	onBreak func(d *Debugger, ev *BreakEvent)
+
+	bps    []dbgBreakpoint
+	bpTyp  byte // the types of all the breakpoints, for a quick out
+	nextId int
+
+	step     byte
+	stepPC   uint16
+	stepS    byte
+	stepLine uint16
+	line     uint16 // the scanline of the previous instruction
+
+	// the prg rom page mapped at each cpu bank, -1 if none, as last looked up
+	prgBanks [8]*byte
+	prgPages [8]int
+}
+
+// AttachDebugger attaches a debugger to the machine, replacing any previous
+// one. onBreak is called on each break, from within RunFrame and with the
+// emulation paused until it returns; there it may look at the machine and
+// pick how to go on with the Step methods, else it runs to the next
+// breakpoint.
+func (sys *Sys) AttachDebugger(onBreak func(d *Debugger, ev *BreakEvent)) *Debugger {
+	d := &Debugger{sys: sys, onBreak: onBreak, nextId: 1, line: sys.scanline}
+	sys.dbg = d
+	return d
+}
+
+func (sys *Sys) DetachDebugger() {
+	sys.dbg = nil
+}
+
+// AddBreakpoint returns the id of the new breakpoint.
+func (d *Debugger) AddBreakpoint(bp Breakpoint) int {
+	if bp.End < bp.Addr {
+		bp.End = bp.Addr
+	}
+	id := d.nextId
+	d.nextId++
+	d.bps = append(d.bps, dbgBreakpoint{bp, id})
+	d.bpTyp |= bp.Typ
+	return id
+}
+
+func (d *Debugger) RemoveBreakpoint(id int) {
+	d.bpTyp = 0
+	bps := d.bps[:0]
+	for _, bp := range d.bps {
+		if bp.id != id {
+			bps = append(bps, bp)
+			d.bpTyp |= bp.Typ
+		}
+	}
+	d.bps = bps
+}
+
+// Breakpoints returns the breakpoints by id.
+func (d *Debugger) Breakpoints() map[int]Breakpoint {
+	m := make(map[int]Breakpoint, len(d.bps))
+	for _, bp := range d.bps {
+		m[bp.id] = bp.Breakpoint
+	}
+	return m
+}
+
+// StepInto breaks before the next instruction.
+func (d *Debugger) StepInto() {
+	d.step = dbgStepInto
+}
+
+// StepOver breaks after the instruction at PC, including the subroutine it
+// calls if a jsr.
+func (d *Debugger) StepOver() {
+	cpu := d.sys.cpu
+	if d.sys.PeekCpu(cpu.regPC) != 0x20 {
+		d.step = dbgStepInto
+		return
+	}
+	d.step, d.stepPC, d.stepS = dbgStepOver, cpu.regPC+3, cpu.regS
+}
+
+// StepOut breaks after the return from the current subroutine or interrupt
+// handler.
+func (d *Debugger) StepOut() {
+	d.step, d.stepS = dbgStepOut, d.sys.cpu.regS
+}
+
+// RunToScanline breaks before the first instruction of the scanline, of the
+// next frame if already on it.
+func (d *Debugger) RunToScanline(line uint16) {
+	d.step, d.stepLine = dbgStepLine, line
+}
+
+// Continue cancels a step, to run to the next breakpoint.
+func (d *Debugger) Continue() {
+	d.step = dbgStepNone
+}
+
+// check is run by the cpu before each instruction, with the previous one in
+// cpu.opcode.
+func (d *Debugger) check() {
+	sys, cpu := d.sys, d.sys.cpu
+	var ev *BreakEvent
+	switch d.step {
+	case dbgStepInto:
+		ev = &BreakEvent{Reason: BreakReasonStep}
+	case dbgStepOver:
+		if cpu.regPC == d.stepPC && cpu.regS >= d.stepS {
+			ev = &BreakEvent{Reason: BreakReasonStep}
+		}
+	case dbgStepOut:
+		// an rts or rti popping the frame the step started in
+		if (cpu.opcode == 0x60 || cpu.opcode == 0x40) && cpu.regS > d.stepS {
+			ev = &BreakEvent{Reason: BreakReasonStep}
+		}
+	case dbgStepLine:
+		if sys.scanline == d.stepLine && d.line != d.stepLine {
+			ev = &BreakEvent{Reason: BreakReasonScanline}
+		}
+	}
+	d.line = sys.scanline
+	if ev == nil && d.bpTyp != 0 {
+		ev = d.checkBreakpoints()
+	}
+	if ev == nil {
+		return
+	}
+	d.step = dbgStepNone
+	ev.Regs = sys.GetCpuRegs()
+	d.onBreak(d, ev)
+}
+
+func (d *Debugger) checkBreakpoints() *BreakEvent {
+	pc := d.sys.cpu.regPC
+	if d.bpTyp&BreakExec != 0 {
+		if ev := d.match(BreakExec, pc); ev != nil {
+			return ev
+		}
+	}
+	if d.bpTyp&(BreakRead|BreakWrite) == 0 {
+		return nil
+	}
+	op := &disasm.Ops[d.sys.PeekCpu(pc)]
+	if op.Access == 0 {
+		return nil
+	}
+	addr := d.opAddr(op.Mode, pc)
+	if op.Access&disasm.AccessRead != 0 && d.bpTyp&BreakRead != 0 {
+		if ev := d.match(BreakRead, addr); ev != nil {
+			return ev
+		}
+	}
+	if op.Access&disasm.AccessWrite != 0 && d.bpTyp&BreakWrite != 0 {
+		return d.match(BreakWrite, addr)
+	}
+	return nil
+}
+
+func (d *Debugger) match(typ byte, addr uint16) *BreakEvent {
+	var regs *CpuRegs
+	for i := range d.bps {
+		bp := &d.bps[i]
+		if bp.Typ&typ == 0 || addr < bp.Addr || addr > bp.End {
+			continue
+		}
+		if bp.Banked && d.prgPage(addr) != bp.Bank {
+			continue
+		}
+		if bp.Cond != nil {
+			if regs == nil {
+				r := d.sys.GetCpuRegs()
+				regs = &r
+			}
+			if !bp.Cond(regs) {
+				continue
+			}
+		}
+		return &BreakEvent{Reason: BreakReasonBreakpoint, Id: bp.id, Typ: typ, Addr: addr}
+	}
+	return nil
+}
+
+// opAddr works out the operand address of the instruction at pc, as it is
+// to be run.
+func (d *Debugger) opAddr(mode byte, pc uint16) uint16 {
+	sys, cpu := d.sys, d.sys.cpu
+	b := sys.PeekCpu(pc + 1)
+	switch mode {
+	case disasm.ModeZp:
+		return uint16(b)
+	case disasm.ModeZpX:
+		return uint16(b + cpu.regX)
+	case disasm.ModeZpY:
+		return uint16(b + cpu.regY)
+	case disasm.ModeAbs, disasm.ModeAbsX, disasm.ModeAbsY:
+		addr := uint16(b) | uint16(sys.PeekCpu(pc+2))<<8
+		if mode == disasm.ModeAbsX {
+			addr += uint16(cpu.regX)
+		} else if mode == disasm.ModeAbsY {
+			addr += uint16(cpu.regY)
+		}
+		return addr
+	case disasm.ModeIndX:
+		b += cpu.regX
+		return uint16(cpu.ram[b]) | uint16(cpu.ram[b+1])<<8
+	case disasm.ModeIndY:
+		return (uint16(cpu.ram[b]) | uint16(cpu.ram[b+1])<<8) + uint16(cpu.regY)
+	}
+	return 0
+}
+
+func (d *Debugger) prgPage(addr uint16) int {
+	i := addr >> 13
+	bank := d.sys.mem.cpuBanks[i]
+	if len(bank) == 0 {
+		return -1
+	}
+	if &bank[0] != d.prgBanks[i] {
+		d.prgBanks[i], d.prgPages[i] = &bank[0], d.sys.GetPrgPage(addr)
+	}
+	return d.prgPages[i]
+}
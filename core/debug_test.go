@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testNsf returns an nsf image of the tune prog, loaded, initialized and
+// played at $8000.
+func testNsf(chips byte, prog []byte) []byte {
+	h := NsfFileHeader{Magic: 0x4d53454e, Magic1: 0x1a, Version: 1, NSong: 1, StartSong: 1,
+		LoadAddr: 0x8000, InitAddr: 0x8000, PlayAddr: 0x8000, SpeedNtsc: 16639, SpeedPal: 19997, Chips: chips}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, &h)
+	b.Write(prog)
+	return b.Bytes()
+}
+
+func TestPeekCpu(t *testing.T) {
+	tests := []struct {
+		name  string
+		rom   []byte
+		setup func(sys *Sys)
+		addr  uint16
+		want  byte
+		check func(sys *Sys) bool // that the peeks left the state alone
+	}{
+		{"nsf driver", testNsf(0, []byte{0x60}), nil, 0x4100, nsfDriver[0], nil},
+		{"nsf n163", testNsf(NsfChipN163, []byte{0x60}), func(sys *Sys) {
+			sys.write(0xf800, 0x85)
+			sys.write(0x4800, 0x3c)
+			sys.write(0xf800, 0x85)
+		}, 0x4800, 0x3c, func(sys *Sys) bool { return sys.mapper.(*mapperNsf).n163.addr == 0x85 }},
+		{"mapper 4 xram", testRom(4, testProg), func(sys *Sys) {
+			sys.mem.xram[0x1234] = 0xa5
+		}, 0x5234, 0xa5, nil},
+		{"mapper 5 irq", testRom(5, testProg), func(sys *Sys) {
+			sys.mapper.(*mapper005).irqStatus = 0xc0
+		}, 0x5204, 0xc0, func(sys *Sys) bool { return sys.mapper.(*mapper005).irqStatus == 0xc0 }},
+		{"mapper 19 sound ram", testRom(19, testProg), func(sys *Sys) {
+			sys.write(0xf800, 0x81)
+			sys.write(0x4800, 0x5a)
+			sys.write(0xf800, 0x81)
+		}, 0x4800, 0x5a, func(sys *Sys) bool { return sys.mapper.(*mapper019).port.addr == 0x81 }},
+		{"ppu", testRom(0, testProg), nil, 0x2002, 0, nil},
+	}
+	for _, tt := range tests {
+		sys, err := NewSys(bytes.NewReader(tt.rom), &Conf{NoRomDb: true})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.setup != nil {
+			tt.setup(sys)
+		}
+		for i := 0; i < 2; i++ {
+			if b := sys.PeekCpu(tt.addr); b != tt.want {
+				t.Errorf("%s: peeked %#x at %04x, want %#x", tt.name, b, tt.addr, tt.want)
+			}
+		}
+		if tt.check != nil && !tt.check(sys) {
+			t.Errorf("%s: the peek had side effects", tt.name)
+		}
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	prog := make([]byte, 0x60)
+	copy(prog, []byte{
+		0x78, 0xa2, 0xff, 0x9a, // sei; ldx #$ff; txs
+		0xa9, 0x05, 0x85, 0x10, // $8004: lda #$05; sta $10
+		0xa5, 0x10, 0x20, 0x40, 0x80, // $8008: lda $10; jsr $8040
+		0xe6, 0x11, 0x4c, 0x04, 0x80, // $800d: inc $11; jmp $8004
+	})
+	copy(prog[0x40:], []byte{0xa0, 0x07, 0x20, 0x50, 0x80, 0x60}) // ldy #$07; jsr $8050; rts
+	copy(prog[0x50:], []byte{0xc8, 0x60})                         // iny; rts
+	rom := testRom(0, prog)
+
+	regs := func(pc uint16, a, y, s byte) CpuRegs {
+		return CpuRegs{PC: pc, A: a, X: 0xff, Y: y, S: s}
+	}
+	bp := func(id int, typ byte, addr uint16, regs CpuRegs) BreakEvent {
+		return BreakEvent{Reason: BreakReasonBreakpoint, Id: id, Typ: typ, Addr: addr, Regs: regs}
+	}
+	step := func(regs CpuRegs) BreakEvent {
+		return BreakEvent{Reason: BreakReasonStep, Regs: regs}
+	}
+	tests := []struct {
+		name  string
+		bps   []Breakpoint
+		setup func(d *Debugger) // before the run
+		next  func(d *Debugger) // on the first break
+		want  []BreakEvent      // the registers but P, Intr and Cycle, unless all 0
+	}{
+		{"exec", []Breakpoint{{Typ: BreakExec, Addr: 0x8040}}, nil, nil,
+			[]BreakEvent{bp(1, BreakExec, 0x8040, regs(0x8040, 0x05, 0, 0xfd))}},
+		{"exec range", []Breakpoint{{Typ: BreakExec, Addr: 0x8041, End: 0x8045}}, nil, nil,
+			[]BreakEvent{bp(1, BreakExec, 0x8042, regs(0x8042, 0x05, 0x07, 0xfd))}},
+		{"write", []Breakpoint{{Typ: BreakWrite, Addr: 0x0010}}, nil, nil,
+			[]BreakEvent{bp(1, BreakWrite, 0x0010, regs(0x8006, 0x05, 0, 0xff))}},
+		{"read", []Breakpoint{{Typ: BreakRead, Addr: 0x0010, End: 0x0011}}, nil, nil,
+			[]BreakEvent{bp(1, BreakRead, 0x0010, regs(0x8008, 0x05, 0, 0xff))}},
+		{"read modify write", []Breakpoint{{Typ: BreakWrite, Addr: 0x0011}}, nil, nil,
+			[]BreakEvent{bp(1, BreakWrite, 0x0011, regs(0x800d, 0x05, 0x08, 0xff))}},
+		{"banked", []Breakpoint{
+			{Typ: BreakExec, Addr: 0x8040, Banked: true, Bank: 1},
+			{Typ: BreakExec, Addr: 0x8040, Banked: true, Bank: 0},
+		}, nil, nil,
+			[]BreakEvent{bp(2, BreakExec, 0x8040, regs(0x8040, 0x05, 0, 0xfd))}},
+		{"cond", []Breakpoint{{Typ: BreakExec, Addr: 0x8004, Cond: func(r *CpuRegs) bool { return r.Y == 0x08 }}},
+			nil, nil,
+			[]BreakEvent{bp(1, BreakExec, 0x8004, regs(0x8004, 0x05, 0x08, 0xff))}},
+		{"step into", []Breakpoint{{Typ: BreakExec, Addr: 0x800a}}, nil, (*Debugger).StepInto,
+			[]BreakEvent{bp(1, BreakExec, 0x800a, regs(0x800a, 0x05, 0, 0xff)), step(regs(0x8040, 0x05, 0, 0xfd))}},
+		{"step over", []Breakpoint{{Typ: BreakExec, Addr: 0x800a}}, nil, (*Debugger).StepOver,
+			[]BreakEvent{bp(1, BreakExec, 0x800a, regs(0x800a, 0x05, 0, 0xff)), step(regs(0x800d, 0x05, 0x08, 0xff))}},
+		{"step out", []Breakpoint{{Typ: BreakExec, Addr: 0x8042}}, nil, (*Debugger).StepOut,
+			[]BreakEvent{bp(1, BreakExec, 0x8042, regs(0x8042, 0x05, 0x07, 0xfd)), step(regs(0x800d, 0x05, 0x08, 0xff))}},
+		{"scanline", nil, func(d *Debugger) { d.RunToScanline(100) }, nil,
+			[]BreakEvent{{Reason: BreakReasonScanline}}},
+	}
+	for _, tt := range tests {
+		sys := newTestRomSys(t, rom)
+		var got []BreakEvent
+		var lines []uint16
+		d := sys.AttachDebugger(func(d *Debugger, ev *BreakEvent) {
+			got, lines = append(got, *ev), append(lines, sys.GetScanline())
+			if len(got) == 1 && tt.next != nil {
+				tt.next(d)
+			}
+			if len(got) == len(tt.want) {
+				sys.DetachDebugger()
+			}
+		})
+		for _, bp := range tt.bps {
+			d.AddBreakpoint(bp)
+		}
+		if tt.setup != nil {
+			tt.setup(d)
+		}
+		for i := 0; i < 2 && len(got) < len(tt.want); i++ {
+			sys.RunFrame()
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d breaks, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, ev := range got {
+			want := tt.want[i]
+			if want.Regs == (CpuRegs{}) {
+				ev.Regs = CpuRegs{}
+			} else {
+				ev.Regs.P, ev.Regs.Intr, ev.Regs.Cycle = 0, 0, 0
+			}
+			if ev != want {
+				t.Errorf("%s: break %d: got %+v, want %+v", tt.name, i, ev, want)
+			}
+			if want.Reason == BreakReasonScanline && lines[i] != 100 {
+				t.Errorf("%s: break %d on scanline %d", tt.name, i, lines[i])
+			}
+		}
+	}
+}
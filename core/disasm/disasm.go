@@ -0,0 +1,431 @@
+// Package disasm disassembles 6502 code, with the opcodes as the cpu of the
+// core runs them, unofficial ones included, and the labels of symbol files.
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// the addressing modes
+const (
+	ModeImp byte = iota
+	ModeAcc
+	ModeImm
+	ModeZp
+	ModeZpX
+	ModeZpY
+	ModeAbs
+	ModeAbsX
+	ModeAbsY
+	ModeInd
+	ModeIndX
+	ModeIndY
+	ModeRel
+)
+
+// ModeLens is the instruction length of each addressing mode.
+var ModeLens = [...]byte{1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 2, 2, 2}
+
+// the accesses of an instruction to the memory at its operand address
+const (
+	AccessRead byte = 0x01 << iota
+	AccessWrite
+)
+
+// Op describes an opcode; the unofficial ones are named as in the nestest
+// log.
+type Op struct {
+	Name     string
+	Mode     byte
+	Access   byte
+	Official bool
+}
+
+var Ops = [256]Op{
+	{"BRK", ModeImp, 0, true},                          // 00
+	{"ORA", ModeIndX, AccessRead, true},                // 01
+	{"KIL", ModeImp, 0, false},                         // 02
+	{"SLO", ModeIndX, AccessRead | AccessWrite, false}, // 03
+	{"NOP", ModeZp, AccessRead, false},                 // 04
+	{"ORA", ModeZp, AccessRead, true},                  // 05
+	{"ASL", ModeZp, AccessRead | AccessWrite, true},    // 06
+	{"SLO", ModeZp, AccessRead | AccessWrite, false},   // 07
+	{"PHP", ModeImp, 0, true},                          // 08
+	{"ORA", ModeImm, 0, true},                          // 09
+	{"ASL", ModeAcc, 0, true},                          // 0a
+	{"ANC", ModeImm, 0, false},                         // 0b
+	{"NOP", ModeAbs, AccessRead, false},                // 0c
+	{"ORA", ModeAbs, AccessRead, true},                 // 0d
+	{"ASL", ModeAbs, AccessRead | AccessWrite, true},   // 0e
+	{"SLO", ModeAbs, AccessRead | AccessWrite, false},  // 0f
+	{"BPL", ModeRel, 0, true},                          // 10
+	{"ORA", ModeIndY, AccessRead, true},                // 11
+	{"KIL", ModeImp, 0, false},                         // 12
+	{"SLO", ModeIndY, AccessRead | AccessWrite, false}, // 13
+	{"NOP", ModeZpX, AccessRead, false},                // 14
+	{"ORA", ModeZpX, AccessRead, true},                 // 15
+	{"ASL", ModeZpX, AccessRead | AccessWrite, true},   // 16
+	{"SLO", ModeZpX, AccessRead | AccessWrite, false},  // 17
+	{"CLC", ModeImp, 0, true},                          // 18
+	{"ORA", ModeAbsY, AccessRead, true},                // 19
+	{"NOP", ModeImp, 0, false},                         // 1a
+	{"SLO", ModeAbsY, AccessRead | AccessWrite, false}, // 1b
+	{"NOP", ModeAbsX, AccessRead, false},               // 1c
+	{"ORA", ModeAbsX, AccessRead, true},                // 1d
+	{"ASL", ModeAbsX, AccessRead | AccessWrite, true},  // 1e
+	{"SLO", ModeAbsX, AccessRead | AccessWrite, false}, // 1f
+	{"JSR", ModeAbs, 0, true},                          // 20
+	{"AND", ModeIndX, AccessRead, true},                // 21
+	{"KIL", ModeImp, 0, false},                         // 22
+	{"RLA", ModeIndX, AccessRead | AccessWrite, false}, // 23
+	{"BIT", ModeZp, AccessRead, true},                  // 24
+	{"AND", ModeZp, AccessRead, true},                  // 25
+	{"ROL", ModeZp, AccessRead | AccessWrite, true},    // 26
+	{"RLA", ModeZp, AccessRead | AccessWrite, false},   // 27
+	{"PLP", ModeImp, 0, true},                          // 28
+	{"AND", ModeImm, 0, true},                          // 29
+	{"ROL", ModeAcc, 0, true},                          // 2a
+	{"ANC", ModeImm, 0, false},                         // 2b
+	{"BIT", ModeAbs, AccessRead, true},                 // 2c
+	{"AND", ModeAbs, AccessRead, true},                 // 2d
+	{"ROL", ModeAbs, AccessRead | AccessWrite, true},   // 2e
+	{"RLA", ModeAbs, AccessRead | AccessWrite, false},  // 2f
+	{"BMI", ModeRel, 0, true},                          // 30
+	{"AND", ModeIndY, AccessRead, true},                // 31
+	{"KIL", ModeImp, 0, false},                         // 32
+	{"RLA", ModeIndY, AccessRead | AccessWrite, false}, // 33
+	{"NOP", ModeZpX, AccessRead, false},                // 34
+	{"AND", ModeZpX, AccessRead, true},                 // 35
+	{"ROL", ModeZpX, AccessRead | AccessWrite, true},   // 36
+	{"RLA", ModeZpX, AccessRead | AccessWrite, false},  // 37
+	{"SEC", ModeImp, 0, true},                          // 38
+	{"AND", ModeAbsY, AccessRead, true},                // 39
+	{"NOP", ModeImp, 0, false},                         // 3a
+	{"RLA", ModeAbsY, AccessRead | AccessWrite, false}, // 3b
+	{"NOP", ModeAbsX, AccessRead, false},               // 3c
+	{"AND", ModeAbsX, AccessRead, true},                // 3d
+	{"ROL", ModeAbsX, AccessRead | AccessWrite, true},  // 3e
+	{"RLA", ModeAbsX, AccessRead | AccessWrite, false}, // 3f
+	{"RTI", ModeImp, 0, true},                          // 40
+	{"EOR", ModeIndX, AccessRead, true},                // 41
+	{"KIL", ModeImp, 0, false},                         // 42
+	{"SRE", ModeIndX, AccessRead | AccessWrite, false}, // 43
+	{"NOP", ModeZp, AccessRead, false},                 // 44
+	{"EOR", ModeZp, AccessRead, true},                  // 45
+	{"LSR", ModeZp, AccessRead | AccessWrite, true},    // 46
+	{"SRE", ModeZp, AccessRead | AccessWrite, false},   // 47
+	{"PHA", ModeImp, 0, true},                          // 48
+	{"EOR", ModeImm, 0, true},                          // 49
+	{"LSR", ModeAcc, 0, true},                          // 4a
+	{"ALR", ModeImm, 0, false},                         // 4b
+	{"JMP", ModeAbs, 0, true},                          // 4c
+	{"EOR", ModeAbs, AccessRead, true},                 // 4d
+	{"LSR", ModeAbs, AccessRead | AccessWrite, true},   // 4e
+	{"SRE", ModeAbs, AccessRead | AccessWrite, false},  // 4f
+	{"BVC", ModeRel, 0, true},                          // 50
+	{"EOR", ModeIndY, AccessRead, true},                // 51
+	{"KIL", ModeImp, 0, false},                         // 52
+	{"SRE", ModeIndY, AccessRead | AccessWrite, false}, // 53
+	{"NOP", ModeZpX, AccessRead, false},                // 54
+	{"EOR", ModeZpX, AccessRead, true},                 // 55
+	{"LSR", ModeZpX, AccessRead | AccessWrite, true},   // 56
+	{"SRE", ModeZpX, AccessRead | AccessWrite, false},  // 57
+	{"CLI", ModeImp, 0, true},                          // 58
+	{"EOR", ModeAbsY, AccessRead, true},                // 59
+	{"NOP", ModeImp, 0, false},                         // 5a
+	{"SRE", ModeAbsY, AccessRead | AccessWrite, false}, // 5b
+	{"NOP", ModeAbsX, AccessRead, false},               // 5c
+	{"EOR", ModeAbsX, AccessRead, true},                // 5d
+	{"LSR", ModeAbsX, AccessRead | AccessWrite, true},  // 5e
+	{"SRE", ModeAbsX, AccessRead | AccessWrite, false}, // 5f
+	{"RTS", ModeImp, 0, true},                          // 60
+	{"ADC", ModeIndX, AccessRead, true},                // 61
+	{"KIL", ModeImp, 0, false},                         // 62
+	{"RRA", ModeIndX, AccessRead | AccessWrite, false}, // 63
+	{"NOP", ModeZp, AccessRead, false},                 // 64
+	{"ADC", ModeZp, AccessRead, true},                  // 65
+	{"ROR", ModeZp, AccessRead | AccessWrite, true},    // 66
+	{"RRA", ModeZp, AccessRead | AccessWrite, false},   // 67
+	{"PLA", ModeImp, 0, true},                          // 68
+	{"ADC", ModeImm, 0, true},                          // 69
+	{"ROR", ModeAcc, 0, true},                          // 6a
+	{"ARR", ModeImm, 0, false},                         // 6b
+	{"JMP", ModeInd, 0, true},                          // 6c
+	{"ADC", ModeAbs, AccessRead, true},                 // 6d
+	{"ROR", ModeAbs, AccessRead | AccessWrite, true},   // 6e
+	{"RRA", ModeAbs, AccessRead | AccessWrite, false},  // 6f
+	{"BVS", ModeRel, 0, true},                          // 70
+	{"ADC", ModeIndY, AccessRead, true},                // 71
+	{"KIL", ModeImp, 0, false},                         // 72
+	{"RRA", ModeIndY, AccessRead | AccessWrite, false}, // 73
+	{"NOP", ModeZpX, AccessRead, false},                // 74
+	{"ADC", ModeZpX, AccessRead, true},                 // 75
+	{"ROR", ModeZpX, AccessRead | AccessWrite, true},   // 76
+	{"RRA", ModeZpX, AccessRead | AccessWrite, false},  // 77
+	{"SEI", ModeImp, 0, true},                          // 78
+	{"ADC", ModeAbsY, AccessRead, true},                // 79
+	{"NOP", ModeImp, 0, false},                         // 7a
+	{"RRA", ModeAbsY, AccessRead | AccessWrite, false}, // 7b
+	{"NOP", ModeAbsX, AccessRead, false},               // 7c
+	{"ADC", ModeAbsX, AccessRead, true},                // 7d
+	{"ROR", ModeAbsX, AccessRead | AccessWrite, true},  // 7e
+	{"RRA", ModeAbsX, AccessRead | AccessWrite, false}, // 7f
+	{"NOP", ModeImm, 0, false},                         // 80
+	{"STA", ModeIndX, AccessWrite, true},               // 81
+	{"NOP", ModeImm, 0, false},                         // 82
+	{"SAX", ModeIndX, AccessWrite, false},              // 83
+	{"STY", ModeZp, AccessWrite, true},                 // 84
+	{"STA", ModeZp, AccessWrite, true},                 // 85
+	{"STX", ModeZp, AccessWrite, true},                 // 86
+	{"SAX", ModeZp, AccessWrite, false},                // 87
+	{"DEY", ModeImp, 0, true},                          // 88
+	{"NOP", ModeImm, 0, false},                         // 89
+	{"TXA", ModeImp, 0, true},                          // 8a
+	{"XAA", ModeImm, 0, false},                         // 8b
+	{"STY", ModeAbs, AccessWrite, true},                // 8c
+	{"STA", ModeAbs, AccessWrite, true},                // 8d
+	{"STX", ModeAbs, AccessWrite, true},                // 8e
+	{"SAX", ModeAbs, AccessWrite, false},               // 8f
+	{"BCC", ModeRel, 0, true},                          // 90
+	{"STA", ModeIndY, AccessWrite, true},               // 91
+	{"KIL", ModeImp, 0, false},                         // 92
+	{"AHX", ModeIndY, AccessWrite, false},              // 93
+	{"STY", ModeZpX, AccessWrite, true},                // 94
+	{"STA", ModeZpX, AccessWrite, true},                // 95
+	{"STX", ModeZpY, AccessWrite, true},                // 96
+	{"SAX", ModeZpY, AccessWrite, false},               // 97
+	{"TYA", ModeImp, 0, true},                          // 98
+	{"STA", ModeAbsY, AccessWrite, true},               // 99
+	{"TXS", ModeImp, 0, true},                          // 9a
+	{"TAS", ModeAbsY, AccessWrite, false},              // 9b
+	{"SHY", ModeAbsX, AccessWrite, false},              // 9c
+	{"STA", ModeAbsX, AccessWrite, true},               // 9d
+	{"SHX", ModeAbsY, AccessWrite, false},              // 9e
+	{"AHX", ModeAbsY, AccessWrite, false},              // 9f
+	{"LDY", ModeImm, 0, true},                          // a0
+	{"LDA", ModeIndX, AccessRead, true},                // a1
+	{"LDX", ModeImm, 0, true},                          // a2
+	{"LAX", ModeIndX, AccessRead, false},               // a3
+	{"LDY", ModeZp, AccessRead, true},                  // a4
+	{"LDA", ModeZp, AccessRead, true},                  // a5
+	{"LDX", ModeZp, AccessRead, true},                  // a6
+	{"LAX", ModeZp, AccessRead, false},                 // a7
+	{"TAY", ModeImp, 0, true},                          // a8
+	{"LDA", ModeImm, 0, true},                          // a9
+	{"TAX", ModeImp, 0, true},                          // aa
+	{"LAX", ModeImm, 0, false},                         // ab
+	{"LDY", ModeAbs, AccessRead, true},                 // ac
+	{"LDA", ModeAbs, AccessRead, true},                 // ad
+	{"LDX", ModeAbs, AccessRead, true},                 // ae
+	{"LAX", ModeAbs, AccessRead, false},                // af
+	{"BCS", ModeRel, 0, true},                          // b0
+	{"LDA", ModeIndY, AccessRead, true},                // b1
+	{"KIL", ModeImp, 0, false},                         // b2
+	{"LAX", ModeIndY, AccessRead, false},               // b3
+	{"LDY", ModeZpX, AccessRead, true},                 // b4
+	{"LDA", ModeZpX, AccessRead, true},                 // b5
+	{"LDX", ModeZpY, AccessRead, true},                 // b6
+	{"LAX", ModeZpY, AccessRead, false},                // b7
+	{"CLV", ModeImp, 0, true},                          // b8
+	{"LDA", ModeAbsY, AccessRead, true},                // b9
+	{"TSX", ModeImp, 0, true},                          // ba
+	{"LAS", ModeAbsY, AccessRead, false},               // bb
+	{"LDY", ModeAbsX, AccessRead, true},                // bc
+	{"LDA", ModeAbsX, AccessRead, true},                // bd
+	{"LDX", ModeAbsY, AccessRead, true},                // be
+	{"LAX", ModeAbsY, AccessRead, false},               // bf
+	{"CPY", ModeImm, 0, true},                          // c0
+	{"CMP", ModeIndX, AccessRead, true},                // c1
+	{"NOP", ModeImm, 0, false},                         // c2
+	{"DCP", ModeIndX, AccessRead | AccessWrite, false}, // c3
+	{"CPY", ModeZp, AccessRead, true},                  // c4
+	{"CMP", ModeZp, AccessRead, true},                  // c5
+	{"DEC", ModeZp, AccessRead | AccessWrite, true},    // c6
+	{"DCP", ModeZp, AccessRead | AccessWrite, false},   // c7
+	{"INY", ModeImp, 0, true},                          // c8
+	{"CMP", ModeImm, 0, true},                          // c9
+	{"DEX", ModeImp, 0, true},                          // ca
+	{"AXS", ModeImm, 0, false},                         // cb
+	{"CPY", ModeAbs, AccessRead, true},                 // cc
+	{"CMP", ModeAbs, AccessRead, true},                 // cd
+	{"DEC", ModeAbs, AccessRead | AccessWrite, true},   // ce
+	{"DCP", ModeAbs, AccessRead | AccessWrite, false},  // cf
+	{"BNE", ModeRel, 0, true},                          // d0
+	{"CMP", ModeIndY, AccessRead, true},                // d1
+	{"KIL", ModeImp, 0, false},                         // d2
+	{"DCP", ModeIndY, AccessRead | AccessWrite, false}, // d3
+	{"NOP", ModeZpX, AccessRead, false},                // d4
+	{"CMP", ModeZpX, AccessRead, true},                 // d5
+	{"DEC", ModeZpX, AccessRead | AccessWrite, true},   // d6
+	{"DCP", ModeZpX, AccessRead | AccessWrite, false},  // d7
+	{"CLD", ModeImp, 0, true},                          // d8
+	{"CMP", ModeAbsY, AccessRead, true},                // d9
+	{"NOP", ModeImp, 0, false},                         // da
+	{"DCP", ModeAbsY, AccessRead | AccessWrite, false}, // db
+	{"NOP", ModeAbsX, AccessRead, false},               // dc
+	{"CMP", ModeAbsX, AccessRead, true},                // dd
+	{"DEC", ModeAbsX, AccessRead | AccessWrite, true},  // de
+	{"DCP", ModeAbsX, AccessRead | AccessWrite, false}, // df
+	{"CPX", ModeImm, 0, true},                          // e0
+	{"SBC", ModeIndX, AccessRead, true},                // e1
+	{"NOP", ModeImm, 0, false},                         // e2
+	{"ISB", ModeIndX, AccessRead | AccessWrite, false}, // e3
+	{"CPX", ModeZp, AccessRead, true},                  // e4
+	{"SBC", ModeZp, AccessRead, true},                  // e5
+	{"INC", ModeZp, AccessRead | AccessWrite, true},    // e6
+	{"ISB", ModeZp, AccessRead | AccessWrite, false},   // e7
+	{"INX", ModeImp, 0, true},                          // e8
+	{"SBC", ModeImm, 0, true},                          // e9
+	{"NOP", ModeImp, 0, true},                          // ea
+	{"SBC", ModeImm, 0, false},                         // eb
+	{"CPX", ModeAbs, AccessRead, true},                 // ec
+	{"SBC", ModeAbs, AccessRead, true},                 // ed
+	{"INC", ModeAbs, AccessRead | AccessWrite, true},   // ee
+	{"ISB", ModeAbs, AccessRead | AccessWrite, false},  // ef
+	{"BEQ", ModeRel, 0, true},                          // f0
+	{"SBC", ModeIndY, AccessRead, true},                // f1
+	{"KIL", ModeImp, 0, false},                         // f2
+	{"ISB", ModeIndY, AccessRead | AccessWrite, false}, // f3
+	{"NOP", ModeZpX, AccessRead, false},                // f4
+	{"SBC", ModeZpX, AccessRead, true},                 // f5
+	{"INC", ModeZpX, AccessRead | AccessWrite, true},   // f6
+	{"ISB", ModeZpX, AccessRead | AccessWrite, false},  // f7
+	{"SED", ModeImp, 0, true},                          // f8
+	{"SBC", ModeAbsY, AccessRead, true},                // f9
+	{"NOP", ModeImp, 0, false},                         // fa
+	{"ISB", ModeAbsY, AccessRead | AccessWrite, false}, // fb
+	{"NOP", ModeAbsX, AccessRead, false},               // fc
+	{"SBC", ModeAbsX, AccessRead, true},                // fd
+	{"INC", ModeAbsX, AccessRead | AccessWrite, true},  // fe
+	{"ISB", ModeAbsX, AccessRead | AccessWrite, false}, // ff
+}
+
+// Bus is the memory to disassemble, *core.Sys being the live cpu address
+// space.
+type Bus interface {
+	PeekCpu(addr uint16) byte
+	// GetPrgPage returns the 8 KB page of prg rom at the address, -1 if
+	// none, for the symbols of banked code.
+	GetPrgPage(addr uint16) int
+}
+
+type prgBank struct {
+	data []byte
+	base uint16
+	page int
+}
+
+// NewPrgBank returns a Bus of raw prg rom, data seen from the address base
+// on, data starting at the 8 KB page page of the rom, -1 if unknown.
+func NewPrgBank(data []byte, base uint16, page int) Bus {
+	return &prgBank{data, base, page}
+}
+
+func (b *prgBank) PeekCpu(addr uint16) byte {
+	if i := int(addr - b.base); i < len(b.data) {
+		return b.data[i]
+	}
+	return 0
+}
+
+func (b *prgBank) GetPrgPage(addr uint16) int {
+	if i := int(addr - b.base); i < len(b.data) && b.page >= 0 {
+		return b.page + i>>13
+	}
+	return -1
+}
+
+// Inst is a decoded instruction.
+type Inst struct {
+	Addr    uint16
+	Bytes   [3]byte
+	Len     byte
+	Op      *Op
+	Operand uint16 // the operand as encoded, the target for a branch
+}
+
+func Decode(bus Bus, addr uint16) Inst {
+	in := Inst{Addr: addr}
+	in.Bytes[0] = bus.PeekCpu(addr)
+	in.Op = &Ops[in.Bytes[0]]
+	in.Len = ModeLens[in.Op.Mode]
+	for i := byte(1); i < in.Len; i++ {
+		in.Bytes[i] = bus.PeekCpu(addr + uint16(i))
+	}
+	switch in.Len {
+	case 2:
+		in.Operand = uint16(in.Bytes[1])
+		if in.Op.Mode == ModeRel {
+			in.Operand = addr + 2 + uint16(int8(in.Bytes[1]))
+		}
+	case 3:
+		in.Operand = uint16(in.Bytes[1]) | uint16(in.Bytes[2])<<8
+	}
+	return in
+}
+
+// Text formats the instruction as "LDA $0200,X", with the operand address
+// replaced by its label in syms if any; bus tells the bank of the label.
+func (in *Inst) Text(bus Bus, syms *Symbols) string {
+	op := in.Op
+	var addr string
+	switch op.Mode {
+	case ModeImp:
+		return op.Name
+	case ModeAcc:
+		return op.Name + " A"
+	case ModeImm:
+		return fmt.Sprintf("%s #$%02X", op.Name, in.Operand)
+	case ModeZp, ModeZpX, ModeZpY, ModeIndX, ModeIndY:
+		addr = fmt.Sprintf("$%02X", in.Operand)
+	default:
+		addr = fmt.Sprintf("$%04X", in.Operand)
+	}
+	if syms != nil {
+		if name, ok := syms.Lookup(in.Operand, bus.GetPrgPage(in.Operand)); ok {
+			addr = name
+		}
+	}
+	switch op.Mode {
+	case ModeZpX, ModeAbsX:
+		addr += ",X"
+	case ModeZpY, ModeAbsY:
+		addr += ",Y"
+	case ModeInd:
+		addr = "(" + addr + ")"
+	case ModeIndX:
+		addr = "(" + addr + ",X)"
+	case ModeIndY:
+		addr = "(" + addr + "),Y"
+	}
+	return op.Name + " " + addr
+}
+
+// List writes the disassembly of the instructions starting from addr to end,
+// one a line, after the labels of its address if any.
+func List(w io.Writer, bus Bus, syms *Symbols, addr, end uint16) error {
+	for {
+		if syms != nil {
+			if name, ok := syms.Lookup(addr, bus.GetPrgPage(addr)); ok {
+				if _, err := fmt.Fprintf(w, "%s:\n", name); err != nil {
+					return err
+				}
+			}
+		}
+		in := Decode(bus, addr)
+		var bs strings.Builder
+		for _, b := range in.Bytes[:in.Len] {
+			fmt.Fprintf(&bs, "%02X ", b)
+		}
+		if _, err := fmt.Fprintf(w, "%04X  %-9s %s\n", addr, bs.String(), in.Text(bus, syms)); err != nil {
+			return err
+		}
+		next := addr + uint16(in.Len)
+		if next < addr || next > end {
+			return nil
+		}
+		addr = next
+	}
+}
@@ -0,0 +1,64 @@
+package disasm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		code    []byte
+		addr    uint16
+		len     byte
+		operand uint16
+		text    string
+	}{
+		{[]byte{0xea}, 0x8000, 1, 0, "NOP"},
+		{[]byte{0x0a}, 0x8000, 1, 0, "ASL A"},
+		{[]byte{0xa9, 0x1f}, 0x8000, 2, 0x1f, "LDA #$1F"},
+		{[]byte{0xb5, 0x10}, 0x8000, 2, 0x10, "LDA $10,X"},
+		{[]byte{0xb6, 0x10}, 0x8000, 2, 0x10, "LDX $10,Y"},
+		{[]byte{0x9d, 0x00, 0x02}, 0x8000, 3, 0x0200, "STA $0200,X"},
+		{[]byte{0x6c, 0xfc, 0xff}, 0x8000, 3, 0xfffc, "JMP ($FFFC)"},
+		{[]byte{0xa1, 0x20}, 0x8000, 2, 0x20, "LDA ($20,X)"},
+		{[]byte{0x91, 0x20}, 0x8000, 2, 0x20, "STA ($20),Y"},
+		{[]byte{0xd0, 0xfe}, 0x8010, 2, 0x8010, "BNE $8010"},
+		{[]byte{0x10, 0x7f}, 0x8010, 2, 0x8091, "BPL $8091"},
+		{[]byte{0xf0, 0x80}, 0x8010, 2, 0x7f92, "BEQ $7F92"},
+		{[]byte{0x9b, 0x00, 0x03}, 0x8000, 3, 0x0300, "TAS $0300,Y"},
+		{[]byte{0x02}, 0x8000, 1, 0, "KIL"},
+	}
+	for _, tt := range tests {
+		bus := NewPrgBank(tt.code, tt.addr, -1)
+		in := Decode(bus, tt.addr)
+		if in.Len != tt.len || in.Operand != tt.operand || in.Op != &Ops[tt.code[0]] {
+			t.Errorf("%02x: got len %d, operand %04x", tt.code[0], in.Len, in.Operand)
+		}
+		if !bytes.Equal(in.Bytes[:in.Len], tt.code) {
+			t.Errorf("%02x: got bytes % x", tt.code[0], in.Bytes[:in.Len])
+		}
+		if s := in.Text(bus, nil); s != tt.text {
+			t.Errorf("%02x: got %q, want %q", tt.code[0], s, tt.text)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	code := []byte{0xa9, 0x00, 0x8d, 0x00, 0x20, 0x4c, 0x00, 0xc0}
+	bus := NewPrgBank(code, 0xc000, 6)
+	syms := NewSymbols()
+	syms.Add(0xc000, 6, "reset")
+	syms.Add(0x2000, -1, "PPUCTRL")
+	syms.Add(0xc000, 7, "other_bank")
+	var b bytes.Buffer
+	if err := List(&b, bus, syms, 0xc000, 0xc005); err != nil {
+		t.Fatal(err)
+	}
+	want := "reset:\n" +
+		"C000  A9 00     LDA #$00\n" +
+		"C002  8D 00 20  STA PPUCTRL\n" +
+		"C005  4C 00 C0  JMP reset\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
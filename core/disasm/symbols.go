@@ -0,0 +1,193 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type symKey struct {
+	addr uint16
+	page int
+}
+
+// Symbols maps addresses to labels, those of code in prg rom by the 8 KB
+// page too, as the same address is another code in each bank.
+type Symbols struct {
+	m map[symKey]string
+}
+
+func NewSymbols() *Symbols {
+	return &Symbols{m: map[symKey]string{}}
+}
+
+// Add adds a label, at an address in the 8 KB page page of prg rom, or -1 for
+// one not banked. The first label added to an address stays.
+func (s *Symbols) Add(addr uint16, page int, name string) {
+	if page < 0 {
+		page = -1
+	}
+	k := symKey{addr, page}
+	if _, ok := s.m[k]; !ok {
+		s.m[k] = name
+	}
+}
+
+// Lookup returns the label at an address, in the 8 KB page page of prg rom
+// if not negative, else the one not banked.
+func (s *Symbols) Lookup(addr uint16, page int) (string, bool) {
+	if page >= 0 {
+		if name, ok := s.m[symKey{addr, page}]; ok {
+			return name, true
+		}
+	}
+	name, ok := s.m[symKey{addr, -1}]
+	return name, ok
+}
+
+// LoadNl reads an fceux name list, of lines as "$C000#label#comment". bank
+// is that of the file name, "game.nes.<bank>.nl", the 16 KB bank of prg rom
+// the labels are in, or -1 for "game.nes.ram.nl".
+func (s *Symbols) LoadNl(r io.Reader, bank int) error {
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimSpace(sc.Text())
+		if len(line) == 0 {
+			continue
+		}
+		fs := strings.SplitN(line, "#", 3)
+		if len(fs) < 2 || !strings.HasPrefix(fs[0], "$") {
+			return fmt.Errorf("line %d: invalid name", ln)
+		}
+		// an array as "$0200/10"
+		a := fs[0][1:]
+		if i := strings.IndexByte(a, '/'); i >= 0 {
+			a = a[:i]
+		}
+		addr, err := strconv.ParseUint(a, 16, 16)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid address", ln)
+		}
+		if len(fs[1]) == 0 {
+			continue
+		}
+		page := -1
+		if bank >= 0 && addr >= 0x8000 {
+			page = bank<<1 | int(addr>>13)&1
+		}
+		s.Add(uint16(addr), page, fs[1])
+	}
+	return sc.Err()
+}
+
+// the header of the ines image ld65 writes, before the prg rom
+const dbgInesHeaderSize = 16
+
+type dbgSeg struct {
+	start uint64
+	ooffs int64 // -1 if not in the output file
+}
+
+// LoadDbg reads the labels of a ca65 debug file, as written by ld65 with
+// --dbgfile. The labels of the segments in the output file, taken to be an
+// ines image, are banked by their place in the prg rom.
+func (s *Symbols) LoadDbg(r io.Reader) error {
+	type sym struct {
+		name string
+		val  uint64
+		seg  int
+	}
+	segs := map[int]dbgSeg{}
+	var syms []sym
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for ln := 1; sc.Scan(); ln++ {
+		line := sc.Text()
+		i := strings.IndexByte(line, '\t')
+		if i < 0 {
+			continue
+		}
+		typ := line[:i]
+		if typ != "seg" && typ != "sym" {
+			continue
+		}
+		kv := dbgFields(line[i+1:])
+		id, err := strconv.Atoi(kv["id"])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid id", ln)
+		}
+		switch typ {
+		case "seg":
+			seg := dbgSeg{ooffs: -1}
+			if seg.start, err = strconv.ParseUint(kv["start"], 0, 32); err != nil {
+				return fmt.Errorf("line %d: invalid segment start", ln)
+			}
+			if o, ok := kv["ooffs"]; ok {
+				if seg.ooffs, err = strconv.ParseInt(o, 0, 64); err != nil {
+					return fmt.Errorf("line %d: invalid segment offset", ln)
+				}
+			}
+			segs[id] = seg
+		case "sym":
+			if kv["type"] != "lab" {
+				continue
+			}
+			sm := sym{name: kv["name"], seg: -1}
+			if sm.val, err = strconv.ParseUint(kv["val"], 0, 32); err != nil {
+				return fmt.Errorf("line %d: invalid symbol value", ln)
+			}
+			if seg, ok := kv["seg"]; ok {
+				if sm.seg, err = strconv.Atoi(seg); err != nil {
+					return fmt.Errorf("line %d: invalid symbol segment", ln)
+				}
+			}
+			syms = append(syms, sm)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	for _, sm := range syms {
+		page := -1
+		if seg, ok := segs[sm.seg]; ok && seg.ooffs >= dbgInesHeaderSize && sm.val >= seg.start {
+			page = int((seg.ooffs - dbgInesHeaderSize + int64(sm.val-seg.start)) >> 13)
+		}
+		s.Add(uint16(sm.val), page, sm.name)
+	}
+	return nil
+}
+
+// dbgFields splits the fields of a debug file line, as a=1,b="x".
+func dbgFields(s string) map[string]string {
+	kv := map[string]string{}
+	for len(s) != 0 {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			break
+		}
+		k := s[:i]
+		s = s[i+1:]
+		var v string
+		if strings.HasPrefix(s, "\"") {
+			j := strings.IndexByte(s[1:], '"')
+			if j < 0 {
+				j = len(s) - 1
+			}
+			v, s = s[1:j+1], s[j+1:]
+			if len(s) != 0 {
+				s = s[1:]
+			}
+		} else {
+			j := strings.IndexByte(s, ',')
+			if j < 0 {
+				j = len(s)
+			}
+			v, s = s[:j], s[j:]
+		}
+		s = strings.TrimPrefix(s, ",")
+		kv[k] = v
+	}
+	return kv
+}
@@ -0,0 +1,89 @@
+package disasm
+
+import (
+	"strings"
+	"testing"
+)
+
+type symWant struct {
+	addr uint16
+	page int
+	name string // empty if none
+}
+
+func checkSyms(t *testing.T, name string, s *Symbols, wants []symWant) {
+	for _, w := range wants {
+		got, ok := s.Lookup(w.addr, w.page)
+		if ok != (w.name != "") || got != w.name {
+			t.Errorf("%s: %04x in page %d: got %q, want %q", name, w.addr, w.page, got, w.name)
+		}
+	}
+}
+
+func TestLoadNl(t *testing.T) {
+	tests := []struct {
+		name  string
+		nl    string
+		bank  int
+		wants []symWant
+		err   bool
+	}{
+		{"ram", "$0010#ptr#a pointer\n\n$0200/10#buf#\n$0300##no name\n", -1, []symWant{
+			{0x0010, -1, "ptr"}, {0x0010, 3, "ptr"}, {0x0200, -1, "buf"}, {0x0300, -1, ""},
+		}, false},
+		{"bank", "$8000#start#\n$A000#second#\n$6000#wram#\n", 2, []symWant{
+			{0x8000, 4, "start"}, {0xa000, 5, "second"}, {0x8000, 6, ""}, {0x8000, -1, ""}, {0x6000, 0, "wram"},
+		}, false},
+		{"first stays", "$C000#a#\n$C000#b#\n", 0, []symWant{{0xc000, 0, "a"}}, false},
+		{"no dollar", "C000#a#\n", 0, nil, true},
+		{"no name", "$C000\n", 0, nil, true},
+		{"bad address", "$G000#a#\n", 0, nil, true},
+	}
+	for _, tt := range tests {
+		s := NewSymbols()
+		err := s.LoadNl(strings.NewReader(tt.nl), tt.bank)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		checkSyms(t, tt.name, s, tt.wants)
+	}
+}
+
+func TestLoadDbg(t *testing.T) {
+	tests := []struct {
+		name  string
+		dbg   string
+		wants []symWant
+		err   bool
+	}{
+		{"segments", `version	major=2,minor=0
+seg	id=0,name="ZEROPAGE",start=0x000000,size=0x0010,addrsize=zeropage,type=rw
+seg	id=1,name="CODE",start=0x00C000,size=0x4000,addrsize=absolute,type=ro,oname="game.nes",ooffs=16
+seg	id=2,name="BANK1",start=0x008000,size=0x2000,addrsize=absolute,type=ro,oname="game.nes",ooffs=8208
+sym	id=0,name="ptr",addrsize=zeropage,size=2,scope=0,def=1,ref=2,val=0x10,seg=0,type=lab
+sym	id=1,name="reset",addrsize=absolute,scope=0,def=3,val=0xC000,seg=1,type=lab
+sym	id=2,name="nmi",addrsize=absolute,scope=0,def=4,val=0xE010,seg=1,type=lab
+sym	id=3,name="far",addrsize=absolute,scope=0,def=5,val=0x8004,seg=2,type=lab
+sym	id=4,name="CONST",addrsize=zeropage,scope=0,def=6,val=0x20,type=equ
+sym	id=5,name="a,b",addrsize=absolute,scope=0,def=7,val=0x300,type=lab
+`, []symWant{
+			{0x0010, -1, "ptr"}, {0xc000, 0, "reset"}, {0xe010, 1, "nmi"}, {0x8004, 1, "far"},
+			{0xc000, -1, ""}, {0x0020, -1, ""}, {0x0300, -1, "a,b"},
+		}, false},
+		{"bad id", "sym\tid=x,name=\"a\",val=0x10,type=lab\n", nil, true},
+		{"bad value", "sym\tid=0,name=\"a\",val=zz,type=lab\n", nil, true},
+		{"bad start", "seg\tid=0,name=\"a\",start=,size=1\n", nil, true},
+		{"bad offset", "seg\tid=0,name=\"a\",start=0,ooffs=x\n", nil, true},
+		{"bad segment", "sym\tid=0,name=\"a\",val=0x10,seg=x,type=lab\n", nil, true},
+	}
+	for _, tt := range tests {
+		s := NewSymbols()
+		err := s.LoadDbg(strings.NewReader(tt.dbg))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		checkSyms(t, tt.name, s, tt.wants)
+	}
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	fdsSideSize    = 65500
+	fdsGapHead     = 28300 / 8
+	fdsGapBlock    = 976 / 8
+	fdsInsertDelay = 900000
+)
+
+var fdsDiskMagic = []byte("\x01*NINTENDO-HVC*")
+
+// fdsDisk holds every side as the drive head sees it: leading gap, then
+// each block preceded by its start mark and followed by a crc and a gap.
+type fdsDisk struct {
+	sides [][]byte
+	side  int32
+	next  int32
+	delay int32
+	dirty bool
+}
+
+func (d *fdsDisk) serialize(s *stateBuf) {
+	s.i32(&d.side)
+	s.i32(&d.next)
+	s.i32(&d.delay)
+	s.bool(&d.dirty)
+	for _, sd := range d.sides {
+		s.bytes(sd)
+	}
+}
+
+func (d *fdsDisk) data() []byte {
+	if d.side < 0 {
+		return nil
+	}
+	return d.sides[d.side]
+}
+
+func (d *fdsDisk) insert(side int32) {
+	if d.side >= 0 {
+		// let the bios see the drive empty for a while first
+		d.side, d.next, d.delay = -1, side, fdsInsertDelay
+	} else {
+		d.side, d.next, d.delay = side, -1, 0
+	}
+}
+
+func (d *fdsDisk) clock(nCycle int32) {
+	if d.delay > 0 {
+		if d.delay -= nCycle; d.delay <= 0 {
+			d.side, d.next, d.delay = d.next, -1, 0
+		}
+	}
+}
+
+func fdsBlockLen(b []byte, i int, fileSize int) int {
+	switch b[i] {
+	case 1:
+		return 56
+	case 2:
+		return 2
+	case 3:
+		return 16
+	case 4:
+		return 1 + fileSize
+	}
+	return 0
+}
+
+func fdsAddGaps(raw []byte) []byte {
+	side := make([]byte, fdsGapHead, fdsSideSize+0x2000)
+	fileSize := 0
+	for i := 0; i < len(raw); {
+		if raw[i] == 3 && i+15 <= len(raw) {
+			fileSize = int(raw[i+13]) | int(raw[i+14])<<8
+		}
+		n := fdsBlockLen(raw, i, fileSize)
+		if n == 0 || i+n > len(raw) {
+			break
+		}
+		side = append(side, 0x80)
+		side = append(side, raw[i:i+n]...)
+		side = append(side, 0x4d, 0x62) // crc, never checked by the drive
+		side = append(side, make([]byte, fdsGapBlock)...)
+		i += n
+	}
+	if len(side) < fdsSideSize {
+		side = append(side, make([]byte, fdsSideSize-len(side))...)
+	}
+	return side
+}
+
+func fdsStripGaps(side []byte) []byte {
+	raw := make([]byte, 0, fdsSideSize)
+	fileSize := 0
+	for i := 0; i < len(side); {
+		if side[i] != 0x80 {
+			i++
+			continue
+		}
+		i++
+		if i >= len(side) {
+			break
+		}
+		if side[i] == 3 && i+15 <= len(side) {
+			fileSize = int(side[i+13]) | int(side[i+14])<<8
+		}
+		n := fdsBlockLen(side, i, fileSize)
+		if n == 0 || i+n > len(side) {
+			break
+		}
+		raw = append(raw, side[i:i+n]...)
+		i += n + 2
+	}
+	if len(raw) > fdsSideSize {
+		raw = raw[:fdsSideSize]
+	}
+	return append(raw, make([]byte, fdsSideSize-len(raw))...)
+}
+
+// newFdsRom reads the sides of a disk image, head holding the bytes already
+// consumed when the image has no fwNES header.
+func newFdsRom(file io.Reader, head []byte) (*Rom, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	data = append(head, data...)
+	n := len(data) / fdsSideSize
+	if n == 0 || n > 0xff {
+		return nil, errors.New("invalid disk image")
+	}
+
+	rom := &Rom{}
+	info := &rom.info
+	info.MapperNo = 20
+	info.PramSize = 0x8000
+	info.CramSize = 0x2000
+	info.FdsSideNum = byte(n)
+	info.Crc = crc32.ChecksumIEEE(data[:n*fdsSideSize])
+	rom.mapperNo = info.MapperNo
+	rom.nPromPage = 1
+	rom.prom = make([]byte, 0x4000)
+
+	rom.fds = &fdsDisk{next: -1}
+	for i := 0; i < n; i++ {
+		rom.fds.sides = append(rom.fds.sides, fdsAddGaps(data[i*fdsSideSize:(i+1)*fdsSideSize]))
+	}
+	return rom, nil
+}
+
+func (rom *Rom) loadFdsBios(bios []byte) error {
+	if len(bios) != 0x2000 {
+		return errors.New("disk system bios required")
+	}
+	copy(rom.prom, bios)
+	return nil
+}
+
+// FdsGetSideNum returns the number of disk sides, 0 if not a disk image.
+func (sys *Sys) FdsGetSideNum() int {
+	if sys.rom.fds == nil {
+		return 0
+	}
+	return len(sys.rom.fds.sides)
+}
+
+// FdsGetSide returns the inserted side, or -1 if the drive is empty.
+func (sys *Sys) FdsGetSide() int {
+	if sys.rom.fds == nil {
+		return -1
+	}
+	return int(sys.rom.fds.side)
+}
+
+func (sys *Sys) FdsEjectDisk() {
+	if d := sys.rom.fds; d != nil {
+		d.side, d.next, d.delay = -1, -1, 0
+	}
+}
+
+// FdsInsertDisk inserts the given side. If a disk is in the drive, it is
+// ejected first and the new side shows up about half a second later.
+func (sys *Sys) FdsInsertDisk(side int) error {
+	d := sys.rom.fds
+	if d == nil {
+		return errors.New("not a disk image")
+	}
+	if side < 0 || side >= len(d.sides) {
+		return errors.New("invalid disk side")
+	}
+	d.insert(int32(side))
+	return nil
+}
+
+func (sys *Sys) IsFdsImageDirty() bool {
+	return sys.rom.fds != nil && sys.rom.fds.dirty
+}
+
+// SaveFdsImage writes the disk with the changes made by the game as an
+// image with fwNES header.
+func (sys *Sys) SaveFdsImage(w io.Writer) error {
+	d := sys.rom.fds
+	if d == nil {
+		return errors.New("not a disk image")
+	}
+	header := NesFileHeader{Magic: 0x1a534446, NPromPage: byte(len(d.sides))}
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	for _, side := range d.sides {
+		if _, err := w.Write(fdsStripGaps(side)); err != nil {
+			return err
+		}
+	}
+	d.dirty = false
+	return nil
+}
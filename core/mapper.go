@@ -8,6 +8,10 @@ type Mapper interface {
 	writeEx(addr uint16, data byte)
 	readLow(addr uint16) byte
 	writeLow(addr uint16, data byte)
+	// peek reads an address from $4018 on without side effects, for those
+	// whose read has any or does not come from readEx, readLow or cpuBanks
+	// as the others do; ok is false for the others.
+	peek(addr uint16) (data byte, ok bool)
 	read(addr uint16) byte
 	write(addr uint16, data byte)
 
@@ -19,6 +23,15 @@ type Mapper interface {
 	ppuExtLatchX(x byte)
 	ppuExtLatchSpOfs() byte
 	ppuExtLatch(iNameTbl uint16, chL *byte, chH *byte, attr *byte)
+	// ppuA12Rise is called by the dot renderer on each rise of the ppu
+	// address line a12, with how long, in sys cycles, it was low before.
+	ppuA12Rise(nLowCycle int64)
+
+	serialize(s *stateBuf)
+	saveRam() []byte
+	// saveRamEx is the ram of the chips of the cartridge kept by its battery
+	// along with saveRam, as the sound ram of the namco 163.
+	saveRamEx() []byte
 }
 
 func newMapperNil(bm *baseMapper) Mapper { return nil }
@@ -28,7 +41,7 @@ var mapperTable = [256]func(bm *baseMapper) Mapper{
 	newMapper000, newMapper001, newMapper002, newMapper003, newMapper004, newMapper005, newMapper006, newMapper007,
 	newMapper008, newMapper009, newMapper010, newMapper011, newMapper012, newMapper013, newMapperNil, newMapper015,
 	// 1x
-	newMapper016, newMapper017, newMapper018, newMapper019, newMapperNil, newMapper021, newMapper022, newMapper023,
+	newMapper016, newMapper017, newMapper018, newMapper019, newMapper020, newMapper021, newMapper022, newMapper023,
 	newMapper024, newMapper025, newMapper026, newMapper027, newMapperNil, newMapperNil, newMapperNil, newMapperNil,
 	// 2x
 	newMapper032, newMapper033, newMapper034, newMapperNil, newMapperNil, newMapperNil, newMapperNil, newMapperNil,
@@ -91,7 +104,12 @@ func newMapper(sys *Sys) (Mapper, error) {
 	bm.nVrom1kPage = sys.mem.nVrom1kPage
 	bm.cpuBanks = sys.mem.cpuBanks[:]
 
-	m := mapperTable[sys.rom.mapperNo](bm)
+	var m Mapper
+	if sys.rom.nsf != nil {
+		m = newMapperNsf(bm)
+	} else if sys.rom.mapperNo < uint16(len(mapperTable)) {
+		m = mapperTable[sys.rom.mapperNo](bm)
+	}
 	if nil == m {
 		return nil, fmt.Errorf("unsupported mapper #%d", sys.rom.mapperNo)
 	}
@@ -107,6 +125,7 @@ func (m *baseMapper) readLow(addr uint16) byte {
 	}
 	return byte(addr >> 8)
 }
+func (m *baseMapper) peek(addr uint16) (byte, bool) { return 0, false }
 func (m *baseMapper) writeLow(addr uint16, data byte) {
 	if addr >= 0x6000 {
 		m.cpuBanks[addr>>13][addr&0x1fff] = data
@@ -127,6 +146,19 @@ func (m *baseMapper) ppuExtLatchSpOfs() byte  { return 0 }
 func (m *baseMapper) ppuExtLatch(
 	iNameTbl uint16, chL *byte, chH *byte, attr *byte) {
 }
+func (m *baseMapper) ppuA12Rise(nLowCycle int64) {}
+
+func (m *baseMapper) serialize(s *stateBuf) {}
+func (m *baseMapper) saveRam() []byte {
+	if m.sys.rom.bSaveRam {
+		if n := m.sys.rom.info.PnvramSize; n != 0 && n < uint32(len(m.mem.wram)) {
+			return m.mem.wram[:n]
+		}
+		return m.mem.wram[:0x2000]
+	}
+	return nil
+}
+func (m *baseMapper) saveRamEx() []byte { return nil }
 
 func (m *baseMapper) setIntr() {
 	m.sys.cpu.intr |= cpuIntrTypMapper
@@ -139,3 +171,25 @@ func (m *baseMapper) clearIntr() {
 func (m *baseMapper) isPpuDisp() bool {
 	return m.sys.ppu.reg1&(ppuReg1BgDisp|ppuReg1SpDisp) != 0
 }
+
+// the MMC3 and its clones count a rise of a12 only after it was low for more
+// than three cycles of M2, which filters out those between the fetches of a
+// line
+const mapperA12LowCycle = 3 * 12
+
+// isA12Clocked tells whether the scanline counters are clocked by ppuA12Rise,
+// in the dot render mode, or by hSync, the other renderers making no rises.
+func (m *baseMapper) isA12Clocked() bool {
+	return m.sys.renderMode == RenderModeDot
+}
+
+func (m *baseMapper) isA12Count(nLowCycle int64) bool {
+	return nLowCycle > mapperA12LowCycle
+}
+
+// isIrqLine tells whether a scanline counter clocked on the scanline counts
+// it: any filtered rise of a12 does, an hSync only on the lines the ppu
+// renders.
+func (m *baseMapper) isIrqLine(scanline uint16) bool {
+	return m.isA12Clocked() || scanline < ScreenHeight && m.isPpuDisp()
+}
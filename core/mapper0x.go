@@ -38,6 +38,17 @@ func newMapper001(bm *baseMapper) Mapper {
 	return &mapper001{baseMapper: *bm}
 }
 
+func (m *mapper001) serialize(s *stateBuf) {
+	s.bool(&m.largeTyp)
+	s.u8(&m.wramTyp)
+	s.bytes(m.reg[:])
+	s.u8(&m.regBuf)
+	s.u8(&m.shift)
+	s.u8(&m.wramCnt)
+	s.u8(&m.wramBank)
+	s.u16(&m.prevAddr)
+}
+
 func (m *mapper001) reset() {
 	patch := m.sys.conf.PatchTyp
 	if patch&0x01 != 0 {
@@ -59,6 +70,13 @@ func (m *mapper001) reset() {
 	}
 }
 
+func (m *mapper001) saveRam() []byte {
+	if m.sys.rom.bSaveRam && m.wramTyp != 0 && len(m.mem.wram) >= 0x4000 {
+		return m.mem.wram[:0x4000]
+	}
+	return m.baseMapper.saveRam()
+}
+
 func (m *mapper001) setVramMirror() {
 	if m.reg[0]&0x02 != 0 {
 		if m.reg[0]&0x01 != 0 {
@@ -81,9 +99,9 @@ func (m *mapper001) write(addr uint16, data byte) {
 		m.wramBank += data & 0x01
 		if m.wramCnt == 5 {
 			if m.wramBank != 0 {
-				m.mem.setCpuBank(3, m.mem.wram[0x2000:], memBankTypRam)
+				m.mem.setWram8kBank(3, 1)
 			} else {
-				m.mem.setCpuBank(3, m.mem.wram[:], memBankTypRam)
+				m.mem.setWram8kBank(3, 0)
 			}
 			m.wramCnt, m.wramBank = 0, 0
 		}
@@ -142,9 +160,9 @@ func (m *mapper001) write(addr uint16, data byte) {
 	} else {
 		if m.wramTyp == 2 {
 			if m.reg[1]&0x18 != 0 {
-				m.mem.setCpuBank(3, m.mem.wram[0x2000:], memBankTypRam)
+				m.mem.setWram8kBank(3, 1)
 			} else {
-				m.mem.setCpuBank(3, m.mem.wram[0x2000:], memBankTypRam)
+				m.mem.setWram8kBank(3, 1)
 			}
 		}
 
@@ -196,6 +214,10 @@ func newMapper002(bm *baseMapper) Mapper {
 	return &mapper002{baseMapper: *bm}
 }
 
+func (m *mapper002) serialize(s *stateBuf) {
+	s.u8(&m.patchTyp)
+}
+
 func (m *mapper002) reset() {
 	patch := byte(m.sys.conf.PatchTyp)
 	if patch&0x01 != 0 {
@@ -266,6 +288,20 @@ func newMapper004(bm *baseMapper) Mapper {
 	return &mapper004{baseMapper: *bm}
 }
 
+func (m *mapper004) serialize(s *stateBuf) {
+	s.u8(&m.irqTyp)
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.bool(&m.irqReq)
+	s.bool(&m.irqPre)
+	s.bool(&m.irqPreVbl)
+	s.u8(&m.p0)
+	s.u8(&m.p1)
+	s.u8(&m.r)
+	s.bytes(m.c[:])
+}
+
 func (m *mapper004) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p1), uint32(m.p0), m.nProm8kPage-1)
@@ -403,9 +439,22 @@ func (m *mapper004) write(addr uint16, data byte) {
 }
 
 func (m *mapper004) hSync(scanline uint16) {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper004) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+// irqClock runs the scanline counter, on an hSync or a rise of a12.
+func (m *mapper004) irqClock(scanline uint16) {
 	switch m.irqTyp {
 	case 1:
-		if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+		if m.isIrqLine(scanline) && m.irqEn {
 			if m.irqCnt == 0 {
 				m.irqCnt, m.irqReq = m.irqLatch, true
 			}
@@ -417,7 +466,7 @@ func (m *mapper004) hSync(scanline uint16) {
 			m.setIntr()
 		}
 	case 5:
-		if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+		if m.isIrqLine(scanline) && m.irqEn {
 			m.irqCnt--
 			if m.irqCnt == 0 {
 				m.irqCnt, m.irqReq = m.irqLatch, true
@@ -427,7 +476,7 @@ func (m *mapper004) hSync(scanline uint16) {
 			m.setIntr()
 		}
 	default:
-		if scanline < ScreenHeight && m.isPpuDisp() {
+		if m.isIrqLine(scanline) {
 			if m.irqPreVbl {
 				m.irqCnt, m.irqPreVbl = m.irqLatch, false
 			}
@@ -471,16 +520,55 @@ type mapper005 struct {
 	multA, multB         byte
 	splitX, splitY       byte
 	splitAddr            uint16
+	pcmRead, pcmIrqEn    bool
+	pcmIrq               bool
 
 	ntTyps  [4]byte
 	c0, c1  [8]byte
 	bgBanks [8][]byte
+	snd     *apuChanMmc5
 }
 
 func newMapper005(bm *baseMapper) Mapper {
 	return &mapper005{baseMapper: *bm}
 }
 
+func (m *mapper005) serialize(s *stateBuf) {
+	s.u8(&m.sramSize)
+	s.bool(&m.irqPatch)
+	s.bool(&m.chrPatch)
+	s.u8(&m.prgSize)
+	s.u8(&m.chrSize)
+	s.bool(&m.sramWEA)
+	s.bool(&m.sramWEB)
+	s.u8(&m.graphMode)
+	s.u8(&m.fillChr)
+	s.u8(&m.fillPal)
+	s.bool(&m.chrMode)
+	s.u8(&m.splitCont)
+	s.u8(&m.splitScrl)
+	s.u8(&m.splitPage)
+	s.bool(&m.irqEn)
+	s.u8(&m.irqStatus)
+	s.u8(&m.irqClear)
+	s.u8(&m.irqLine)
+	s.u8(&m.irqScanline)
+	s.u8(&m.multA)
+	s.u8(&m.multB)
+	s.u8(&m.splitX)
+	s.u8(&m.splitY)
+	s.u16(&m.splitAddr)
+	s.bool(&m.pcmRead)
+	s.bool(&m.pcmIrqEn)
+	s.bool(&m.pcmIrq)
+	s.bytes(m.ntTyps[:])
+	s.bytes(m.c0[:])
+	s.bytes(m.c1[:])
+	for i := range m.bgBanks {
+		s.bank(&m.bgBanks[i], 0x0400)
+	}
+}
+
 func (m *mapper005) setCpuBankAlt(iBank byte, data byte) {
 	switch m.sramSize {
 	case 0:
@@ -501,7 +589,7 @@ func (m *mapper005) setCpuBankAlt(iBank byte, data byte) {
 		}
 	}
 	if data != 8 {
-		m.mem.setCpuBank(iBank, m.mem.wram[uint32(data)<<13:], memBankTypRam)
+		m.mem.setWram8kBank(iBank, uint32(data))
 	} else {
 		m.mem.cpuBanksTyp[iBank] = memBankTypRom
 	}
@@ -530,6 +618,7 @@ func (m *mapper005) reset() {
 	m.irqEn = false
 	m.irqStatus, m.irqClear, m.irqLine, m.irqScanline = 0, 0, 0, 0
 	m.multA, m.multB = 0, 0
+	m.pcmRead, m.pcmIrqEn, m.pcmIrq = false, false, false
 
 	m.ntTyps[0], m.ntTyps[1], m.ntTyps[2], m.ntTyps[3] = 0, 0, 0, 0
 	for i := byte(0); i < 8; i++ {
@@ -544,12 +633,50 @@ func (m *mapper005) reset() {
 	m.mem.setVrom8kBank(0)
 	m.setCpuBankAlt(3, 0)
 	m.sys.ppu.bExtLatch = true
+
+	if m.snd == nil {
+		m.snd = newApuChanMmc5(m.sys.apu)
+	}
+	m.sys.apu.ex = m.snd
+}
+
+func (m *mapper005) saveRam() []byte {
+	if m.sys.rom.bSaveRam {
+		if n := 0x2000 << m.sramSize; n < len(m.mem.wram) {
+			return m.mem.wram[:n]
+		}
+		return m.mem.wram
+	}
+	return nil
+}
+
+func (m *mapper005) peek(addr uint16) (byte, bool) {
+	switch addr {
+	case 0x5010:
+		if m.pcmIrq {
+			return 0x80, true
+		}
+		return 0, true
+	case 0x5204:
+		return m.irqStatus, true
+	}
+	return 0, false
 }
 
 func (m *mapper005) readLow(addr uint16) byte {
 	switch addr {
+	case 0x5010:
+		var data byte
+		if m.pcmIrq {
+			data = 0x80
+		}
+		m.pcmIrq = false
+		if m.irqStatus&0x80 == 0 || !m.irqEn {
+			m.clearIntr()
+		}
+		return data
 	case 0x5015:
-		return 0
+		return m.snd.status()
 	case 0x5204:
 		data := m.irqStatus
 		m.irqStatus &^= 0x80
@@ -624,6 +751,14 @@ func (m *mapper005) setPpuBanks() {
 
 func (m *mapper005) writeLow(addr uint16, data byte) {
 	switch addr {
+	case 0x5000, 0x5002, 0x5003, 0x5004, 0x5006, 0x5007, 0x5015:
+		m.snd.write(addr, data)
+	case 0x5010:
+		m.pcmRead, m.pcmIrqEn = data&0x01 != 0, data&0x80 != 0
+	case 0x5011:
+		if !m.pcmRead {
+			m.snd.write(addr, data)
+		}
 	case 0x5100:
 		m.prgSize = data & 0x03
 	case 0x5101:
@@ -740,6 +875,22 @@ func (m *mapper005) writeLow(addr uint16, data byte) {
 	}
 }
 
+// read feeds the pcm in its read mode, from where a 0 raises the irq instead.
+func (m *mapper005) read(addr uint16) byte {
+	data := m.cpuBanks[addr>>13][addr&0x1fff]
+	if m.pcmRead && addr < 0xc000 {
+		if data != 0 {
+			m.snd.write(0x5011, data)
+		} else {
+			m.pcmIrq = true
+			if m.pcmIrqEn {
+				m.setIntr()
+			}
+		}
+	}
+	return data
+}
+
 func (m *mapper005) write(addr uint16, data byte) {
 	if addr >= 0x8000 && addr < 0xe000 {
 		i := addr >> 13
@@ -749,6 +900,10 @@ func (m *mapper005) write(addr uint16, data byte) {
 	}
 }
 
+func (m *mapper005) clock(nCycle int64) {
+	m.snd.sync(int32(nCycle))
+}
+
 func (m *mapper005) hSync(scanline uint16) {
 	if m.irqPatch && m.irqScanline == m.irqLine {
 		m.irqStatus |= 0x80
@@ -874,6 +1029,11 @@ func newMapper006(bm *baseMapper) Mapper {
 	return &mapper006{baseMapper: *bm}
 }
 
+func (m *mapper006) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u64(&m.irqCnt)
+}
+
 func (m *mapper006) reset() {
 	m.mem.setProm32kBank4(0, 1, 14, 15)
 	if m.nVrom1kPage != 0 {
@@ -984,6 +1144,12 @@ func newMapper009(bm *baseMapper) Mapper {
 	return &mapper009{baseMapper: *bm}
 }
 
+func (m *mapper009) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+	s.bool(&m.latchA)
+	s.bool(&m.latchB)
+}
+
 func (m *mapper009) reset() {
 	m.mem.setProm32kBank4(0, m.nProm8kPage-3, m.nProm8kPage-2, m.nProm8kPage-1)
 	m.r[0], m.r[1], m.r[2], m.r[3] = 0, 4, 0, 0
@@ -1064,6 +1230,12 @@ func newMapper010(bm *baseMapper) Mapper {
 	return &mapper010{baseMapper: *bm}
 }
 
+func (m *mapper010) serialize(s *stateBuf) {
+	s.u8(&m.latchA)
+	s.u8(&m.latchB)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper010) reset() {
 	m.latchA, m.latchB = 0xfe, 0xfe
 	m.r[0], m.r[1], m.r[2], m.r[3] = 0, 4, 0, 0
@@ -1166,6 +1338,18 @@ func newMapper012(bm *baseMapper) Mapper {
 	return &mapper012{baseMapper: *bm}
 }
 
+func (m *mapper012) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.bool(&m.irqPreset)
+	s.bool(&m.irqPresetVbl)
+	s.u8(&m.r)
+	s.u32s(m.vb[:])
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper012) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -1275,7 +1459,19 @@ func (m *mapper012) write(addr uint16, data byte) {
 }
 
 func (m *mapper012) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper012) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper012) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) {
 		if m.irqPresetVbl {
 			m.irqCnt, m.irqPresetVbl = m.irqLatch, false
 		}
@@ -21,6 +21,17 @@ func (r *mapper016Eeprom1) reset(sl []byte) {
 	r.sl = sl
 }
 
+func (r *mapper016Eeprom1) serialize(s *stateBuf) {
+	s.bool(&r.sda)
+	s.bool(&r.prevScl)
+	s.bool(&r.prevSda)
+	s.u8(&r.state)
+	s.u8(&r.nextState)
+	s.u8(&r.n)
+	s.u8(&r.addr)
+	s.u8(&r.data)
+}
+
 func (r *mapper016Eeprom1) write(scl bool, sda bool) {
 	sclRise, sclFall := !r.prevScl && scl, r.prevScl && !scl
 	sdaRise, sdaFall := !r.prevSda && sda, r.prevSda && !sda
@@ -117,6 +128,18 @@ func (r *mapper016Eeprom2) reset(sl []byte) {
 	r.sl = sl
 }
 
+func (r *mapper016Eeprom2) serialize(s *stateBuf) {
+	s.bool(&r.sda)
+	s.bool(&r.prevScl)
+	s.bool(&r.prevSda)
+	s.bool(&r.rw)
+	s.u8(&r.state)
+	s.u8(&r.nextState)
+	s.u8(&r.n)
+	s.u8(&r.addr)
+	s.u8(&r.data)
+}
+
 func (r *mapper016Eeprom2) write(scl bool, sda bool) {
 	sclRise, sclFall := !r.prevScl && scl, r.prevScl && !scl
 	sdaRise, sdaFall := !r.prevSda && sda, r.prevSda && !sda
@@ -233,6 +256,20 @@ func newMapper016(bm *baseMapper) Mapper {
 	return &mapper016{baseMapper: *bm}
 }
 
+func (m *mapper016) serialize(s *stateBuf) {
+	s.bool(&m.patch1)
+	s.u8(&m.eepTyp)
+	s.bool(&m.irqEn)
+	s.bool(&m.irqClkTyp)
+	s.i32(&m.irqCnt)
+	s.i32(&m.irqLatch)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+	m.eep1.serialize(s)
+	m.eep2.serialize(s)
+}
+
 func (m *mapper016) reset() {
 	patch := m.sys.conf.PatchTyp
 	m.patch1 = patch&0x01 != 0
@@ -266,6 +303,21 @@ func (m *mapper016) reset() {
 	}
 }
 
+func (m *mapper016) saveRam() []byte {
+	if m.patch1 {
+		return m.baseMapper.saveRam()
+	}
+	switch m.eepTyp {
+	case 0:
+		return m.mem.wram[:0x0080]
+	case 1:
+		return m.mem.wram[:0x0100]
+	case 2:
+		return m.mem.wram[:0x0180]
+	}
+	return nil
+}
+
 func (m *mapper016) readLow(addr uint16) byte {
 	if m.patch1 {
 		return m.baseMapper.readLow(addr)
@@ -417,6 +469,12 @@ func newMapper017(bm *baseMapper) Mapper {
 	return &mapper017{baseMapper: *bm}
 }
 
+func (m *mapper017) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u32(&m.irqCnt)
+	s.u32(&m.irqLatch)
+}
+
 func (m *mapper017) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch = false, 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -482,6 +540,14 @@ func newMapper018(bm *baseMapper) Mapper {
 	return &mapper018{baseMapper: *bm}
 }
 
+func (m *mapper018) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqMode)
+	s.i32(&m.irqCnt)
+	s.i32(&m.irqLatch)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper018) reset() {
 	m.irqEn, m.irqMode = false, 0
 	m.irqCnt, m.irqLatch = 0xffff, 0xffff
@@ -568,13 +634,23 @@ type mapper019 struct {
 	patchTyp byte
 	irqEn    bool
 	irqCnt   uint16
-	r0, r1   byte
+	r0       byte
+	port     apuN163Port
+	snd      *apuChanN163
 }
 
 func newMapper019(bm *baseMapper) Mapper {
 	return &mapper019{baseMapper: *bm}
 }
 
+func (m *mapper019) serialize(s *stateBuf) {
+	s.u8(&m.patchTyp)
+	s.bool(&m.irqEn)
+	s.u16(&m.irqCnt)
+	s.u8(&m.r0)
+	m.port.serialize(s)
+}
+
 func (m *mapper019) reset() {
 	patch := m.sys.conf.PatchTyp
 	if patch&0x01 != 0 {
@@ -585,22 +661,33 @@ func (m *mapper019) reset() {
 		m.patchTyp = 3
 	}
 	m.irqEn, m.irqCnt = false, 0
-	m.r0, m.r1 = 0, 0
+	m.r0, m.port.addr = 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage >= 8 {
 		m.mem.setVrom8kBank((m.nVrom1kPage >> 3) - 1)
 	}
+	if m.snd == nil {
+		m.snd = newApuChanN163(m.sys.apu)
+	}
+	m.sys.apu.ex = m.snd
+}
+
+func (m *mapper019) saveRamEx() []byte {
+	return m.port.ram[:]
+}
+
+func (m *mapper019) peek(addr uint16) (byte, bool) {
+	if addr == 0x4800 {
+		return m.port.ram[m.port.addr&0x7f], true
+	}
+	return 0, false
 }
 
 func (m *mapper019) readLow(addr uint16) byte {
 	switch addr & 0xf800 {
 	case 0x4800:
 		if addr == 0x4800 {
-			data := m.mem.wram[m.r1&0x7f]
-			if m.r1&0x80 != 0 {
-				m.r1 = (m.r1 + 1) | 0x80
-			}
-			return data
+			return m.port.read()
 		}
 	case 0x5000:
 		return byte(m.irqCnt)
@@ -616,10 +703,7 @@ func (m *mapper019) writeLow(addr uint16, data byte) {
 	switch addr & 0xf800 {
 	case 0x4800:
 		if addr == 0x4800 {
-			m.mem.wram[m.r1&0x7f] = data
-			if m.r1&0x80 != 0 {
-				m.r1 = (m.r1 + 1) | 0x80
-			}
+			m.port.write(m.sys.apu, data)
 		}
 	case 0x5000:
 		m.irqCnt = (m.irqCnt & 0xff00) | uint16(data)
@@ -675,7 +759,7 @@ func (m *mapper019) write(addr uint16, data byte) {
 		m.mem.setProm8kBank(5, uint32(data&0x3f))
 	case 0xf800:
 		if addr == 0xf800 {
-			m.r1 = data
+			m.port.addr = data
 		}
 	}
 }
@@ -690,6 +774,329 @@ func (m *mapper019) clock(nCycle int64) {
 	}
 }
 
+// 020
+
+type mapper020 struct {
+	baseMapper
+	disk *fdsDisk
+	snd  *apuChanFds
+
+	irqEn         bool
+	irqRepeat     bool
+	irqTimerOccur bool
+	irqDiskOccur  bool
+	irqReload     uint16
+	irqCnt        uint16
+
+	diskRegEn  bool
+	soundRegEn bool
+	motorOn    bool
+	xferReset  bool
+	readMode   bool
+	crcCtrl    bool
+	diskReady  bool
+	diskIrqEn  bool
+
+	prevCrcCtrl bool
+	gapEnded    bool
+	scanning    bool
+	endOfHead   bool
+	xferDone    bool
+	readData    byte
+	writeData   byte
+	extData     byte
+	crc         uint16
+	delay       int32
+	pos         int32
+}
+
+func newMapper020(bm *baseMapper) Mapper {
+	return &mapper020{baseMapper: *bm, disk: bm.sys.rom.fds}
+}
+
+func (m *mapper020) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.bool(&m.irqRepeat)
+	s.bool(&m.irqTimerOccur)
+	s.bool(&m.irqDiskOccur)
+	s.u16(&m.irqReload)
+	s.u16(&m.irqCnt)
+	s.bool(&m.diskRegEn)
+	s.bool(&m.soundRegEn)
+	s.bool(&m.motorOn)
+	s.bool(&m.xferReset)
+	s.bool(&m.readMode)
+	s.bool(&m.crcCtrl)
+	s.bool(&m.diskReady)
+	s.bool(&m.diskIrqEn)
+	s.bool(&m.prevCrcCtrl)
+	s.bool(&m.gapEnded)
+	s.bool(&m.scanning)
+	s.bool(&m.endOfHead)
+	s.bool(&m.xferDone)
+	s.u8(&m.readData)
+	s.u8(&m.writeData)
+	s.u8(&m.extData)
+	s.u16(&m.crc)
+	s.i32(&m.delay)
+	s.i32(&m.pos)
+	m.disk.serialize(s)
+}
+
+func (m *mapper020) reset() {
+	for i := byte(3); i < 7; i++ {
+		m.mem.setWram8kBank(i, uint32(i-3))
+	}
+	m.mem.setProm8kBank(7, 0)
+
+	m.irqEn, m.irqRepeat, m.irqTimerOccur, m.irqDiskOccur = false, false, false, false
+	m.irqReload, m.irqCnt = 0, 0
+	m.diskRegEn, m.soundRegEn = true, true
+	m.motorOn, m.xferReset, m.readMode, m.crcCtrl = false, false, true, false
+	m.diskReady, m.diskIrqEn = false, false
+	m.prevCrcCtrl, m.gapEnded, m.scanning, m.endOfHead, m.xferDone = false, false, false, true, false
+	m.readData, m.writeData, m.extData = 0, 0, 0
+	m.crc, m.delay, m.pos = 0, 0, 0
+
+	if m.snd == nil {
+		m.snd = newApuChanFds(m.sys.apu)
+	}
+	m.sys.apu.ex = m.snd
+}
+
+func (m *mapper020) updateIntr() {
+	if m.irqTimerOccur || m.irqDiskOccur {
+		m.setIntr()
+	} else {
+		m.clearIntr()
+	}
+}
+
+func (m *mapper020) peek(addr uint16) (byte, bool) {
+	if !m.diskRegEn {
+		return 0, false
+	}
+	switch addr {
+	case 0x4030:
+		var data byte
+		if m.irqTimerOccur {
+			data |= 0x01
+		}
+		if m.xferDone {
+			data |= 0x02
+		}
+		return data | 0x40, true
+	case 0x4031:
+		return m.readData, true
+	}
+	return 0, false
+}
+
+func (m *mapper020) readEx(addr uint16) byte {
+	if addr >= 0x4040 {
+		if m.soundRegEn && addr <= 0x4092 {
+			return m.snd.read(addr)
+		}
+		return byte(addr >> 8)
+	}
+	if !m.diskRegEn {
+		return byte(addr >> 8)
+	}
+	switch addr {
+	case 0x4030:
+		var data byte
+		if m.irqTimerOccur {
+			data |= 0x01
+		}
+		if m.xferDone {
+			data |= 0x02
+		}
+		m.xferDone, m.irqTimerOccur, m.irqDiskOccur = false, false, false
+		m.updateIntr()
+		return data | 0x40
+	case 0x4031:
+		m.xferDone, m.irqDiskOccur = false, false
+		m.updateIntr()
+		return m.readData
+	case 0x4032:
+		data := byte(0x40)
+		if m.disk.side < 0 {
+			data |= 0x07
+		} else if !m.scanning {
+			data |= 0x02
+		}
+		return data
+	case 0x4033:
+		// battery always good
+		return m.extData & 0x80
+	}
+	return byte(addr >> 8)
+}
+
+func (m *mapper020) writeEx(addr uint16, data byte) {
+	if addr >= 0x4040 {
+		if m.soundRegEn {
+			m.sys.apu.writeEx(addr, data)
+		}
+		return
+	}
+	if !m.diskRegEn && addr >= 0x4024 && addr <= 0x4026 {
+		return
+	}
+	switch addr {
+	case 0x4020:
+		m.irqReload = (m.irqReload & 0xff00) | uint16(data)
+	case 0x4021:
+		m.irqReload = (m.irqReload & 0x00ff) | (uint16(data) << 8)
+	case 0x4022:
+		m.irqRepeat = data&0x01 != 0
+		m.irqEn = data&0x02 != 0 && m.diskRegEn
+		if m.irqEn {
+			m.irqCnt = m.irqReload
+		} else {
+			m.irqTimerOccur = false
+			m.updateIntr()
+		}
+	case 0x4023:
+		m.diskRegEn, m.soundRegEn = data&0x01 != 0, data&0x02 != 0
+		if !m.diskRegEn {
+			m.irqEn, m.irqTimerOccur, m.irqDiskOccur = false, false, false
+			m.updateIntr()
+		}
+	case 0x4024:
+		m.writeData, m.xferDone, m.irqDiskOccur = data, false, false
+		m.updateIntr()
+	case 0x4025:
+		m.motorOn = data&0x01 != 0
+		m.xferReset = data&0x02 != 0
+		m.readMode = data&0x04 != 0
+		m.crcCtrl = data&0x10 != 0
+		m.diskReady = data&0x40 != 0
+		m.diskIrqEn = data&0x80 != 0
+		if data&0x08 != 0 {
+			m.mem.setVramMirror(memVramMirrorH)
+		} else {
+			m.mem.setVramMirror(memVramMirrorV)
+		}
+		m.irqDiskOccur = false
+		m.updateIntr()
+	case 0x4026:
+		m.extData = data
+	}
+}
+
+func (m *mapper020) write(addr uint16, data byte) {
+	if addr < 0xe000 {
+		m.cpuBanks[addr>>13][addr&0x1fff] = data
+	}
+}
+
+func (m *mapper020) updateCrc(data byte) {
+	for n := byte(0x01); n != 0; n <<= 1 {
+		c := m.crc & 0x01
+		m.crc >>= 1
+		if c != 0 {
+			m.crc ^= 0x8408
+		}
+		if data&n != 0 {
+			m.crc ^= 0x8000
+		}
+	}
+}
+
+// clockDisk moves the head by one cpu cycle. A byte passes under it every
+// 150 cycles, roughly the 96.4 kbit/s of the real drive.
+func (m *mapper020) clockDisk() {
+	side := m.disk.data()
+	if side == nil || !m.motorOn {
+		m.endOfHead, m.scanning = true, false
+		return
+	}
+	if m.xferReset && !m.scanning {
+		return
+	}
+	if m.endOfHead {
+		m.delay, m.endOfHead, m.pos, m.gapEnded = 50000, false, 0, false
+		return
+	}
+	if m.delay > 0 {
+		m.delay--
+		return
+	}
+
+	m.scanning = true
+	needIrq := m.diskIrqEn
+	if m.readMode {
+		data := side[m.pos]
+		if !m.prevCrcCtrl {
+			m.updateCrc(data)
+		}
+		if !m.diskReady {
+			m.gapEnded, m.crc = false, 0
+		} else if data != 0 && !m.gapEnded {
+			m.gapEnded, needIrq = true, false
+		}
+		if m.gapEnded {
+			m.xferDone, m.readData = true, data
+			if needIrq {
+				m.irqDiskOccur = true
+				m.updateIntr()
+			}
+		}
+	} else {
+		var data byte
+		if !m.crcCtrl {
+			m.xferDone, data = true, m.writeData
+			if needIrq {
+				m.irqDiskOccur = true
+				m.updateIntr()
+			}
+		}
+		if !m.diskReady {
+			data = 0
+		}
+		if !m.crcCtrl {
+			m.updateCrc(data)
+		} else {
+			if !m.prevCrcCtrl {
+				m.updateCrc(0)
+				m.updateCrc(0)
+			}
+			data = byte(m.crc)
+			m.crc >>= 8
+		}
+		// the byte latched two transfers ago is the one being written
+		if i := m.pos - 2; i >= 0 && side[i] != data {
+			side[i], m.disk.dirty = data, true
+		}
+		m.gapEnded = false
+	}
+	m.prevCrcCtrl = m.crcCtrl
+
+	if m.pos++; int(m.pos) >= len(side) {
+		m.motorOn = false
+	} else {
+		m.delay = 149
+	}
+}
+
+func (m *mapper020) clock(nCycle int64) {
+	m.disk.clock(int32(nCycle))
+	for i := int64(0); i < nCycle; i++ {
+		if m.irqEn {
+			if m.irqCnt == 0 {
+				m.irqCnt = m.irqReload
+				m.irqEn = m.irqRepeat
+				m.irqTimerOccur = true
+				m.updateIntr()
+			} else {
+				m.irqCnt--
+			}
+		}
+		m.clockDisk()
+	}
+}
+
 // 021
 
 type mapper021 struct {
@@ -705,6 +1112,14 @@ func newMapper021(bm *baseMapper) Mapper {
 	return &mapper021{baseMapper: *bm}
 }
 
+func (m *mapper021) serialize(s *stateBuf) {
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.i16(&m.irqClk)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper021) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch, m.irqClk = 0, 0, 0, 0
 	for i := byte(0); i < 8; i++ {
@@ -841,6 +1256,15 @@ func newMapper023(bm *baseMapper) Mapper {
 	return &mapper023{baseMapper: *bm}
 }
 
+func (m *mapper023) serialize(s *stateBuf) {
+	s.u16(&m.addrMask)
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper023) reset() {
 	m.addrMask = 0xffff
 	if m.sys.conf.PatchTyp&0x01 != 0 {
@@ -928,18 +1352,30 @@ type mapper024 struct {
 	irqCnt   byte
 	irqLatch byte
 	irqClk   uint16
+	snd      *apuChanVrc6
 }
 
 func newMapper024(bm *baseMapper) Mapper {
 	return &mapper024{baseMapper: *bm}
 }
 
+func (m *mapper024) serialize(s *stateBuf) {
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+}
+
 func (m *mapper024) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch, m.irqClk = 0, 0, 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage != 0 {
 		m.mem.setVrom8kBank(0)
 	}
+	if m.snd == nil {
+		m.snd = newApuChanVrc6(m.sys.apu)
+	}
+	m.sys.apu.ex = m.snd
 	m.sys.renderMode = RenderModePost
 }
 
@@ -948,6 +1384,8 @@ func (m *mapper024) write(addr uint16, data byte) {
 	switch addr {
 	case 0x8000:
 		m.mem.setProm16kBank(4, uint32(data))
+	case 0x9000, 0x9001, 0x9002, 0x9003, 0xa000, 0xa001, 0xa002, 0xb000, 0xb001, 0xb002:
+		m.sys.apu.writeEx(addr, data)
 	case 0xb003:
 		switch data & 0x0c {
 		case 0x00:
@@ -1012,6 +1450,17 @@ func newMapper025(bm *baseMapper) Mapper {
 	return &mapper025{baseMapper: *bm}
 }
 
+func (m *mapper025) serialize(s *stateBuf) {
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+	s.u8(&m.r3)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper025) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch, m.irqClk = 0, 0, 0, 0
 	for i := 0; i < 8; i++ {
@@ -1101,18 +1550,30 @@ type mapper026 struct {
 	irqCnt   byte
 	irqLatch byte
 	irqClk   uint16
+	snd      *apuChanVrc6
 }
 
 func newMapper026(bm *baseMapper) Mapper {
 	return &mapper026{baseMapper: *bm}
 }
 
+func (m *mapper026) serialize(s *stateBuf) {
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+}
+
 func (m *mapper026) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch, m.irqClk = 0, 0, 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage != 0 {
 		m.mem.setVrom8kBank(0)
 	}
+	if m.snd == nil {
+		m.snd = newApuChanVrc6(m.sys.apu)
+	}
+	m.sys.apu.ex = m.snd
 }
 
 func (m *mapper026) write(addr uint16, data byte) {
@@ -1120,6 +1581,9 @@ func (m *mapper026) write(addr uint16, data byte) {
 	switch addr {
 	case 0x8000:
 		m.mem.setProm16kBank(4, uint32(data))
+	case 0x9000, 0x9001, 0x9002, 0x9003, 0xa000, 0xa001, 0xa002, 0xb000, 0xb001, 0xb002:
+		// a0 and a1 are swapped relative to mapper 24
+		m.sys.apu.writeEx((addr&0xfffc)|((addr&0x01)<<1)|((addr&0x02)>>1), data)
 	case 0xb003:
 		switch data & 0x7f {
 		case 0x08, 0x2c:
@@ -1182,6 +1646,13 @@ func newMapper027(bm *baseMapper) Mapper {
 	return &mapper027{baseMapper: *bm}
 }
 
+func (m *mapper027) serialize(s *stateBuf) {
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper027) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch = 0, 0, 0
 	for i := byte(0); i < 8; i++ {
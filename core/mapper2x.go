@@ -12,6 +12,11 @@ func newMapper032(bm *baseMapper) Mapper {
 	return &mapper032{baseMapper: *bm}
 }
 
+func (m *mapper032) serialize(s *stateBuf) {
+	s.bool(&m.patchTyp)
+	s.u8(&m.r)
+}
+
 func (m *mapper032) reset() {
 	patch := m.sys.conf.PatchTyp
 	if patch&0x01 != 0 {
@@ -76,6 +81,14 @@ func newMapper033(bm *baseMapper) Mapper {
 	return &mapper033{baseMapper: *bm}
 }
 
+func (m *mapper033) serialize(s *stateBuf) {
+	s.bool(&m.patch)
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper033) setPpuBanks() {
 	m.mem.setVrom2kBank(0, uint32(m.r[0]))
 	m.mem.setVrom2kBank(2, uint32(m.r[1]))
@@ -192,6 +205,11 @@ func newMapper040(bm *baseMapper) Mapper {
 	return &mapper040{baseMapper: *bm}
 }
 
+func (m *mapper040) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.i32(&m.irqLine)
+}
+
 func (m *mapper040) reset() {
 	m.mem.setProm8kBank(3, 6)
 	m.mem.setProm32kBank4(4, 5, 0, 7)
@@ -233,6 +251,11 @@ func newMapper041(bm *baseMapper) Mapper {
 	return &mapper041{baseMapper: *bm}
 }
 
+func (m *mapper041) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper041) reset() {
 	m.mem.setProm32kBank(0)
 	if m.nVrom1kPage != 0 {
@@ -273,6 +296,11 @@ func newMapper042(bm *baseMapper) Mapper {
 	return &mapper042{baseMapper: *bm}
 }
 
+func (m *mapper042) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+}
+
 func (m *mapper042) reset() {
 	m.irqEn, m.irqCnt = false, 0
 	m.mem.setProm8kBank(3, 0)
@@ -328,6 +356,11 @@ func newMapper043(bm *baseMapper) Mapper {
 	return &mapper043{baseMapper: *bm}
 }
 
+func (m *mapper043) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u32(&m.irqCnt)
+}
+
 func (m *mapper043) reset() {
 	m.irqEn, m.irqCnt = true, 0
 	m.mem.setProm8kBank(3, 2)
@@ -402,6 +435,17 @@ func newMapper044(bm *baseMapper) Mapper {
 	return &mapper044{baseMapper: *bm}
 }
 
+func (m *mapper044) serialize(s *stateBuf) {
+	s.u8(&m.bank)
+	s.u8(&m.r)
+	s.u8(&m.p0)
+	s.u8(&m.p1)
+	s.bytes(m.c[:])
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+}
+
 func (m *mapper044) setCpuBanks() {
 	ps := [4]byte{}
 	if m.r&0x40 != 0 {
@@ -512,7 +556,19 @@ func (m *mapper044) write(addr uint16, data byte) {
 }
 
 func (m *mapper044) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper044) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper044) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0 {
 			m.irqCnt = m.irqLatch
@@ -539,6 +595,19 @@ func newMapper045(bm *baseMapper) Mapper {
 	return &mapper045{baseMapper: *bm}
 }
 
+func (m *mapper045) serialize(s *stateBuf) {
+	s.bytes(m.p[:])
+	s.bytes(m.p1[:])
+	s.bytes(m.c[:])
+	s.bytes(m.r[:])
+	s.u32s(m.c1[:])
+	s.bool(&m.irqEn)
+	s.bool(&m.ireReset)
+	s.bool(&m.ireLatched)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+}
+
 func (m *mapper045) setCpuBanks(i, data byte) {
 	data = (data & ((m.r[3] & 0x3f) ^ 0xff) & 0x3f) | m.r[1]
 	m.mem.setProm8kBank(i+4, uint32(data))
@@ -653,7 +722,19 @@ func (m *mapper045) write(addr uint16, data byte) {
 
 func (m *mapper045) hSync(scanline uint16) {
 	m.ireReset = false
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqCnt != 0 {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper045) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper045) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqCnt != 0 {
 		m.irqCnt--
 		if m.irqCnt == 0 && m.irqEn {
 			m.sys.cpu.intr &= cpuIntrTypMapper
@@ -672,6 +753,13 @@ func newMapper046(bm *baseMapper) Mapper {
 	return &mapper046{baseMapper: *bm}
 }
 
+func (m *mapper046) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+	s.u8(&m.r3)
+}
+
 func (m *mapper046) reset() {
 	m.setBank()
 	m.mem.setVramMirror(memVramMirrorV)
@@ -709,6 +797,17 @@ func newMapper047(bm *baseMapper) Mapper {
 	return &mapper047{baseMapper: *bm}
 }
 
+func (m *mapper047) serialize(s *stateBuf) {
+	s.u8(&m.bank)
+	s.u8(&m.r)
+	s.u8(&m.p0)
+	s.u8(&m.p1)
+	s.bytes(m.c[:])
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+}
+
 func (m *mapper047) setCpuBanks() {
 	ps := [4]byte{}
 	if m.r&0x40 != 0 {
@@ -804,7 +903,19 @@ func (m *mapper047) write(addr uint16, data byte) {
 }
 
 func (m *mapper047) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper047) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper047) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0 {
 			m.irqCnt = m.irqLatch
@@ -13,6 +13,12 @@ func newMapper048(bm *baseMapper) Mapper {
 	return &mapper048{baseMapper: *bm}
 }
 
+func (m *mapper048) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.r)
+}
+
 func (m *mapper048) reset() {
 	m.irqEn, m.irqCnt = false, 0
 	m.r = 0
@@ -50,7 +56,19 @@ func (m *mapper048) write(addr uint16, data byte) {
 }
 
 func (m *mapper048) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper048) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper048) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		if m.irqCnt == 0xff {
 			m.sys.cpu.intr |= cpuIntrTypTrig2
 		}
@@ -76,6 +94,18 @@ func newMapper049(bm *baseMapper) Mapper {
 	return &mapper049{baseMapper: *bm}
 }
 
+func (m *mapper049) serialize(s *stateBuf) {
+	s.bool(&m.irqReq)
+	s.bool(&m.irqRel)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.cmd)
+	s.u8(&m.a0)
+	s.u8(&m.a1)
+	s.u8(&m.tmp)
+	s.bytes(m.d[:])
+}
+
 func (m *mapper049) setPpuBanks0a(iBank byte, data byte) { //cwrap
 	if m.nVrom1kPage != 0 {
 		m.mem.setVrom1kBank(iBank, uint32(data))
@@ -200,7 +230,19 @@ func (m *mapper049) write(addr uint16, data byte) {
 }
 
 func (m *mapper049) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqReq && !m.irqRel {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper049) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper049) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqReq && !m.irqRel {
 		if scanline == 0 && m.irqCnt != 0 {
 			m.irqCnt--
 		}
@@ -223,6 +265,10 @@ func newMapper050(bm *baseMapper) Mapper {
 	return &mapper050{baseMapper: *bm}
 }
 
+func (m *mapper050) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+}
+
 func (m *mapper050) reset() {
 	m.irqEn = false
 	m.mem.setProm8kBank(3, 15)
@@ -266,6 +312,11 @@ func newMapper051(bm *baseMapper) Mapper {
 	return &mapper051{baseMapper: *bm}
 }
 
+func (m *mapper051) serialize(s *stateBuf) {
+	s.u8(&m.mode)
+	s.u8(&m.bank)
+}
+
 func (m *mapper051) setBanks() {
 	var mi byte = memVramMirrorV
 	var b0, b1 uint32 = 0, 0
@@ -317,6 +368,14 @@ func newMapper052(bm *baseMapper) Mapper {
 	return &mapper052{baseMapper: *bm}
 }
 
+func (m *mapper052) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper052) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch, m.irqClk = false, 0, 0, 0
 	for i := byte(0); i < 8; i++ {
@@ -396,6 +455,10 @@ func newMapper057(bm *baseMapper) Mapper {
 	return &mapper057{baseMapper: *bm}
 }
 
+func (m *mapper057) serialize(s *stateBuf) {
+	s.u8(&m.r)
+}
+
 func (m *mapper057) reset() {
 	m.mem.setProm32kBank4(0, 1, 0, 1)
 	m.mem.setVrom8kBank(0)
@@ -471,6 +534,11 @@ func newMapper060(bm *baseMapper) Mapper {
 	return &mapper060{baseMapper: *bm}
 }
 
+func (m *mapper060) serialize(s *stateBuf) {
+	s.u8(&m.idx)
+	s.bool(&m.patch)
+}
+
 func (m *mapper060) reset() {
 	switch m.sys.conf.PatchTyp {
 	case 1:
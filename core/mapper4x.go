@@ -17,6 +17,18 @@ func newMapper064(bm *baseMapper) Mapper {
 	return &mapper064{baseMapper: *bm}
 }
 
+func (m *mapper064) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.bool(&m.irqMode)
+	s.bool(&m.irqReset)
+	s.u8(&m.irqLatch)
+	s.i16(&m.irqCnt)
+	s.i16(&m.irqCnt2)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+}
+
 func (m *mapper064) reset() {
 	m.r0, m.r1, m.r2 = 0, 0, 0
 	m.irqEn, m.irqMode, m.irqReset = false, false, false
@@ -133,6 +145,13 @@ func newMapper065(bm *baseMapper) Mapper {
 	return &mapper065{baseMapper: *bm}
 }
 
+func (m *mapper065) serialize(s *stateBuf) {
+	s.bool(&m.patchTyp)
+	s.bool(&m.irqEn)
+	s.i32(&m.irqCnt)
+	s.i32(&m.irqLatch)
+}
+
 func (m *mapper065) reset() {
 	patch := m.sys.conf.PatchTyp
 	if patch&0x01 != 0 {
@@ -252,6 +271,12 @@ func newMapper067(bm *baseMapper) Mapper {
 	return &mapper067{baseMapper: *bm}
 }
 
+func (m *mapper067) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.bool(&m.irqTog)
+	s.i32(&m.irqCnt)
+}
+
 func (m *mapper067) reset() {
 	m.irqEn, m.irqTog, m.irqCnt = false, false, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -312,6 +337,10 @@ func newMapper068(bm *baseMapper) Mapper {
 	return &mapper068{baseMapper: *bm}
 }
 
+func (m *mapper068) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+}
+
 func (m *mapper068) reset() {
 	m.r[0], m.r[1], m.r[2], m.r[3] = 0, 0, 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -373,22 +402,36 @@ type mapper069 struct {
 	irqEn    bool
 	irqCnt   int32
 	r        byte
+	sndReg   byte
+	snd      *apuChan5b
 }
 
 func newMapper069(bm *baseMapper) Mapper {
 	return &mapper069{baseMapper: *bm}
 }
 
+func (m *mapper069) serialize(s *stateBuf) {
+	s.bool(&m.patchTyp)
+	s.bool(&m.irqEn)
+	s.i32(&m.irqCnt)
+	s.u8(&m.r)
+	s.u8(&m.sndReg)
+}
+
 func (m *mapper069) reset() {
 	if m.sys.conf.PatchTyp&0x01 != 0 {
 		m.patchTyp = true
 	}
 	m.irqEn, m.irqCnt = false, 0
-	m.r = 0
+	m.r, m.sndReg = 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage != 0 {
 		m.mem.setVrom8kBank(0)
 	}
+	if m.snd == nil {
+		m.snd = newApuChan5b(m.sys.apu)
+	}
+	m.sys.apu.ex = m.snd
 }
 
 func (m *mapper069) write(addr uint16, data byte) {
@@ -428,6 +471,10 @@ func (m *mapper069) write(addr uint16, data byte) {
 			m.irqCnt = (m.irqCnt & 0x00ff) | (int32(data) << 8)
 			m.clearIntr()
 		}
+	case 0xc000:
+		m.sndReg = data & 0x0f
+	case 0xe000:
+		m.sys.apu.writeEx(0xe000|uint16(m.sndReg), data)
 	}
 }
 
@@ -536,6 +583,11 @@ func newMapper073(bm *baseMapper) Mapper {
 	return &mapper073{baseMapper: *bm}
 }
 
+func (m *mapper073) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u32(&m.irqCnt)
+}
+
 func (m *mapper073) reset() {
 	m.irqEn, m.irqCnt = false, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -590,6 +642,17 @@ func newMapper074(bm *baseMapper) Mapper {
 	return &mapper074{baseMapper: *bm}
 }
 
+func (m *mapper074) serialize(s *stateBuf) {
+	s.bool(&m.patchTyp)
+	s.bool(&m.irqEn)
+	s.bool(&m.irqReq)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper074) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -690,7 +753,19 @@ func (m *mapper074) write(addr uint16, data byte) {
 }
 
 func (m *mapper074) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn && !m.irqReq {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper074) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper074) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn && !m.irqReq {
 		if scanline == 0 && m.irqCnt != 0 {
 			m.irqCnt--
 		}
@@ -713,6 +788,10 @@ func newMapper075(bm *baseMapper) Mapper {
 	return &mapper075{baseMapper: *bm}
 }
 
+func (m *mapper075) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+}
+
 func (m *mapper075) reset() {
 	m.r[0], m.r[1] = 0, 1
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -754,6 +833,10 @@ func newMapper076(bm *baseMapper) Mapper {
 	return &mapper076{baseMapper: *bm}
 }
 
+func (m *mapper076) serialize(s *stateBuf) {
+	s.u8(&m.r)
+}
+
 func (m *mapper076) reset() {
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage >= 8 {
@@ -11,6 +11,10 @@ func newMapper080(bm *baseMapper) Mapper {
 	return &mapper080{baseMapper: *bm}
 }
 
+func (m *mapper080) serialize(s *stateBuf) {
+	s.u8(&m.r)
+}
+
 func (m *mapper080) reset() {
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage != 0 {
@@ -56,6 +60,10 @@ func newMapper082(bm *baseMapper) Mapper {
 	return &mapper082{baseMapper: *bm}
 }
 
+func (m *mapper082) serialize(s *stateBuf) {
+	s.u8(&m.r)
+}
+
 func (m *mapper082) reset() {
 	m.r = 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -109,6 +117,16 @@ func newMapper083(bm *baseMapper) Mapper {
 	return &mapper083{baseMapper: *bm}
 }
 
+func (m *mapper083) serialize(s *stateBuf) {
+	s.bool(&m.patchTyp)
+	s.bool(&m.irqEn)
+	s.u16(&m.irqCnt)
+	s.u32(&m.chrBank)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+}
+
 func (m *mapper083) reset() {
 	if m.sys.conf.PatchTyp&0x01 != 0 {
 		m.patchTyp = true
@@ -207,20 +225,35 @@ type mapper085 struct {
 	irqCnt   byte
 	irqLatch byte
 	irqClk   uint16
+	sndReg   byte
+	snd      *apuChanVrc7
 }
 
 func newMapper085(bm *baseMapper) Mapper {
 	return &mapper085{baseMapper: *bm}
 }
 
+func (m *mapper085) serialize(s *stateBuf) {
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+	s.u8(&m.sndReg)
+}
+
 func (m *mapper085) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch, m.irqClk = 0, 0, 0, 0
+	m.sndReg = 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage != 0 {
 		m.mem.setVrom8kBank(0)
 	} else {
 		m.mem.setCram8kBank(0)
 	}
+	if m.snd == nil {
+		m.snd = newApuChanVrc7(m.sys.apu)
+	}
+	m.sys.apu.ex = m.snd
 }
 
 func (m *mapper085) write(addr uint16, data byte) {
@@ -232,6 +265,10 @@ func (m *mapper085) write(addr uint16, data byte) {
 		m.mem.setProm8kBank(5, uint32(data))
 	case 0x9000:
 		m.mem.setProm8kBank(6, uint32(data))
+	case 0x9010:
+		m.sndReg = data & 0x3f
+	case 0x9030:
+		m.sys.apu.writeEx(apuVrc7RegBase|uint16(m.sndReg), data)
 	case 0xa000, 0xa008, 0xa010, 0xb000, 0xb008, 0xb010,
 		0xc000, 0xc008, 0xc010, 0xd000, 0xd008, 0xd010:
 		i := (byte(addr>>11) - 0x14) | (byte(addr&0x08) >> 3) | (byte(addr&0x10) >> 4)
@@ -287,6 +324,11 @@ func newMapper086(bm *baseMapper) Mapper {
 	return &mapper086{baseMapper: *bm}
 }
 
+func (m *mapper086) serialize(s *stateBuf) {
+	s.u8(&m.r)
+	s.u8(&m.c)
+}
+
 func (m *mapper086) reset() {
 	m.mem.setProm32kBank(0)
 	m.mem.setVrom8kBank(0)
@@ -344,6 +386,10 @@ func newMapper088(bm *baseMapper) Mapper {
 	return &mapper088{baseMapper: *bm}
 }
 
+func (m *mapper088) serialize(s *stateBuf) {
+	s.u8(&m.r)
+}
+
 func (m *mapper088) reset() {
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage >= 8 {
@@ -427,6 +473,27 @@ func newMapper090(bm *baseMapper) Mapper {
 	return &mapper090{baseMapper: *bm}
 }
 
+func (m *mapper090) serialize(s *stateBuf) {
+	s.bool(&m.patchTyp)
+	s.bool(&m.irqEn)
+	s.bool(&m.irqPre)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.irqOfs)
+	s.bool(&m.mirMode)
+	s.u8(&m.mirTyp)
+	s.u8(&m.flags)
+	s.u8(&m.k)
+	s.u8(&m.sw)
+	s.u8(&m.m0)
+	s.u8(&m.m1)
+	s.bytes(m.p[:])
+	s.bytes(m.rl[:])
+	s.bytes(m.rh[:])
+	s.bytes(m.cl[:])
+	s.bytes(m.ch[:])
+}
+
 func (m *mapper090) reset() {
 	patch := m.sys.conf.PatchTyp
 	if patch&0x01 != 0 {
@@ -617,6 +684,11 @@ func newMapper091(bm *baseMapper) Mapper {
 	return &mapper091{baseMapper: *bm}
 }
 
+func (m *mapper091) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+}
+
 func (m *mapper091) reset() {
 	m.irqEn, m.irqCnt = false, 0
 	b := m.nProm8kPage
@@ -743,6 +815,12 @@ func newMapper095(bm *baseMapper) Mapper {
 	return &mapper095{baseMapper: *bm}
 }
 
+func (m *mapper095) serialize(s *stateBuf) {
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper095) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -11,6 +11,11 @@ func newMapper096(bm *baseMapper) Mapper {
 	return &mapper096{baseMapper: *bm}
 }
 
+func (m *mapper096) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper096) reset() {
 	m.r0, m.r1 = 0, 0
 	m.mem.setProm32kBank(0)
@@ -73,6 +78,10 @@ func newMapper099(bm *baseMapper) Mapper {
 	return &mapper099{baseMapper: *bm}
 }
 
+func (m *mapper099) serialize(s *stateBuf) {
+	s.u8(&m.c)
+}
+
 func (m *mapper099) reset() {
 	m.c = 0
 	if m.nProm8kPage > 2 {
@@ -123,6 +132,15 @@ func newMapper100(bm *baseMapper) Mapper {
 	return &mapper100{baseMapper: *bm}
 }
 
+func (m *mapper100) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper100) setPpuBanks() {
 	if m.nVrom1kPage != 0 {
 		for i := byte(0); i < 8; i++ {
@@ -206,7 +224,19 @@ func (m *mapper100) write(addr uint16, data byte) {
 }
 
 func (m *mapper100) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper100) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper100) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0xff {
 			m.irqCnt = m.irqLatch
@@ -256,6 +286,15 @@ func newMapper105(bm *baseMapper) Mapper {
 	return &mapper105{baseMapper: *bm}
 }
 
+func (m *mapper105) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.i32(&m.irqCnt)
+	s.u8(&m.state)
+	s.u8(&m.writeCnt)
+	s.u8(&m.bits)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper105) reset() {
 	m.irqEn, m.irqCnt = false, 0
 	m.state, m.writeCnt, m.bits = 0, 0, 0
@@ -383,6 +422,13 @@ func newMapper109(bm *baseMapper) Mapper {
 	return &mapper109{baseMapper: *bm}
 }
 
+func (m *mapper109) serialize(s *stateBuf) {
+	s.u8(&m.r)
+	s.bytes(m.c[:])
+	s.u8(&m.mode0)
+	s.u8(&m.mode1)
+}
+
 func (m *mapper109) setPpuBanks() {
 	if m.nVrom1kPage != 0 {
 		m.mem.setVrom1kBank(0, uint32(m.c[0]))
@@ -443,6 +489,11 @@ func newMapper110(bm *baseMapper) Mapper {
 	return &mapper110{baseMapper: *bm}
 }
 
+func (m *mapper110) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper110) reset() {
 	m.r0, m.r1 = 0, 0
 	m.mem.setProm32kBank(0)
@@ -485,6 +536,11 @@ func newMapper111(bm *baseMapper) Mapper {
 	return &mapper111{baseMapper: *bm}
 }
 
+func (m *mapper111) serialize(s *stateBuf) {
+	s.bool(&m.largeTyp)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper111) reset() {
 	m.r[0], m.r[1], m.r[2], m.r[3] = 0x0c, 0, 0, 0
 	if m.nProm8kPage < 64 {
@@ -27,6 +27,12 @@ func newMapper112(bm *baseMapper) Mapper {
 	return &mapper112{baseMapper: *bm}
 }
 
+func (m *mapper112) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper112) reset() {
 	m.r[0], m.r[1], m.r[2], m.r[3] = 0, 0, 0, 0
 	m.p[0], m.p[1] = 0, 1
@@ -86,6 +92,10 @@ func newMapper113(bm *baseMapper) Mapper {
 	return &mapper113{baseMapper: *bm}
 }
 
+func (m *mapper113) serialize(s *stateBuf) {
+	s.bool(&m.patchTyp)
+}
+
 func (m *mapper113) reset() {
 	if m.sys.conf.PatchTyp&0x01 != 0 {
 		m.patchTyp = true
@@ -140,6 +150,15 @@ func newMapper114(bm *baseMapper) Mapper {
 	return &mapper114{baseMapper: *bm}
 }
 
+func (m *mapper114) serialize(s *stateBuf) {
+	s.bool(&m.irqOccur)
+	s.u8(&m.irqCnt)
+	s.bool(&m.c)
+	s.u8(&m.m)
+	s.u8(&m.a)
+	s.bytes(m.b[:])
+}
+
 func (m *mapper114) reset() {
 	m.irqOccur, m.irqCnt = false, 0
 	m.c, m.m, m.a = false, 0, 0
@@ -209,7 +228,19 @@ func (m *mapper114) write(addr uint16, data byte) {
 }
 
 func (m *mapper114) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqCnt != 0 {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper114) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper114) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqCnt != 0 {
 		m.irqCnt--
 		if m.irqCnt == 0 {
 			m.irqOccur = true
@@ -236,6 +267,17 @@ func newMapper115(bm *baseMapper) Mapper {
 	return &mapper115{baseMapper: *bm}
 }
 
+func (m *mapper115) serialize(s *stateBuf) {
+	s.bool(&m.cSwitch)
+	s.u8(&m.pSwitch)
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper115) setCpuBanks() {
 	if m.pSwitch&0x80 == 0 {
 		m.p[0], m.p[1] = m.p[4], m.p[5]
@@ -342,7 +384,19 @@ func (m *mapper115) write(addr uint16, data byte) {
 }
 
 func (m *mapper115) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper115) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper115) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0xff {
 			m.irqCnt = m.irqLatch
@@ -368,6 +422,16 @@ func newMapper116(bm *baseMapper) Mapper {
 	return &mapper116{baseMapper: *bm}
 }
 
+func (m *mapper116) serialize(s *stateBuf) {
+	s.bool(&m.cSwitch)
+	s.bool(&m.irqEn)
+	s.u8(&m.irqLatch)
+	s.i16(&m.irqCnt)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper116) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -476,6 +540,11 @@ func newMapper117(bm *baseMapper) Mapper {
 	return &mapper117{baseMapper: *bm}
 }
 
+func (m *mapper117) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+}
+
 func (m *mapper117) reset() {
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	if m.nVrom1kPage != 0 {
@@ -519,6 +588,15 @@ func newMapper118(bm *baseMapper) Mapper {
 	return &mapper118{baseMapper: *bm}
 }
 
+func (m *mapper118) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper118) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -604,7 +682,19 @@ func (m *mapper118) write(addr uint16, data byte) {
 }
 
 func (m *mapper118) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper118) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper118) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0xff {
 			m.irqCnt = m.irqLatch
@@ -629,6 +719,15 @@ func newMapper119(bm *baseMapper) Mapper {
 	return &mapper119{baseMapper: *bm}
 }
 
+func (m *mapper119) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper119) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -718,7 +817,19 @@ func (m *mapper119) write(addr uint16, data byte) {
 }
 
 func (m *mapper119) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper119) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper119) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0xff {
 			m.irqCnt = m.irqLatch
@@ -767,6 +878,18 @@ func newMapper121(bm *baseMapper) Mapper {
 	return &mapper121{baseMapper: *bm}
 }
 
+func (m *mapper121) serialize(s *stateBuf) {
+	s.bool(&m.irqReq)
+	s.bool(&m.irqRel)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.cmd)
+	s.u8(&m.a0)
+	s.u8(&m.a1)
+	s.bytes(m.r[:])
+	s.bytes(m.d[:])
+}
+
 func (m *mapper121) setCpuBanks0(iBank byte, data byte) {
 	m.mem.setProm8kBank(iBank, uint32(data&0x1f)|(uint32(m.r[3]&0x80)>>2))
 	if m.r[5]&0x3f != 0 {
@@ -926,7 +1049,19 @@ func (m *mapper121) write(addr uint16, data byte) {
 }
 
 func (m *mapper121) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqReq && !m.irqRel {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper121) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper121) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqReq && !m.irqRel {
 		if scanline == 0 && m.irqCnt != 0 {
 			m.irqCnt--
 		}
@@ -11,6 +11,10 @@ func newMapper132(bm *baseMapper) Mapper {
 	return &mapper132{baseMapper: *bm}
 }
 
+func (m *mapper132) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+}
+
 func (m *mapper132) reset() {
 	for i := 0; i < 4; i++ {
 		m.r[i] = 0
@@ -72,6 +76,12 @@ func newMapper134(bm *baseMapper) Mapper {
 	return &mapper134{baseMapper: *bm}
 }
 
+func (m *mapper134) serialize(s *stateBuf) {
+	s.u8(&m.cmd)
+	s.u8(&m.p)
+	s.u8(&m.c)
+}
+
 func (m *mapper134) reset() {
 	m.mem.setProm32kBank(0)
 	m.mem.setVrom8kBank(0)
@@ -116,6 +126,11 @@ func newMapper135(bm *baseMapper) Mapper {
 	return &mapper135{baseMapper: *bm}
 }
 
+func (m *mapper135) serialize(s *stateBuf) {
+	s.u8(&m.cmd)
+	s.bytes(m.c[:])
+}
+
 func (m *mapper135) setPpuBanks() {
 	b := uint32(m.c[4]) << 4
 	m.mem.setVrom2kBank(0, uint32(m.c[0]<<1)|b)
@@ -190,6 +205,11 @@ func newMapper141(bm *baseMapper) Mapper {
 	return &mapper141{baseMapper: *bm}
 }
 
+func (m *mapper141) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+	s.u8(&m.cmd)
+}
+
 func (m *mapper141) reset() {
 	for i := 0; i < 8; i++ {
 		m.r[i] = 0
@@ -238,6 +258,12 @@ func newMapper142(bm *baseMapper) Mapper {
 	return &mapper142{baseMapper: *bm}
 }
 
+func (m *mapper142) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u16(&m.irqCnt)
+	s.u8(&m.p)
+}
+
 func (m *mapper142) reset() {
 	m.irqEn, m.irqCnt, m.p = false, 0, 0
 	m.mem.setProm8kBank(3, 0)
@@ -34,6 +34,15 @@ func newMapper150(bm *baseMapper) Mapper {
 	return &mapper150{baseMapper: *bm}
 }
 
+func (m *mapper150) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+	s.u8(&m.r3)
+	s.u8(&m.r4)
+	s.u8(&m.cmd)
+}
+
 func (m *mapper150) reset() {
 	m.r0, m.r1, m.r2, m.r3, m.r4 = 0, 0, 0, 0, 0
 	m.cmd = 0
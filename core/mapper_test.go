@@ -0,0 +1,37 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// In the dot render mode, the mmc3 counter counts every filtered rise of a12,
+// on the pre-render line or with the rendering off as well.
+func TestMapper004A12Clock(t *testing.T) {
+	tests := []struct {
+		scanline uint16
+		reg1     byte
+		nLow     int64
+		nCnt     byte
+	}{
+		{0, ppuReg1BgDisp, mapperA12LowCycle + 1, 4},
+		{261, ppuReg1BgDisp, mapperA12LowCycle + 1, 4},
+		{100, 0, mapperA12LowCycle + 1, 4},
+		{100, ppuReg1BgDisp, mapperA12LowCycle, 5},
+	}
+	for _, tt := range tests {
+		sys, err := NewSys(bytes.NewReader(testRom(4, testProg)),
+			&Conf{RenderMode: RenderModeDot, NoRomDb: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := sys.mapper.(*mapper004)
+		m.irqCnt = 5
+		sys.scanline, sys.ppu.reg1 = tt.scanline, tt.reg1
+		m.ppuA12Rise(tt.nLow)
+		if m.irqCnt != tt.nCnt {
+			t.Errorf("scanline %d, $2001 %#x, low for %d: counter %d, want %d",
+				tt.scanline, tt.reg1, tt.nLow, m.irqCnt, tt.nCnt)
+		}
+	}
+}
@@ -14,6 +14,13 @@ func newMapper160(bm *baseMapper) Mapper {
 	return &mapper160{baseMapper: *bm}
 }
 
+func (m *mapper160) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.rTyp)
+}
+
 func (m *mapper160) reset() {
 	m.irqEn, m.irqCnt, m.irqLatch, m.rTyp = false, 0, 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -122,6 +129,10 @@ func newMapper162(bm *baseMapper) Mapper {
 	return &mapper162{baseMapper: *bm}
 }
 
+func (m *mapper162) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+}
+
 func (m *mapper162) setCpuBanks() {
 	var b byte
 	switch m.r[3] {
@@ -178,6 +189,16 @@ func newMapper163(bm *baseMapper) Mapper {
 	return &mapper163{baseMapper: *bm}
 }
 
+func (m *mapper163) serialize(s *stateBuf) {
+	s.u8(&m.typ)
+	s.bool(&m.trig)
+	s.bool(&m.strobe)
+	s.u8(&m.secur)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+}
+
 func (m *mapper163) reset() {
 	patch := m.sys.conf.PatchTyp
 	if patch&0x01 != 0 {
@@ -277,6 +298,13 @@ func newMapper164(bm *baseMapper) Mapper {
 	return &mapper164{baseMapper: *bm}
 }
 
+func (m *mapper164) serialize(s *stateBuf) {
+	s.bool(&m.pMode)
+	s.u8(&m.a)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper164) setCpuBanks() {
 	b := uint32(m.r0&0x01) << 5
 	switch (m.r0 >> 4) & 0x07 {
@@ -354,6 +382,13 @@ func newMapper165(bm *baseMapper) Mapper {
 	return &mapper165{baseMapper: *bm}
 }
 
+func (m *mapper165) serialize(s *stateBuf) {
+	s.bool(&m.sw)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper165) setPpuBanksSub(iBank byte, iPage uint32) {
 	if iPage != 0 {
 		m.mem.setVrom4kBank(iBank, iPage>>2)
@@ -434,6 +469,10 @@ func newMapper167(bm *baseMapper) Mapper {
 	return &mapper167{baseMapper: *bm}
 }
 
+func (m *mapper167) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+}
+
 func (m *mapper167) setCpuBanks() {
 	b := ((uint32(m.r[0]^m.r[1]) & 0x10) << 1) | (uint32(m.r[2]^m.r[3]) & 0x1f)
 	if m.r[1]&0x08 != 0 {
@@ -475,13 +514,20 @@ func newMapper168(bm *baseMapper) Mapper {
 	patch := bm.sys.conf.PatchTyp
 	if patch&0x01 != 0 {
 		m.typ = 1
-		bm.sys.tvFormat = tvFormats[2]
+		bm.sys.tvFormat = tvFormats[TvFormatPalChina]
 	} else if patch&0x02 != 0 {
 		m.typ = 2
 	}
 	return m
 }
 
+func (m *mapper168) serialize(s *stateBuf) {
+	s.u8(&m.typ)
+	s.bool(&m.sw)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper168) reset() {
 	m.sw = false
 	m.r0, m.r1 = 0, 0
@@ -574,6 +620,12 @@ func newMapper174(bm *baseMapper) Mapper {
 	return &mapper174{baseMapper: *bm}
 }
 
+func (m *mapper174) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+	s.u32s(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper174) reset() {
 	m.r[0], m.r[1], m.r[2], m.r[3] = 0, 0, 0, 0
 	m.p[0], m.p[1] = 0, 0
@@ -671,6 +723,10 @@ func newMapper175(bm *baseMapper) Mapper {
 	return &mapper175{baseMapper: *bm}
 }
 
+func (m *mapper175) serialize(s *stateBuf) {
+	s.u8(&m.r)
+}
+
 func (m *mapper175) reset() {
 	m.r = 0
 	m.mem.setProm16kBank(4, 0)
@@ -13,6 +13,12 @@ func newMapper176(bm *baseMapper) Mapper {
 	return &mapper176{baseMapper: *bm}
 }
 
+func (m *mapper176) serialize(s *stateBuf) {
+	s.bool(&m.patch)
+	s.u8(&m.we)
+	s.bool(&m.sb)
+}
+
 func (m *mapper176) reset() {
 	if m.sys.conf.PatchTyp&0x01 != 0 {
 		m.patch = true
@@ -32,7 +38,7 @@ func (m *mapper176) readLow(addr uint16) byte {
 	if m.patch && addr >= 0x6000 {
 		switch m.we {
 		case 0xe4, 0xec, 0xe5, 0xed, 0xe6, 0xee, 0xe7, 0xef:
-			return m.mem.wram[(addr&0x1fff)+(uint16(m.we)&0x03<<13)]
+			return m.mem.wram8kPage(uint32(m.we) & 0x03)[addr&0x1fff]
 		default:
 			return m.cpuBanks[addr>>13][addr&0x1fff]
 		}
@@ -62,7 +68,7 @@ func (m *mapper176) writeLow(addr uint16, data byte) {
 				m.cpuBanks[addr>>13][addr&0x1fff] = data
 				m.mem.wram[addr&0x1fff] = data
 			case 0xe5, 0xed, 0xe6, 0xee, 0xe7, 0xef:
-				m.mem.wram[(addr&0x1fff)+(uint16(m.we)&0x03<<13)] = data
+				m.mem.wram8kPage(uint32(m.we) & 0x03)[addr&0x1fff] = data
 			default:
 				m.cpuBanks[addr>>13][addr&0x1fff] = data
 			}
@@ -102,6 +108,13 @@ func newMapper178(bm *baseMapper) Mapper {
 	return &mapper178{baseMapper: *bm}
 }
 
+func (m *mapper178) serialize(s *stateBuf) {
+	s.bool(&m.patch)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.u8(&m.r2)
+}
+
 func (m *mapper178) reset() {
 	if m.sys.conf.PatchTyp&0x01 != 0 {
 		m.patch = true
@@ -194,6 +207,12 @@ func newMapper182(bm *baseMapper) Mapper {
 	return &mapper182{baseMapper: *bm}
 }
 
+func (m *mapper182) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.r)
+}
+
 func (m *mapper182) reset() {
 	m.irqEn, m.irqCnt, m.r = false, 0, 0
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
@@ -231,7 +250,19 @@ func (m *mapper182) write(addr uint16, data byte) {
 }
 
 func (m *mapper182) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper182) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper182) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0 {
 			m.irqEn = false
@@ -253,6 +284,12 @@ func newMapper183(bm *baseMapper) Mapper {
 	return &mapper183{baseMapper: *bm}
 }
 
+func (m *mapper183) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u16(&m.irqCnt)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper183) reset() {
 	m.irqEn, m.irqCnt = false, 0
 	for i := byte(0); i < 8; i++ {
@@ -395,6 +432,18 @@ func newMapper187(bm *baseMapper) Mapper {
 	return &mapper187{baseMapper: *bm}
 }
 
+func (m *mapper187) serialize(s *stateBuf) {
+	s.bool(&m.extMode)
+	s.bool(&m.extEn)
+	s.u8(&m.chrMode)
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.writePrev)
+	s.bytes(m.bank[:])
+	s.bytes(m.p[:])
+	s.u32s(m.c[:])
+}
+
 func (m *mapper187) reset() {
 	m.extMode, m.extEn, m.chrMode = true, false, 0
 	m.irqEn, m.irqCnt = false, 0
@@ -510,7 +559,19 @@ func (m *mapper187) write(addr uint16, data byte) {
 }
 
 func (m *mapper187) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper187) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper187) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		if m.irqCnt == 0 {
 			m.irqEn = true
 			m.setIntr()
@@ -564,6 +625,14 @@ func newMapper189(bm *baseMapper) Mapper {
 	return &mapper189{baseMapper: *bm}
 }
 
+func (m *mapper189) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.c[:])
+}
+
 func (m *mapper189) setPpuBanks() {
 	if m.nVrom1kPage != 0 {
 		if m.r&0x80 != 0 {
@@ -650,7 +719,19 @@ func (m *mapper189) write(addr uint16, data byte) {
 }
 
 func (m *mapper189) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper189) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper189) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0 {
 			m.irqCnt = m.irqLatch
@@ -675,6 +756,16 @@ func newMapper190(bm *baseMapper) Mapper {
 	return &mapper190{baseMapper: *bm}
 }
 
+func (m *mapper190) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.bool(&m.cmdCh)
+	s.u8(&m.cmd)
+	s.u8(&m.cmdL)
+	s.u8(&m.lo)
+}
+
 func (m *mapper190) reset() {
 	m.irqEn, m.irqCnt = false, 0
 	m.cmdCh, m.cmdL = false, 1
@@ -815,6 +906,13 @@ func newMapper191(bm *baseMapper) Mapper {
 	return &mapper191{baseMapper: *bm}
 }
 
+func (m *mapper191) serialize(s *stateBuf) {
+	s.u8(&m.r)
+	s.u8(&m.p)
+	s.u8(&m.h)
+	s.bytes(m.c[:])
+}
+
 func (m *mapper191) setPpuBanks() {
 	if m.nVrom1kPage != 0 {
 		b := uint32(m.h) << 3
@@ -63,6 +63,13 @@ func newMapper198(bm *baseMapper) Mapper {
 	return &mapper198{baseMapper: *bm}
 }
 
+func (m *mapper198) serialize(s *stateBuf) {
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+	s.bytes(m.buf[:])
+}
+
 func (m *mapper198) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -165,10 +172,23 @@ type mapper199 struct {
 }
 
 func newMapper199(bm *baseMapper) Mapper {
-	bm.sys.tvFormat = tvFormats[1]
+	bm.sys.tvFormat = tvFormats[TvFormatPal]
 	return &mapper199{baseMapper: *bm}
 }
 
+func (m *mapper199) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.bool(&m.irqReq)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.we)
+	s.bool(&m.jm)
+	s.u8(&m.r)
+	s.bytes(m.jmData[:])
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper199) setCpuBanks() {
 	for i := byte(0); i < 4; i++ {
 		j := i ^ ((m.r >> 5) & (^(i << 1)) & 0x02)
@@ -201,6 +221,13 @@ func (m *mapper199) reset() {
 	m.setPpuBanks()
 }
 
+func (m *mapper199) peek(addr uint16) (byte, bool) {
+	if m.jm && addr == 0x6013 {
+		return m.jmData[2], true
+	}
+	return 0, false
+}
+
 func (m *mapper199) readLow(addr uint16) byte {
 	if addr >= 0x5000 && addr < 0x6000 {
 		return m.mem.xram[addr&0x1fff]
@@ -218,7 +245,7 @@ func (m *mapper199) readLow(addr uint16) byte {
 		}
 		switch m.we {
 		case 0xe4, 0xe5, 0xe6, 0xe7, 0xec, 0xed, 0xee, 0xef:
-			return m.mem.wram[(uint16(m.we&0x03)<<13)|(addr&0x1fff)]
+			return m.mem.wram8kPage(uint32(m.we) & 0x03)[addr&0x1fff]
 		default:
 			return m.cpuBanks[addr>>13][addr&0x1fff]
 		}
@@ -247,7 +274,7 @@ func (m *mapper199) writeLow(addr uint16, data byte) {
 			m.mem.wram[addr&0x1fff] = data
 			m.cpuBanks[addr>>13][addr&0x1fff] = data
 		case 0xe5, 0xe6, 0xe7, 0xed, 0xee, 0xef:
-			m.mem.wram[(uint16(m.we&0x03)<<13)|(addr&0x1fff)] = data
+			m.mem.wram8kPage(uint32(m.we) & 0x03)[addr&0x1fff] = data
 		default:
 			m.cpuBanks[addr>>13][addr&0x1fff] = data
 		}
@@ -304,7 +331,19 @@ func (m *mapper199) write(addr uint16, data byte) {
 }
 
 func (m *mapper199) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn && !m.irqReq {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper199) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper199) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn && !m.irqReq {
 		if scanline == 0 && m.irqCnt != 0 {
 			m.irqCnt--
 		}
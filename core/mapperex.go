@@ -48,6 +48,11 @@ func newMapper226(bm *baseMapper) Mapper {
 	return &mapper226{baseMapper: *bm}
 }
 
+func (m *mapper226) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper226) reset() {
 	m.mem.setProm32kBank(0)
 }
@@ -203,6 +208,10 @@ func newMapper230(bm *baseMapper) Mapper {
 	return &mapper230{baseMapper: *bm, idx: true}
 }
 
+func (m *mapper230) serialize(s *stateBuf) {
+	s.bool(&m.idx)
+}
+
 func (m *mapper230) reset() {
 	m.idx = !m.idx
 	if m.idx {
@@ -275,6 +284,11 @@ func newMapper232(bm *baseMapper) Mapper {
 	return &mapper232{baseMapper: *bm}
 }
 
+func (m *mapper232) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper232) reset() {
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	m.r0 = 0x0c
@@ -340,6 +354,11 @@ func newMapper234(bm *baseMapper) Mapper {
 	return &mapper234{baseMapper: *bm}
 }
 
+func (m *mapper234) serialize(s *stateBuf) {
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+}
+
 func (m *mapper234) setBank() {
 	r0, r1 := uint32(m.r0), uint32(m.r1)
 	if r0&0x40 != 0 {
@@ -457,6 +476,11 @@ func newMapper236(bm *baseMapper) Mapper {
 	return &mapper236{baseMapper: *bm}
 }
 
+func (m *mapper236) serialize(s *stateBuf) {
+	s.u8(&m.bank)
+	s.u8(&m.mode)
+}
+
 func (m *mapper236) reset() {
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 }
@@ -510,6 +534,12 @@ func newMapper237(bm *baseMapper) Mapper {
 	return &mapper237{baseMapper: *bm}
 }
 
+func (m *mapper237) serialize(s *stateBuf) {
+	s.u8(&m.r)
+	s.u8(&m.data)
+	s.u16(&m.addr)
+}
+
 func (m *mapper237) reset() {
 	m.mem.setProm16kBank(4, 0)
 	m.mem.setProm16kBank(6, 7)
@@ -517,6 +547,13 @@ func (m *mapper237) reset() {
 	m.data, m.addr = 0, 0
 }
 
+func (m *mapper237) peek(addr uint16) (byte, bool) {
+	if addr == 0xc000 {
+		return m.r + 1, true
+	}
+	return 0, false
+}
+
 func (m *mapper237) read(addr uint16) byte {
 	if addr == 0xc000 {
 		m.cpuBanks[6][0] = m.r + 1
@@ -78,6 +78,10 @@ func newMapper243(bm *baseMapper) Mapper {
 	return &mapper243{baseMapper: *bm}
 }
 
+func (m *mapper243) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+}
+
 func (m *mapper243) reset() {
 	m.mem.setProm32kBank(0)
 	if m.nVrom1kPage>>3 > 4 {
@@ -156,6 +160,17 @@ func newMapper245(bm *baseMapper) Mapper {
 	return &mapper245{baseMapper: *bm}
 }
 
+func (m *mapper245) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.bool(&m.irqReq)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r0)
+	s.u8(&m.r1)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper245) reset() {
 	m.irqEn, m.irqReq, m.irqCnt, m.irqLatch = false, false, 0, 0
 	m.r0, m.r1, m.p[0], m.p[1] = 0, 0, 0, 1
@@ -204,7 +219,19 @@ func (m *mapper245) write(addr uint16, data byte) {
 }
 
 func (m *mapper245) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn && !m.irqReq {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper245) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper245) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn && !m.irqReq {
 		if scanline == 0 && m.irqCnt != 0 {
 			m.irqCnt--
 		}
@@ -259,6 +286,15 @@ func newMapper248(bm *baseMapper) Mapper {
 	return &mapper248{baseMapper: *bm}
 }
 
+func (m *mapper248) serialize(s *stateBuf) {
+	s.bool(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper248) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -332,7 +368,19 @@ func (m *mapper248) write(addr uint16, data byte) {
 }
 
 func (m *mapper248) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper248) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper248) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn {
 		m.irqCnt--
 		if m.irqCnt == 0xff {
 			m.irqCnt = m.irqLatch
@@ -357,6 +405,15 @@ func newMapper249(bm *baseMapper) Mapper {
 	return &mapper249{baseMapper: *bm}
 }
 
+func (m *mapper249) serialize(s *stateBuf) {
+	s.bool(&m.sp)
+	s.bool(&m.irqEn)
+	s.bool(&m.irqReq)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+}
+
 func (m *mapper249) reset() {
 	m.sp = false
 	m.irqEn, m.irqReq, m.irqCnt, m.irqLatch = false, false, 0, 0
@@ -443,7 +500,19 @@ func (m *mapper249) write(addr uint16, data byte) {
 }
 
 func (m *mapper249) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn && !m.irqReq {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper249) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper249) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn && !m.irqReq {
 		if scanline == 0 && m.irqCnt != 0 {
 			m.irqCnt--
 		}
@@ -467,6 +536,11 @@ func newMapper251(bm *baseMapper) Mapper {
 	return &mapper251{baseMapper: *bm}
 }
 
+func (m *mapper251) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+	s.bytes(m.b[:])
+}
+
 func (m *mapper251) reset() {
 	m.mem.setProm32kBank4(0, 1, m.nProm8kPage-2, m.nProm8kPage-1)
 	m.mem.setVramMirror(memVramMirrorV)
@@ -544,6 +618,15 @@ func newMapper252(bm *baseMapper) Mapper {
 	return &mapper252{baseMapper: *bm}
 }
 
+func (m *mapper252) serialize(s *stateBuf) {
+	s.bool(&m.irqOccur)
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper252) reset() {
 	m.irqOccur, m.irqEn, m.irqCnt, m.irqLatch, m.irqClk = false, 0, 0, 0, 0
 	for i := byte(0); i < 8; i++ {
@@ -620,6 +703,16 @@ func newMapper253(bm *baseMapper) Mapper {
 	return &mapper253{baseMapper: *bm}
 }
 
+func (m *mapper253) serialize(s *stateBuf) {
+	s.bool(&m.patch)
+	s.bool(&m.vrsw)
+	s.u8(&m.irqEn)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u16(&m.irqClk)
+	s.bytes(m.r[:])
+}
+
 func (m *mapper253) reset() {
 	if m.sys.conf.PatchTyp&0x01 != 0 {
 		m.patch = true
@@ -726,6 +819,17 @@ func newMapper254(bm *baseMapper) Mapper {
 	return &mapper254{baseMapper: *bm}
 }
 
+func (m *mapper254) serialize(s *stateBuf) {
+	s.bool(&m.prot)
+	s.bool(&m.irqEn)
+	s.bool(&m.irqReq)
+	s.u8(&m.irqCnt)
+	s.u8(&m.irqLatch)
+	s.u8(&m.r)
+	s.bytes(m.p[:])
+	s.bytes(m.c[:])
+}
+
 func (m *mapper254) setCpuBanks() {
 	if m.r&0x40 != 0 {
 		m.mem.setProm32kBank4(m.nProm8kPage-2, uint32(m.p[1]), uint32(m.p[0]), m.nProm8kPage-1)
@@ -831,7 +935,19 @@ func (m *mapper254) write(addr uint16, data byte) {
 }
 
 func (m *mapper254) hSync(scanline uint16) {
-	if scanline < ScreenHeight && m.isPpuDisp() && m.irqEn && !m.irqReq {
+	if !m.isA12Clocked() {
+		m.irqClock(scanline)
+	}
+}
+
+func (m *mapper254) ppuA12Rise(nLowCycle int64) {
+	if m.isA12Count(nLowCycle) {
+		m.irqClock(m.sys.scanline)
+	}
+}
+
+func (m *mapper254) irqClock(scanline uint16) {
+	if m.isIrqLine(scanline) && m.irqEn && !m.irqReq {
 		if scanline == 0 && m.irqCnt != 0 {
 			m.irqCnt--
 		}
@@ -854,6 +970,10 @@ func newMapper255(bm *baseMapper) Mapper {
 	return &mapper255{baseMapper: *bm}
 }
 
+func (m *mapper255) serialize(s *stateBuf) {
+	s.bytes(m.r[:])
+}
+
 func (m *mapper255) reset() {
 	m.r[0], m.r[1], m.r[2], m.r[3] = 0, 0, 0, 0
 	m.mem.setProm32kBank(0)
@@ -1,5 +1,7 @@
 package core
 
+import "errors"
+
 const (
 	memBankTypRom byte = iota
 	memBankTypRam
@@ -20,6 +22,7 @@ type Mem struct {
 
 	nProm8kPage uint32
 	nVrom1kPage uint32
+	nCram1kPage uint32
 	cpuBanks    [8][]byte
 	cpuBanksTyp [8]byte
 	ppuBanks    [12][]byte
@@ -28,29 +31,45 @@ type Mem struct {
 	ram    [0x2000]byte
 	xram   [0x2000]byte
 	dram   [0xa000]byte
-	wram   [0x20000]byte
+	wram   []byte
 	vram   [0x1000]byte
-	cram   [0x8000]byte
+	cram   []byte
 	prom   []byte
 	vrom   []byte
 	cpuReg [24]byte
 }
 
+func memSize(size uint32, unit uint32) uint32 {
+	if size < unit {
+		return unit
+	}
+	return (size + unit - 1) / unit * unit
+}
+
 func newMem(sys *Sys) *Mem {
 	mem := &Mem{}
 	mem.sys = sys
 
+	rom := sys.rom
+	if info := &rom.info; info.Nes20 {
+		mem.wram = make([]byte, memSize(info.PramSize+info.PnvramSize, 0x2000))
+		mem.cram = make([]byte, memSize(info.CramSize+info.CnvramSize, 0x2000))
+	} else {
+		mem.wram = make([]byte, 0x20000)
+		mem.cram = make([]byte, 0x8000)
+	}
+	mem.nCram1kPage = uint32(len(mem.cram)) >> 10
+
 	mem.setCpuBank(0, mem.ram[:], memBankTypRam)
 	mem.setCpuBank(1, mem.xram[:], memBankTypRom)
 	mem.setCpuBank(2, mem.xram[:], memBankTypRom)
-	mem.setCpuBank(3, mem.wram[:], memBankTypRam)
+	mem.setCpuBank(3, mem.wram, memBankTypRam)
 
-	rom := sys.rom
 	mem.prom = rom.prom
 	mem.vrom = rom.vrom
 
-	mem.nProm8kPage = uint32(rom.nPromPage) << 1
-	mem.nVrom1kPage = uint32(rom.nVromPage) << 3
+	mem.nProm8kPage = rom.nPromPage << 1
+	mem.nVrom1kPage = rom.nVromPage << 3
 	if mem.nVrom1kPage != 0 {
 		mem.setVrom8kBank(0)
 	} else {
@@ -128,6 +147,16 @@ func (mem *Mem) setProm32kBank4(iPage0, iPage1, iPage2, iPage3 uint32) {
 	mem.setProm8kBank(7, iPage3)
 }
 
+// wram8kPage returns a page of wram, mirrored over the size the rom has.
+func (mem *Mem) wram8kPage(iPage uint32) []byte {
+	i := (iPage << 13) % uint32(len(mem.wram))
+	return mem.wram[i : i+0x2000 : i+0x2000]
+}
+
+func (mem *Mem) setWram8kBank(iBank byte, iPage uint32) {
+	mem.setCpuBank(iBank, mem.wram8kPage(iPage), memBankTypRam)
+}
+
 func (mem *Mem) setVrom1kBank(iBank byte, iPage uint32) {
 	iPage %= mem.nVrom1kPage
 	i := iPage << 10
@@ -167,7 +196,7 @@ func (mem *Mem) setVrom8kBank8(iPage0, iPage1, iPage2, iPage3, iPage4, iPage5, i
 }
 
 func (mem *Mem) setCram1kBank(iBank byte, iPage uint32) {
-	iPage &= 0x1f
+	iPage %= mem.nCram1kPage
 	i := iPage << 10
 	mem.ppuBanks[iBank], mem.ppuBanksTyp[iBank] = mem.cram[i:i+0x0400:i+0x0400], memBankTypCram
 }
@@ -231,3 +260,80 @@ func (mem *Mem) setVramMirror(typ byte) {
 		mem.setVramBank(0, 1, 2, 3)
 	}
 }
+
+const (
+	memRegionRam byte = iota
+	memRegionXram
+	memRegionDram
+	memRegionWram
+	memRegionVram
+	memRegionCram
+	memRegionProm
+	memRegionVrom
+	memRegionNum
+)
+
+func (mem *Mem) region(region byte) []byte {
+	switch region {
+	case memRegionRam:
+		return mem.ram[:]
+	case memRegionXram:
+		return mem.xram[:]
+	case memRegionDram:
+		return mem.dram[:]
+	case memRegionWram:
+		return mem.wram
+	case memRegionVram:
+		return mem.vram[:]
+	case memRegionCram:
+		return mem.cram
+	case memRegionProm:
+		return mem.prom
+	case memRegionVrom:
+		return mem.vrom
+	}
+	return nil
+}
+
+// bankRef returns the region a bank points into and its offset there,
+// memRegionNum for a nil bank, and an error for one out of all the regions.
+func (mem *Mem) bankRef(bank []byte, size uint32) (byte, uint32, error) {
+	if len(bank) == 0 {
+		return memRegionNum, 0, nil
+	}
+	for region := byte(0); region < memRegionNum; region++ {
+		sl := mem.region(region)
+		for ofs, l := uint32(0), uint32(len(sl)); ofs+size <= l; ofs += 0x0400 {
+			if &sl[ofs] == &bank[0] {
+				return region, ofs, nil
+			}
+		}
+	}
+	return memRegionNum, 0, errors.New("bank out of the memory regions")
+}
+
+func (mem *Mem) bankSlice(region byte, ofs uint32, size uint32) []byte {
+	sl := mem.region(region)
+	if uint64(ofs)+uint64(size) > uint64(len(sl)) {
+		return nil
+	}
+	return sl[ofs : ofs+size : ofs+size]
+}
+
+func (mem *Mem) serialize(s *stateBuf) {
+	s.bytes(mem.ram[:])
+	s.bytes(mem.xram[:])
+	s.bytes(mem.dram[:])
+	s.bytes(mem.wram)
+	s.bytes(mem.vram[:])
+	s.bytes(mem.cram)
+	s.bytes(mem.cpuReg[:])
+	for i := range mem.cpuBanks {
+		s.u8(&mem.cpuBanksTyp[i])
+		s.bank(&mem.cpuBanks[i], 0x2000)
+	}
+	for i := range mem.ppuBanks {
+		s.u8(&mem.ppuBanksTyp[i])
+		s.bank(&mem.ppuBanks[i], 0x0400)
+	}
+}
@@ -0,0 +1,566 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	NsfChipVrc6 byte = 1 << iota
+	NsfChipVrc7
+	NsfChipFds
+	NsfChipMmc5
+	NsfChipN163
+	NsfChip5b
+)
+
+type NsfFileHeader struct {
+	Magic     uint32
+	Magic1    byte
+	Version   byte
+	NSong     byte
+	StartSong byte
+	LoadAddr  uint16
+	InitAddr  uint16
+	PlayAddr  uint16
+	Name      [32]byte
+	Artist    [32]byte
+	Copyright [32]byte
+	SpeedNtsc uint16
+	Banks     [8]byte
+	SpeedPal  uint16
+	Timing    byte
+	Chips     byte
+	Nsf2Flags byte
+	DataLen   [3]byte
+}
+
+type NsfInfo struct {
+	Name       string
+	Artist     string
+	Copyright  string
+	Ripper     string
+	NSong      byte
+	StartSong  byte
+	Chips      byte
+	Timing     byte
+	TrackNames []string
+	TrackTimes []int32 // ms, -1 if unknown
+}
+
+type nsfFile struct {
+	info      NsfInfo
+	loadAddr  uint16
+	initAddr  uint16
+	playAddr  uint16
+	speedNtsc uint16
+	speedPal  uint16
+	banks     [8]byte
+	banked    bool
+	song      byte
+}
+
+func nsfString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// newNsfRom reads an nsf file whose first 16 bytes were already consumed
+// into head.
+func newNsfRom(file io.Reader, head []byte) (*Rom, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	data = append(head, data...)
+	header := &NsfFileHeader{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	data = data[0x80:]
+	if n := int(header.DataLen[0]) | int(header.DataLen[1])<<8 | int(header.DataLen[2])<<16; header.Version >= 2 && n != 0 && n < len(data) {
+		data = data[:n]
+	}
+
+	nsf := &nsfFile{}
+	info := &nsf.info
+	info.Name = nsfString(header.Name[:])
+	info.Artist = nsfString(header.Artist[:])
+	info.Copyright = nsfString(header.Copyright[:])
+	info.NSong, info.StartSong = header.NSong, header.StartSong-1
+	info.Chips = header.Chips
+	switch header.Timing & 0x03 {
+	case 0x01:
+		info.Timing = RomTimingPal
+	case 0x02, 0x03:
+		info.Timing = RomTimingMulti
+	}
+	nsf.loadAddr, nsf.initAddr, nsf.playAddr = header.LoadAddr, header.InitAddr, header.PlayAddr
+	nsf.speedNtsc, nsf.speedPal = header.SpeedNtsc, header.SpeedPal
+	nsf.banks = header.Banks
+	return nsf.newRom(data)
+}
+
+// newNsfeRom reads an nsfe file, a chunked variant of nsf carrying track
+// names and lengths.
+func newNsfeRom(file io.Reader) (*Rom, error) {
+	rest, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	nsf := &nsfFile{speedNtsc: 16639, speedPal: 19997}
+	info := &nsf.info
+	var data []byte
+	var bInfo bool
+	for {
+		if len(rest) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		n, id := binary.LittleEndian.Uint32(rest), string(rest[4:8])
+		rest = rest[8:]
+		if id == "NEND" {
+			break
+		}
+		if n > uint32(len(rest)) {
+			return nil, errors.New("invalid nsfe chunk " + id)
+		}
+		b := rest[:n:n]
+		rest = rest[n:]
+		switch id {
+		case "INFO":
+			if len(b) < 8 {
+				return nil, errors.New("invalid nsfe info chunk")
+			}
+			bInfo = true
+			nsf.loadAddr = binary.LittleEndian.Uint16(b[0:])
+			nsf.initAddr = binary.LittleEndian.Uint16(b[2:])
+			nsf.playAddr = binary.LittleEndian.Uint16(b[4:])
+			switch b[6] & 0x03 {
+			case 0x01:
+				info.Timing = RomTimingPal
+			case 0x02, 0x03:
+				info.Timing = RomTimingMulti
+			}
+			info.Chips, info.NSong = b[7], 1
+			if len(b) >= 10 {
+				info.NSong, info.StartSong = b[8], b[9]
+			}
+		case "DATA":
+			data = b
+		case "BANK":
+			copy(nsf.banks[:], b)
+		case "RATE":
+			if len(b) >= 2 {
+				nsf.speedNtsc = binary.LittleEndian.Uint16(b[0:])
+			}
+			if len(b) >= 4 {
+				nsf.speedPal = binary.LittleEndian.Uint16(b[2:])
+			}
+		case "auth":
+			ss := bytes.SplitN(b, []byte{0}, 5)
+			for i, p := range []*string{&info.Name, &info.Artist, &info.Copyright, &info.Ripper} {
+				if i < len(ss) {
+					*p = string(ss[i])
+				}
+			}
+		case "tlbl":
+			for _, s := range bytes.Split(bytes.TrimSuffix(b, []byte{0}), []byte{0}) {
+				info.TrackNames = append(info.TrackNames, string(s))
+			}
+		case "time":
+			for i := 0; i+4 <= len(b); i += 4 {
+				info.TrackTimes = append(info.TrackTimes, int32(binary.LittleEndian.Uint32(b[i:])))
+			}
+		default:
+			// an unknown chunk is only fatal if marked as required
+			if id[0] >= 'A' && id[0] <= 'Z' {
+				return nil, errors.New("unsupported nsfe chunk " + id)
+			}
+		}
+	}
+	if !bInfo || data == nil {
+		return nil, errors.New("invalid nsfe file")
+	}
+	return nsf.newRom(data)
+}
+
+func (nsf *nsfFile) newRom(data []byte) (*Rom, error) {
+	info := &nsf.info
+	if info.NSong == 0 {
+		info.NSong = 1
+	}
+	if info.StartSong >= info.NSong {
+		info.StartSong = 0
+	}
+	nsf.song = info.StartSong
+	for _, b := range nsf.banks {
+		nsf.banked = nsf.banked || b != 0
+	}
+
+	// with bankswitching, the data is placed at the offset of the load
+	// address in its 4k page; without, it is placed at the load address
+	// relative to the start of the mapped area
+	var pad int
+	if nsf.banked {
+		pad = int(nsf.loadAddr & 0x0fff)
+	} else {
+		base := uint16(0x8000)
+		if info.Chips&NsfChipFds != 0 {
+			base = 0x6000
+		}
+		if nsf.loadAddr < base {
+			return nil, errors.New("invalid nsf load address")
+		}
+		pad = int(nsf.loadAddr - base)
+	}
+	n := (pad + len(data) + 0x3fff) >> 14
+	if n < 3 {
+		n = 3
+	}
+	rom := &Rom{nsf: nsf}
+	rom.prom = make([]byte, n<<14)
+	copy(rom.prom[pad:], data)
+	rom.nPromPage = uint32(n)
+	rom.info.PromSize = uint32(n) << 14
+	rom.info.PramSize, rom.info.CramSize = 0x2000, 0x2000
+	rom.info.Timing = info.Timing
+	rom.info.Crc = crc32.ChecksumIEEE(data)
+	return rom, nil
+}
+
+var nsfDriver = [...]byte{
+	0x78,       // 4100 sei
+	0xa9, 0x0f, // 4101 lda #$0f
+	0x8d, 0x15, 0x40, // 4103 sta $4015
+	0xa9, 0x40, // 4106 lda #$40
+	0x8d, 0x17, 0x40, // 4108 sta $4017
+	0xa9, 0x00, // 410b lda #song
+	0xa2, 0x00, // 410d ldx #pal
+	0x20, 0x00, 0x00, // 410f jsr init
+	0x4c, 0x12, 0x41, // 4112 jmp $4112
+	0x20, 0x00, 0x00, // 4115 jsr play
+	0x4c, 0x12, 0x41, // 4118 jmp $4112
+}
+
+const (
+	nsfDriverAddr = 0x4100
+	nsfIdleAddr   = 0x4112
+	nsfPlayAddr   = 0x4115
+)
+
+// mapperNsf drives an nsf tune: the cpu starts in a small driver at $4100
+// that calls INIT and then idles, and is sent to PLAY at the tune's rate.
+type mapperNsf struct {
+	baseMapper
+	nsf      *nsfFile
+	ex       apuExChan
+	bEx      bool
+	fds      *apuChanFds
+	mmc5     *apuChanMmc5
+	mmc5Ram  [0x400]byte // $5c00-$5fff, minus the page registers
+	mmc5Mul  [2]byte
+	n163     apuN163Port
+	reg5b    byte
+	regVrc7  byte
+	driver   [len(nsfDriver)]byte
+	regs     [10]byte // 4k pages at $6000-$ffff, the first two only used with fds
+	ram      []byte   // with fds, $6000-$ffff is ram loaded from the pages
+	nPage    uint32
+	period   float64
+	playAcc  float64
+	playPend bool
+}
+
+func newMapperNsf(bm *baseMapper) Mapper {
+	m := &mapperNsf{baseMapper: *bm, nsf: bm.sys.rom.nsf}
+	m.nPage = uint32(len(bm.mem.prom)) >> 12
+	if m.nsf.info.Chips&NsfChipFds != 0 {
+		m.ram = make([]byte, 0xa000)
+	}
+	return m
+}
+
+func (m *mapperNsf) serialize(s *stateBuf) {
+	s.u8(&m.nsf.song)
+	s.bytes(m.regs[:])
+	if m.ram != nil {
+		s.bytes(m.ram)
+	}
+	if m.nsf.info.Chips&NsfChipN163 != 0 {
+		m.n163.serialize(s)
+	}
+	if m.mmc5 != nil {
+		s.bytes(m.mmc5Ram[:])
+		s.bytes(m.mmc5Mul[:])
+	}
+	s.u8(&m.reg5b)
+	s.u8(&m.regVrc7)
+	s.f64(&m.playAcc)
+	s.bool(&m.playPend)
+	if s.load {
+		m.setDriver()
+	}
+}
+
+func (m *mapperNsf) page(n byte) []byte {
+	i := (uint32(n) % m.nPage) << 12
+	return m.mem.prom[i : i+0x1000]
+}
+
+func (m *mapperNsf) setPage(i byte, n byte) {
+	m.regs[i] = n
+	if m.ram != nil {
+		copy(m.ram[uint32(i)<<12:], m.page(n))
+	}
+}
+
+func (m *mapperNsf) setDriver() {
+	nsf, d := m.nsf, &m.driver
+	copy(d[:], nsfDriver[:])
+	d[0x0c] = nsf.song
+	if m.sys.conf.TvFormat != TvFormatNtsc {
+		d[0x0e] = 1
+	}
+	d[0x10], d[0x11] = byte(nsf.initAddr), byte(nsf.initAddr>>8)
+	d[0x16], d[0x17] = byte(nsf.playAddr), byte(nsf.playAddr>>8)
+}
+
+func (m *mapperNsf) reset() {
+	nsf := m.nsf
+	m.setDriver()
+	if nsf.banked {
+		for i := byte(0); i < 8; i++ {
+			m.setPage(i+2, nsf.banks[i])
+		}
+		if m.ram != nil {
+			m.setPage(0, nsf.banks[6])
+			m.setPage(1, nsf.banks[7])
+		}
+	} else if m.ram != nil {
+		for i := byte(0); i < 10; i++ {
+			m.setPage(i, i)
+		}
+	} else {
+		for i := byte(0); i < 8; i++ {
+			m.setPage(i+2, i)
+		}
+	}
+	if m.ram == nil {
+		for i := range m.mem.wram[:0x2000] {
+			m.mem.wram[i] = 0
+		}
+	}
+
+	speed := nsf.speedNtsc
+	if m.sys.conf.TvFormat != TvFormatNtsc {
+		speed = nsf.speedPal
+	}
+	if speed == 0 {
+		m.period = float64(m.sys.tvFormat.cpuRate) * float64(m.sys.tvFormat.framePeriod) / 1000.0
+	} else {
+		m.period = float64(m.sys.tvFormat.cpuRate) * float64(speed) / 1000000.0
+	}
+	m.playAcc, m.playPend = 0, false
+
+	if !m.bEx {
+		m.initEx()
+	}
+	m.sys.apu.ex = m.ex
+}
+
+func (m *mapperNsf) initEx() {
+	chips, apu := m.nsf.info.Chips, m.sys.apu
+	var ex apuExMulti
+	if chips&NsfChipFds != 0 {
+		m.fds = newApuChanFds(apu)
+		ex = append(ex, m.fds)
+	}
+	if chips&NsfChipVrc6 != 0 {
+		ex = append(ex, newApuChanVrc6(apu))
+	}
+	if chips&NsfChipN163 != 0 {
+		ex = append(ex, newApuChanN163(apu))
+	}
+	if chips&NsfChip5b != 0 {
+		ex = append(ex, newApuChan5b(apu))
+	}
+	if chips&NsfChipVrc7 != 0 {
+		ex = append(ex, newApuChanVrc7(apu))
+	}
+	if chips&NsfChipMmc5 != 0 {
+		m.mmc5 = newApuChanMmc5(apu)
+		ex = append(ex, m.mmc5)
+	}
+	switch len(ex) {
+	case 0:
+	case 1:
+		m.ex = ex[0]
+	default:
+		m.ex = ex
+	}
+	m.bEx = true
+}
+
+func (m *mapperNsf) peek(addr uint16) (byte, bool) {
+	if addr == 0x4800 && m.nsf.info.Chips&NsfChipN163 != 0 {
+		return m.n163.ram[m.n163.addr&0x7f], true
+	} else if addr >= 0x8000 {
+		return m.read(addr), true
+	}
+	return 0, false
+}
+
+func (m *mapperNsf) readEx(addr uint16) byte {
+	if m.fds != nil && addr >= 0x4040 && addr <= 0x4092 {
+		return m.fds.read(addr)
+	}
+	return byte(addr >> 8)
+}
+
+func (m *mapperNsf) writeEx(addr uint16, data byte) {
+	if m.fds != nil && addr >= 0x4040 && addr <= 0x408a {
+		m.sys.apu.writeEx(addr, data)
+	}
+}
+
+func (m *mapperNsf) readLow(addr uint16) byte {
+	switch {
+	case addr >= nsfDriverAddr && addr < nsfDriverAddr+uint16(len(m.driver)):
+		return m.driver[addr-nsfDriverAddr]
+	case addr == 0x4800 && m.nsf.info.Chips&NsfChipN163 != 0:
+		return m.n163.read()
+	case m.mmc5 != nil && addr == 0x5015:
+		return m.mmc5.status()
+	case m.mmc5 != nil && addr == 0x5205:
+		return m.mmc5Mul[0] * m.mmc5Mul[1]
+	case m.mmc5 != nil && addr == 0x5206:
+		return byte((uint16(m.mmc5Mul[0]) * uint16(m.mmc5Mul[1])) >> 8)
+	case m.mmc5 != nil && addr >= 0x5c00 && addr < 0x5ff6:
+		return m.mmc5Ram[addr-0x5c00]
+	case addr >= 0x6000 && m.ram != nil:
+		return m.ram[addr-0x6000]
+	}
+	return m.baseMapper.readLow(addr)
+}
+
+func (m *mapperNsf) writeLow(addr uint16, data byte) {
+	switch {
+	case addr == 0x4800 && m.nsf.info.Chips&NsfChipN163 != 0:
+		m.n163.write(m.sys.apu, data)
+	case m.mmc5 != nil && addr >= 0x5000 && addr <= 0x5015:
+		m.mmc5.write(addr, data)
+	case m.mmc5 != nil && (addr == 0x5205 || addr == 0x5206):
+		m.mmc5Mul[addr-0x5205] = data
+	case m.mmc5 != nil && addr >= 0x5c00 && addr < 0x5ff6:
+		m.mmc5Ram[addr-0x5c00] = data
+	case addr >= 0x5ff8 && addr <= 0x5fff:
+		m.setPage(byte(addr-0x5ff8)+2, data)
+	case addr >= 0x5ff6 && addr <= 0x5ff7 && m.ram != nil:
+		m.setPage(byte(addr-0x5ff6), data)
+	case addr >= 0x6000 && m.ram != nil:
+		m.ram[addr-0x6000] = data
+	default:
+		m.baseMapper.writeLow(addr, data)
+	}
+}
+
+func (m *mapperNsf) read(addr uint16) byte {
+	switch {
+	case addr == 0xfffc:
+		return byte(nsfDriverAddr & 0xff)
+	case addr == 0xfffd:
+		return byte(nsfDriverAddr >> 8)
+	case m.ram != nil:
+		return m.ram[addr-0x6000]
+	}
+	return m.page(m.regs[(addr>>12)-6])[addr&0x0fff]
+}
+
+func (m *mapperNsf) write(addr uint16, data byte) {
+	if m.nsf.info.Chips&NsfChipVrc6 != 0 {
+		switch addr {
+		case 0x9000, 0x9001, 0x9002, 0x9003, 0xa000, 0xa001, 0xa002, 0xb000, 0xb001, 0xb002:
+			m.sys.apu.writeEx(addr, data)
+		}
+	}
+	if m.nsf.info.Chips&NsfChipVrc7 != 0 {
+		switch addr {
+		case 0x9010:
+			m.regVrc7 = data & 0x3f
+		case 0x9030:
+			m.sys.apu.writeEx(apuVrc7RegBase|uint16(m.regVrc7), data)
+		}
+	}
+	if addr >= 0xf800 && m.nsf.info.Chips&NsfChipN163 != 0 {
+		m.n163.addr = data
+	}
+	if m.nsf.info.Chips&NsfChip5b != 0 {
+		switch addr & 0xe000 {
+		case 0xc000:
+			m.reg5b = data & 0x0f
+		case 0xe000:
+			m.sys.apu.writeEx(0xe000|uint16(m.reg5b), data)
+		}
+	}
+	if m.ram != nil {
+		m.ram[addr-0x6000] = data
+	}
+}
+
+func (m *mapperNsf) clock(nCycle int64) {
+	if m.mmc5 != nil {
+		m.mmc5.sync(int32(nCycle))
+	}
+	if m.playAcc += float64(nCycle); m.playAcc >= m.period {
+		m.playAcc -= m.period
+		m.playPend = true
+	}
+	// a PLAY still running when the next one is due is not interrupted
+	if m.playPend && m.sys.cpu.regPC == nsfIdleAddr {
+		m.playPend = false
+		m.sys.cpu.regPC = nsfPlayAddr
+	}
+}
+
+func (sys *Sys) IsNsf() bool {
+	return sys.rom.nsf != nil
+}
+
+func (sys *Sys) GetNsfInfo() NsfInfo {
+	if sys.rom.nsf == nil {
+		return NsfInfo{}
+	}
+	return sys.rom.nsf.info
+}
+
+// NsfSelectTrack restarts the tune at the given 0-based track.
+func (sys *Sys) NsfSelectTrack(track int) error {
+	nsf := sys.rom.nsf
+	if nsf == nil {
+		return errors.New("not an nsf file")
+	}
+	if track < 0 || track >= int(nsf.info.NSong) {
+		return errors.New("invalid track")
+	}
+	nsf.song = byte(track)
+	sys.reset(false)
+	return nil
+}
+
+func (sys *Sys) NsfGetTrack() int {
+	if sys.rom.nsf == nil {
+		return -1
+	}
+	return int(sys.rom.nsf.song)
+}
+
+// NsfGetTrackTime returns the ms played since the track was started.
+func (sys *Sys) NsfGetTrackTime() float32 {
+	return float32(float64(sys.cpu.nCycle) * 1000.0 / float64(sys.tvFormat.cpuRate))
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func nsfeChunk(id string, n uint32, b []byte) []byte {
+	ch := make([]byte, 8, 8+len(b))
+	binary.LittleEndian.PutUint32(ch, n)
+	copy(ch[4:], id)
+	return append(ch, b...)
+}
+
+func TestNsfeChunks(t *testing.T) {
+	info := nsfeChunk("INFO", 10, []byte{0x00, 0x80, 0x00, 0x80, 0x03, 0x80, 0, 0, 3, 1})
+	data := nsfeChunk("DATA", 4, []byte{0x60, 0x60, 0x60, 0x60})
+	tests := []struct {
+		name   string
+		chunks [][]byte
+		ok     bool
+	}{
+		{"valid", [][]byte{info, data, nsfeChunk("NEND", 0, nil)}, true},
+		{"no data", [][]byte{info, nsfeChunk("NEND", 0, nil)}, false},
+		{"no end", [][]byte{info, data}, false},
+		{"long chunk", [][]byte{info, nsfeChunk("DATA", 0x100, []byte{0x60}), nsfeChunk("NEND", 0, nil)}, false},
+		{"huge chunk", [][]byte{info, nsfeChunk("tlbl", 0xffffffff, nil)}, false},
+		{"required chunk", [][]byte{info, data, nsfeChunk("XTRA", 0, nil), nsfeChunk("NEND", 0, nil)}, false},
+	}
+	for _, tt := range tests {
+		file := append([]byte("NSFE"), bytes.Join(tt.chunks, nil)...)
+		rom, err := newRom(bytes.NewReader(file))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		} else if tt.ok && rom.nsf.info.NSong != 3 {
+			t.Errorf("%s: got %d songs", tt.name, rom.nsf.info.NSong)
+		}
+	}
+}
@@ -29,6 +29,14 @@ func (pad *Pad) reset() {
 	pad.b1u, pad.b2u = 0, 0
 }
 
+func (pad *Pad) serialize(s *stateBuf) {
+	s.bool(&pad.bStrobe)
+	s.u8(&pad.b1)
+	s.u8(&pad.b2)
+	s.u8(&pad.b1u)
+	s.u8(&pad.b2u)
+}
+
 func (pad *Pad) read(addr uint16) byte {
 	var b byte
 	switch addr {
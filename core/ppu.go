@@ -58,6 +58,7 @@ type Ppu struct {
 	screen        *FrameBuffer
 	palette       *[64]uint32
 	spMirrorTable [256]byte
+	dot           ppuDot
 }
 
 func newPpu(sys *Sys) *Ppu {
@@ -100,11 +101,38 @@ func (ppu *Ppu) reset(init bool) {
 	ppu.toggle, ppu.bExtLatch, ppu.bChrLatch = false, false, false
 	ppu.iScanline = 0
 	ppu.palette = &ppuPalette[0]
+	ppu.dot = ppuDot{nCycle: ppu.sys.cpu.nCycle * 12}
+}
+
+func (ppu *Ppu) serialize(s *stateBuf) {
+	s.u8(&ppu.reg0)
+	s.u8(&ppu.reg1)
+	s.u8(&ppu.reg2)
+	s.u8(&ppu.reg3)
+	s.u8(&ppu.readBuf)
+	s.u16(&ppu.loopyT)
+	s.u16(&ppu.loopyV)
+	s.u16(&ppu.loopyX)
+	s.u16(&ppu.loopyY)
+	s.u16(&ppu.loopySh)
+	s.bytes(ppu.bgPal[:])
+	s.bytes(ppu.spPal[:])
+	s.bytes(ppu.spram[:])
+	s.bool(&ppu.toggle)
+	s.bool(&ppu.bExtLatch)
+	s.bool(&ppu.bChrLatch)
+	s.u16(&ppu.iScanline)
+	ppu.dot.serialize(s)
+	iPal := (ppu.reg1 >> 5) | ((ppu.reg1 & ppuReg1ColorMode) << 3)
+	ppu.palette = &ppuPalette[iPal]
 }
 
 func (ppu *Ppu) read(addr uint16) byte {
 	var data byte
 	mem := ppu.sys.mem
+	if ppu.isDot() {
+		ppu.dotSyncAccess()
+	}
 	switch addr {
 	case 0x2000, 0x2001, 0x2003, 0x2005, 0x2006:
 		return ppu.readBuf
@@ -134,6 +162,9 @@ func (ppu *Ppu) read(addr uint16) byte {
 		if addr >= 0x3000 {
 			addr &= 0xefff
 		}
+		if ppu.isDot() {
+			ppu.dotBus(addr)
+		}
 		data = ppu.readBuf
 		ppu.readBuf = mem.ppuBanks[addr>>10][addr&0x03ff]
 		return data
@@ -143,6 +174,9 @@ func (ppu *Ppu) read(addr uint16) byte {
 
 func (ppu *Ppu) write(addr uint16, data byte) {
 	mem := ppu.sys.mem
+	if ppu.isDot() {
+		ppu.dotSyncAccess()
+	}
 	switch addr {
 	case 0x2000:
 		ppu.loopyT = ppu.loopyT&0xf3ff | ((uint16(data) & 0x0003) << 10)
@@ -156,6 +190,9 @@ func (ppu *Ppu) write(addr uint16, data byte) {
 		ppu.reg3 = data
 	case 0x2004:
 		ppu.spram[ppu.reg3] = data
+		if w := ppu.sys.watch; w != nil {
+			w.report(WatchOamWrite, uint16(ppu.reg3), data)
+		}
 		ppu.reg3++
 	case 0x2005:
 		if !ppu.toggle {
@@ -173,10 +210,16 @@ func (ppu *Ppu) write(addr uint16, data byte) {
 			ppu.loopyT = (ppu.loopyT & 0xff00) | uint16(data)
 			ppu.loopyV = ppu.loopyT
 			ppu.sys.mapper.ppuLatch(ppu.loopyV)
+			if ppu.isDot() {
+				ppu.dotBus(ppu.loopyV)
+			}
 		}
 		ppu.toggle = !ppu.toggle
 	case 0x2007:
 		vaddr := ppu.loopyV & 0x3fff
+		if w := ppu.sys.watch; w != nil {
+			w.report(WatchPpuWrite, vaddr, data)
+		}
 		if ppu.reg0&ppuReg0Inc32 != 0 {
 			ppu.loopyV += 32
 		} else {
@@ -199,6 +242,9 @@ func (ppu *Ppu) write(addr uint16, data byte) {
 		if vaddr >= 0x3000 {
 			vaddr &= 0xefff
 		}
+		if ppu.isDot() {
+			ppu.dotBus(vaddr)
+		}
 		if mem.ppuBanksTyp[vaddr>>10] != memBankTypVrom {
 			mem.ppuBanks[vaddr>>10][vaddr&0x03ff] = data
 		}
@@ -210,6 +256,11 @@ func (ppu *Ppu) dma(data byte) {
 	sys, spram := ppu.sys, ppu.spram[:]
 	for i := uint16(0); i < 256; i++ {
 		spram[i] = sys.read(addr + i)
+		// run within the write to $4014, so past the watcher already
+		if w := sys.watch; w != nil {
+			w.report(WatchCpuRead, addr+i, spram[i])
+			w.report(WatchOamWrite, i, spram[i])
+		}
 	}
 }
 
@@ -312,7 +363,7 @@ func (ppu *Ppu) scanlineRender(scanline uint8, bAllSp bool) {
 				tile := (uint16(ppu.reg0&ppuReg0BgTbl) << 8) +
 					(uint16(bank[iNameTbl&0x03ff]) << 4) + ppu.loopyY
 				if i != 0 && bTileMode {
-					sys.runCpu(32)
+					sys.runCpu(sys.tvFormat.dotCycles(8))
 				}
 				attr := ((bank[iAttr+uint16(x>>2)] >> ((x & 0x02) | attrSh)) & 0x03) << 2
 				if i != 0 && prevTile == tile && prevAttr == attr {
@@ -344,7 +395,7 @@ func (ppu *Ppu) scanlineRender(scanline uint8, bAllSp bool) {
 			var chH, chL, exattr byte
 			for i := byte(0); i < endTile; i++ {
 				if i != 0 && bTileMode {
-					sys.runCpu(32)
+					sys.runCpu(sys.tvFormat.dotCycles(8))
 				}
 				sys.mapper.ppuExtLatchX(i)
 				sys.mapper.ppuExtLatch(iNameTbl, &chL, &chH, &exattr)
@@ -381,7 +432,7 @@ func (ppu *Ppu) scanlineRender(scanline uint8, bAllSp bool) {
 			(*p)[i] = c
 		}
 		if sys.renderMode == RenderModeTile {
-			sys.runCpu(1024)
+			sys.runCpu(sys.tvFormat.nHDrawCycle)
 		}
 	}
 
@@ -0,0 +1,424 @@
+package core
+
+const (
+	ppuDotNum      = 341
+	ppuDotVisLines = 240
+
+	// sprite pixels of a line, 0 being transparent
+	ppuSpPixOpaque byte = 0x80
+	ppuSpPixBehind byte = 0x40
+	ppuSpPixZero   byte = 0x20
+	ppuSpPixPal    byte = 0x0f
+)
+
+// ppuDot is the state of the dot renderer. The ppu is run along with the cpu,
+// catching up after every instruction and before every access to its
+// registers, and renders a pixel per dot from the shift registers as the
+// real one. Sprites are evaluated and fetched at the end of a line, and the
+// pixels of the next line made from them at once, which is what the real
+// one does in effect.
+type ppuDot struct {
+	nCycle    int64 // the sys cycle the next dot is at
+	line      uint16
+	x         uint16
+	oddFrame  bool
+	frameDone bool
+
+	nt       byte
+	at       byte
+	patL     byte
+	patH     byte
+	iTile    byte   // the tile of the line being fetched, for ppuExtLatchX
+	tileData uint64 // 4-bit pixels of two tiles, the one drawn on top
+
+	spN    int
+	spIdx  [64]byte
+	spPatL [64]byte
+	spPatH [64]byte
+	spLine [256]byte
+
+	a12      bool
+	a12Cycle int64 // when a12 last went low
+}
+
+func (d *ppuDot) serialize(s *stateBuf) {
+	s.i64(&d.nCycle)
+	s.u16(&d.line)
+	s.u16(&d.x)
+	s.bool(&d.oddFrame)
+	s.bool(&d.frameDone)
+	s.u8(&d.nt)
+	s.u8(&d.at)
+	s.u8(&d.patL)
+	s.u8(&d.patH)
+	s.u8(&d.iTile)
+	s.u64(&d.tileData)
+	spN := byte(d.spN)
+	s.u8(&spN)
+	d.spN = int(spN)
+	s.bytes(d.spIdx[:])
+	s.bytes(d.spPatL[:])
+	s.bytes(d.spPatH[:])
+	s.bytes(d.spLine[:])
+	s.bool(&d.a12)
+	s.i64(&d.a12Cycle)
+}
+
+func (ppu *Ppu) isDot() bool {
+	return ppu.sys.renderMode == RenderModeDot
+}
+
+// dotCycle returns the sys cycle of a dot of the frame, from its start. A pal
+// dot is 3.75 cycles, so the cycles of the dots are 3 or 4 by their place.
+func (ppu *Ppu) dotCycle(line, x uint16) int64 {
+	return (int64(line)*ppuDotNum + int64(x)) * ppu.sys.tvFormat.dotCycle4 >> 2
+}
+
+// dotSync runs the ppu up to the given sys cycle, or to the end of the frame.
+func (ppu *Ppu) dotSync(nCycle int64) {
+	d := &ppu.dot
+	for d.nCycle < nCycle && !d.frameDone {
+		line, x := d.line, d.x
+		ppu.dotStep()
+		d.nCycle += ppu.dotCycle(line, x+1) - ppu.dotCycle(line, x)
+	}
+}
+
+// dotSyncAccess runs the ppu up to the cycle the current instruction
+// accesses a register at, taken as its last one.
+func (ppu *Ppu) dotSyncAccess() {
+	cpu := ppu.sys.cpu
+	ppu.dotSync((cpu.nCycleOp + int64(cpuOpCycles[cpu.opcode]) - 1) * 12)
+}
+
+// dotFrameLeft returns the sys cycles to the end of the frame.
+func (ppu *Ppu) dotFrameLeft() int64 {
+	d := &ppu.dot
+	n := ppu.dotCycle(ppu.sys.tvFormat.nScanline, 0) - ppu.dotCycle(d.line, d.x)
+	return n + d.nCycle - ppu.sys.cpu.nCycle*12
+}
+
+// dotBus puts a fetch address on the bus, to tell the mapper of the rises of
+// a12.
+func (ppu *Ppu) dotBus(addr uint16) {
+	d := &ppu.dot
+	if a12 := addr&0x1000 != 0; a12 != d.a12 {
+		if d.a12 = a12; a12 {
+			ppu.sys.mapper.ppuA12Rise(d.nCycle - d.a12Cycle)
+		} else {
+			d.a12Cycle = d.nCycle
+		}
+	}
+}
+
+func (ppu *Ppu) dotFetch(addr uint16) byte {
+	ppu.dotBus(addr)
+	return ppu.sys.mem.ppuBanks[addr>>10][addr&0x03ff]
+}
+
+func (ppu *Ppu) dotIncX() {
+	if ppu.loopyV&0x001f == 0x001f {
+		ppu.loopyV = (ppu.loopyV &^ 0x001f) ^ 0x0400
+	} else {
+		ppu.loopyV++
+	}
+}
+
+func (ppu *Ppu) dotIncY() {
+	v := ppu.loopyV
+	if v&0x7000 != 0x7000 {
+		ppu.loopyV = v + 0x1000
+		return
+	}
+	v &^= 0x7000
+	switch v & 0x03e0 {
+	case 0x03a0:
+		v = (v &^ 0x03e0) ^ 0x0800
+	case 0x03e0:
+		v &^= 0x03e0
+	default:
+		v += 0x0020
+	}
+	ppu.loopyV = v
+}
+
+func (ppu *Ppu) dotFetchBg(phase uint16) {
+	d, v := &ppu.dot, ppu.loopyV
+	ppu.loopyY = (v >> 12) & 0x07
+	if ppu.bExtLatch {
+		switch phase {
+		case 1:
+			ppu.dotBus(0x2000)
+			ppu.sys.mapper.ppuExtLatchX(d.iTile)
+			ppu.sys.mapper.ppuExtLatch((v&0x0fff)|0x2000, &d.patL, &d.patH, &d.at)
+			d.at >>= 2
+		case 5, 7:
+			ppu.dotBus(uint16(ppu.reg0&ppuReg0BgTbl) << 8)
+		}
+		return
+	}
+	switch phase {
+	case 1:
+		d.nt = ppu.dotFetch((v & 0x0fff) | 0x2000)
+	case 3:
+		a := ppu.dotFetch(0x23c0 | (v & 0x0c00) | ((v >> 4) & 0x38) | ((v >> 2) & 0x07))
+		d.at = (a >> (((v >> 4) & 0x04) | (v & 0x02))) & 0x03
+	case 5:
+		d.patL = ppu.dotFetch(ppu.dotBgAddr())
+	case 7:
+		addr := ppu.dotBgAddr()
+		d.patH = ppu.dotFetch(addr + 8)
+		if ppu.bChrLatch {
+			ppu.sys.mapper.ppuChrLatch(addr)
+		}
+	}
+}
+
+func (ppu *Ppu) dotBgAddr() uint16 {
+	return uint16(ppu.reg0&ppuReg0BgTbl)<<8 | uint16(ppu.dot.nt)<<4 | (ppu.loopyV>>12)&0x07
+}
+
+// dotStoreTile appends the fetched tile to the pixels to draw.
+func (ppu *Ppu) dotStoreTile() {
+	d := &ppu.dot
+	var data uint32
+	l, h, a := d.patL, d.patH, uint32(d.at)<<2
+	for i := 0; i < 8; i++ {
+		data = data<<4 | a | uint32(l>>7) | uint32(h>>7)<<1
+		l, h = l<<1, h<<1
+	}
+	d.tileData |= uint64(data)
+	d.iTile++
+}
+
+// dotSpAddr returns the pattern address of a sprite row, dy being counted
+// from the top of the sprite.
+func (ppu *Ppu) dotSpAddr(tile byte, attr byte, dy byte) uint16 {
+	if ppu.reg0&ppuReg0Sp16 == 0 {
+		if attr&ppuSpAttrVMirror != 0 {
+			dy = 7 - dy
+		}
+		return uint16(ppu.reg0&ppuReg0SpTbl)<<9 | uint16(tile)<<4 | uint16(dy)
+	}
+	if attr&ppuSpAttrVMirror != 0 {
+		dy = 15 - dy
+	}
+	return uint16(tile&0x01)<<12 | uint16(tile&0xfe)<<4 | uint16(dy&0x08)<<1 | uint16(dy&0x07)
+}
+
+// dotEvalSp finds the sprites of the next line.
+func (ppu *Ppu) dotEvalSp() {
+	d, spram := &ppu.dot, ppu.spram[:]
+	h := uint16(8)
+	if ppu.reg0&ppuReg0Sp16 != 0 {
+		h = 16
+	}
+	nMax := 8
+	if ppu.sys.conf.AllSprite {
+		nMax = 64
+	}
+	d.spN = 0
+	for i := 0; i < 64; i++ {
+		if dy := d.line - uint16(spram[i<<2]); dy < h {
+			if d.spN == 8 {
+				ppu.reg2 |= ppuReg2SpMax
+			}
+			if d.spN == nMax {
+				break
+			}
+			d.spIdx[d.spN] = byte(i)
+			d.spN++
+		}
+	}
+}
+
+func (ppu *Ppu) dotSpRowAddr(i int) uint16 {
+	j := uint16(ppu.dot.spIdx[i]) << 2
+	spram := ppu.spram[:]
+	return ppu.dotSpAddr(spram[j+1], spram[j+2], byte(ppu.dot.line-uint16(spram[j])))
+}
+
+// dotFetchSp fetches the patterns of one of the 8 sprite slots, the empty
+// ones reading those of tile $ff.
+func (ppu *Ppu) dotFetchSp(i int) {
+	d := &ppu.dot
+	ppu.dotBus(0x2000)
+	if i >= d.spN {
+		ppu.dotFetch(ppu.dotSpAddr(0xff, 0, 0))
+		return
+	}
+	addr := ppu.dotSpRowAddr(i)
+	d.spPatL[i], d.spPatH[i] = ppu.dotFetch(addr), ppu.dotFetch(addr+8)
+	if ppu.bChrLatch {
+		ppu.sys.mapper.ppuChrLatch(addr)
+	}
+}
+
+// dotMakeSpLine draws the sprites found into the pixels of the next line,
+// the first ones on top.
+func (ppu *Ppu) dotMakeSpLine() {
+	d, spram, banks := &ppu.dot, ppu.spram[:], ppu.sys.mem.ppuBanks[:]
+	// sprites past 8, with AllSprite, are fetched off the bus
+	for i := 8; i < d.spN; i++ {
+		addr := ppu.dotSpRowAddr(i)
+		d.spPatL[i], d.spPatH[i] = banks[addr>>10][addr&0x03ff], banks[addr>>10][(addr&0x03ff)+8]
+	}
+	for i := range d.spLine {
+		d.spLine[i] = 0
+	}
+	var spOfs byte
+	if ppu.bExtLatch {
+		spOfs = ppu.sys.mapper.ppuExtLatchSpOfs()
+	}
+	for i := 0; i < d.spN; i++ {
+		j := uint16(d.spIdx[i]) << 2
+		attr, x := spram[j+2], int(spram[j+3])
+		l, h := d.spPatL[i], d.spPatH[i]
+		if attr&ppuSpAttrHMirror != 0 {
+			l, h = ppu.spMirrorTable[l], ppu.spMirrorTable[h]
+		}
+		pix := ppuSpPixOpaque | ((attr&ppuSpAttrColor)<<2+spOfs)&ppuSpPixPal
+		if attr&ppuSpAttrPriority != 0 {
+			pix |= ppuSpPixBehind
+		}
+		if j == 0 {
+			pix |= ppuSpPixZero
+		}
+		for k := 0; k < 8 && x+k < len(d.spLine); k++ {
+			c := l>>7 | (h>>7)<<1
+			l, h = l<<1, h<<1
+			if c != 0 && d.spLine[x+k] == 0 {
+				d.spLine[x+k] = pix | c
+			}
+		}
+	}
+}
+
+func (ppu *Ppu) dotPixel(x uint16) {
+	d := &ppu.dot
+	var bg, sp byte
+	if ppu.reg1&ppuReg1BgDisp != 0 && (x >= 8 || ppu.reg1&ppuReg1BgClip != 0) {
+		bg = byte(d.tileData>>(32+(7-ppu.loopyX)*4)) & 0x0f
+		if bg&0x03 == 0 {
+			bg = 0
+		}
+	}
+	if ppu.reg1&ppuReg1SpDisp != 0 && (x >= 8 || ppu.reg1&ppuReg1SpClip != 0) {
+		sp = d.spLine[x]
+	}
+	c := ppu.bgPal[bg]
+	if sp != 0 {
+		if bg != 0 && sp&ppuSpPixZero != 0 && x != 255 {
+			ppu.reg2 |= ppuReg2SpHit
+		}
+		if bg == 0 || sp&ppuSpPixBehind == 0 {
+			c = ppu.spPal[sp&ppuSpPixPal]
+		}
+	}
+	(*ppu.screen)[uint32(d.line)*ScreenWidth+8+uint32(x)] = (*ppu.palette)[c]
+}
+
+func (ppu *Ppu) dotLineStart() {
+	d, sys := &ppu.dot, ppu.sys
+	sys.scanline = d.line
+	switch d.line {
+	case ppuDotVisLines:
+		sys.mapper.vSync()
+		sys.pad.vSync()
+	}
+	if d.line < ppuDotVisLines {
+		iPal := (ppu.reg1 >> 5) | ((ppu.reg1 & ppuReg1ColorMode) << 3)
+		ppu.palette = &ppuPalette[iPal]
+		p := (*ppu.screen)[uint32(d.line)*ScreenWidth:][:ScreenWidth]
+		for i := 0; i < 8; i++ {
+			p[i], p[ScreenWidth-8+i] = 0xff000000, 0xff000000
+		}
+	}
+}
+
+func (ppu *Ppu) dotStep() {
+	d, sys := &ppu.dot, ppu.sys
+	line, x := d.line, d.x
+	lastLine := sys.tvFormat.nScanline - 1
+	bRender := ppu.reg1&(ppuReg1BgDisp|ppuReg1SpDisp) != 0
+	bVis := line < ppuDotVisLines
+
+	if x == 0 {
+		ppu.dotLineStart()
+	}
+	if bVis && x >= 1 && x <= 256 {
+		ppu.dotPixel(x - 1)
+	}
+	if bRender && (bVis || line == lastLine) {
+		if x == 321 {
+			d.iTile = 0
+		}
+		switch {
+		case x >= 1 && x <= 256, x >= 321 && x <= 336:
+			d.tileData <<= 4
+			ph := x & 0x07
+			if ph == 0 {
+				ppu.dotStoreTile()
+				ppu.dotIncX()
+				if x == 256 {
+					ppu.dotIncY()
+				}
+			} else {
+				ppu.dotFetchBg(ph)
+			}
+		case x == 257:
+			ppu.loopyV = (ppu.loopyV &^ 0x041f) | (ppu.loopyT & 0x041f)
+			d.spN = 0
+			if bVis {
+				ppu.dotEvalSp()
+			}
+			ppu.dotFetchSp(0)
+		case x > 257 && x < 321:
+			if x&0x07 == 1 {
+				ppu.dotFetchSp(int(x-257) >> 3)
+			} else if x == 320 {
+				ppu.dotMakeSpLine()
+			}
+		case x == 337, x == 339:
+			ppu.dotFetch((ppu.loopyV & 0x0fff) | 0x2000)
+		}
+		if line == lastLine && x >= 280 && x <= 304 {
+			ppu.loopyV = (ppu.loopyV &^ 0x7be0) | (ppu.loopyT & 0x7be0)
+		}
+	}
+
+	switch {
+	case x == 1 && line == 241:
+		ppu.reg2 |= ppuReg2VBlank
+		if ppu.reg0&ppuReg0VBlank != 0 {
+			sys.cpu.intr |= cpuIntrTypNmi
+		}
+	case x == 1 && line == lastLine:
+		ppu.reg2 &^= ppuReg2VBlank | ppuReg2SpHit | ppuReg2SpMax
+	case x == 256:
+		sys.mapper.hSync(line)
+	}
+
+	if x == 339 && line == lastLine && d.oddFrame && bRender && sys.conf.TvFormat == TvFormatNtsc {
+		x++
+	}
+	if x++; x == ppuDotNum {
+		x = 0
+		if line++; line > lastLine {
+			line = 0
+			d.oddFrame, d.frameDone = !d.oddFrame, true
+		}
+	}
+	d.line, d.x = line, x
+}
+
+// RunFrame in the dot render mode.
+func (sys *Sys) runFrameDot() {
+	ppu := sys.ppu
+	ppu.dot.frameDone = false
+	ppu.dotSync(sys.cpu.nCycle * 12)
+	for !ppu.dot.frameDone {
+		sys.runCpu(ppu.dotFrameLeft())
+	}
+	sys.apu.render()
+}
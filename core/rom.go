@@ -1,11 +1,27 @@
 package core
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"hash/crc32"
 	"io"
 )
 
+const (
+	RomTimingNtsc byte = iota
+	RomTimingPal
+	RomTimingMulti
+	RomTimingDendy
+)
+
+const (
+	RomConsoleNes byte = iota
+	RomConsoleVs
+	RomConsolePlayChoice
+	RomConsoleExt
+)
+
 type NesFileHeader struct {
 	Magic     uint32
 	NPromPage byte
@@ -15,36 +31,138 @@ type NesFileHeader struct {
 	Reserved  [8]byte
 }
 
+type RomInfo struct {
+	Crc           uint32 // of prg rom followed by chr rom
+	PrgCrc        uint32 // of prg rom alone
+	Nes20         bool
+	MapperNo      uint16
+	SubmapperNo   byte
+	PromSize      uint32
+	VromSize      uint32
+	PramSize      uint32
+	PnvramSize    uint32
+	CramSize      uint32
+	CnvramSize    uint32
+	VMirror       bool
+	SaveRam       bool
+	Trainer       bool
+	FourScreen    bool
+	Timing        byte
+	ConsoleTyp    byte
+	VsPpuTyp      byte
+	VsHwTyp       byte
+	ExtConsoleTyp byte
+	ExpDevice     byte
+	FdsSideNum    byte
+}
+
 type Rom struct {
-	nPromPage byte
-	nVromPage byte
+	info      RomInfo
+	nPromPage uint32
+	nVromPage uint32
 	bVMirror  bool
 	bSaveRam  bool
 	bTrainer  bool
 	b4Screen  bool
-	mapperNo  byte
+	mapperNo  uint16
 	prom      []byte
 	vrom      []byte
 	trn       []byte
+	fds       *fdsDisk
+	nsf       *nsfFile
+}
+
+// romMaxSize bounds the rom sizes of a nes 2.0 header, above any of the
+// regular notation.
+const romMaxSize = 0x4000000
+
+// romSize decodes a nes 2.0 rom size from its lsb and msb nibble, the latter
+// selecting the exponent-multiplier notation when it is 0x0f. It returns false
+// if over romMaxSize.
+func romSize(lsb byte, msb byte, unit uint32) (uint32, bool) {
+	var size uint64
+	if msb != 0x0f {
+		size = ((uint64(msb) << 8) | uint64(lsb)) * uint64(unit)
+	} else {
+		size = (uint64(lsb&0x03)*2 + 1) << (lsb >> 2)
+	}
+	return uint32(size), size <= romMaxSize
+}
+
+func ramSize(shift byte) uint32 {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << shift
 }
 
 func newRom(file io.Reader) (*Rom, error) {
-	header := &NesFileHeader{}
-	if err := binary.Read(file, binary.LittleEndian, header); err != nil {
+	var raw [16]byte
+	if _, err := io.ReadFull(file, raw[:]); err != nil {
 		return nil, err
 	}
-	if header.Magic != 0x1a53454e { // "\x1aNES"
+	header := &NesFileHeader{}
+	binary.Read(bytes.NewReader(raw[:]), binary.LittleEndian, header)
+	switch {
+	case header.Magic == 0x4d53454e: // "NESM"
+		return newNsfRom(file, raw[:])
+	case header.Magic == 0x4546534e: // "NSFE"
+		return newNsfeRom(io.MultiReader(bytes.NewReader(raw[4:]), file))
+	case header.Magic == 0x1a534446: // "FDS\x1a"
+		return newFdsRom(file, nil)
+	case bytes.HasPrefix(raw[:], fdsDiskMagic):
+		return newFdsRom(file, raw[:])
+	case header.Magic != 0x1a53454e: // "\x1aNES"
 		return nil, errors.New("unsupported file type")
 	}
 
 	rom := &Rom{}
-	rom.nPromPage = header.NPromPage
-	rom.nVromPage = header.NVromPage
-	rom.bVMirror = header.Control1&0x01 != 0
-	rom.bSaveRam = header.Control1&0x02 != 0
-	rom.bTrainer = header.Control1&0x04 != 0
-	rom.b4Screen = header.Control1&0x08 != 0
-	rom.mapperNo = (header.Control1 >> 4) | (header.Control2 & 0xf0)
+	info, ext := &rom.info, &header.Reserved
+	info.VMirror = header.Control1&0x01 != 0
+	info.SaveRam = header.Control1&0x02 != 0
+	info.Trainer = header.Control1&0x04 != 0
+	info.FourScreen = header.Control1&0x08 != 0
+	info.MapperNo = uint16((header.Control1 >> 4) | (header.Control2 & 0xf0))
+	info.ConsoleTyp = header.Control2 & 0x03
+	info.Nes20 = header.Control2&0x0c == 0x08
+	if info.Nes20 {
+		info.MapperNo |= uint16(ext[0]&0x0f) << 8
+		info.SubmapperNo = ext[0] >> 4
+		var okP, okV bool
+		info.PromSize, okP = romSize(header.NPromPage, ext[1]&0x0f, 0x4000)
+		info.VromSize, okV = romSize(header.NVromPage, ext[1]>>4, 0x2000)
+		if !okP || !okV {
+			return nil, errors.New("rom size too large")
+		}
+		info.PramSize, info.PnvramSize = ramSize(ext[2]&0x0f), ramSize(ext[2]>>4)
+		info.CramSize, info.CnvramSize = ramSize(ext[3]&0x0f), ramSize(ext[3]>>4)
+		info.Timing = ext[4] & 0x03
+		switch info.ConsoleTyp {
+		case RomConsoleVs:
+			info.VsPpuTyp, info.VsHwTyp = ext[5]&0x0f, ext[5]>>4
+		case RomConsoleExt:
+			info.ExtConsoleTyp = ext[5] & 0x0f
+		}
+		info.ExpDevice = ext[7] & 0x3f
+	} else {
+		if info.ConsoleTyp == RomConsoleExt {
+			info.ConsoleTyp = RomConsoleVs
+		}
+		info.PromSize = uint32(header.NPromPage) * 0x4000
+		info.VromSize = uint32(header.NVromPage) * 0x2000
+		info.PramSize = 0x2000
+		if info.VromSize == 0 {
+			info.CramSize = 0x2000
+		}
+	}
+
+	rom.nPromPage = (info.PromSize + 0x3fff) >> 14
+	rom.nVromPage = (info.VromSize + 0x1fff) >> 13
+	rom.bVMirror = info.VMirror
+	rom.bSaveRam = info.SaveRam
+	rom.bTrainer = info.Trainer
+	rom.b4Screen = info.FourScreen
+	rom.mapperNo = info.MapperNo
 	println("mapper: ", rom.mapperNo) //ldeng7
 
 	if rom.bTrainer {
@@ -53,16 +171,18 @@ func newRom(file io.Reader) (*Rom, error) {
 			return nil, err
 		}
 	}
-	rom.prom = make([]byte, uint32(rom.nPromPage)*0x4000)
-	if _, err := io.ReadFull(file, rom.prom); err != nil {
+	rom.prom = make([]byte, rom.nPromPage*0x4000)
+	if _, err := io.ReadFull(file, rom.prom[:info.PromSize]); err != nil {
 		return nil, err
 	}
 	if rom.nVromPage != 0 {
-		rom.vrom = make([]byte, uint32(rom.nVromPage)*0x2000)
-		if _, err := io.ReadFull(file, rom.vrom); err != nil {
+		rom.vrom = make([]byte, rom.nVromPage*0x2000)
+		if _, err := io.ReadFull(file, rom.vrom[:info.VromSize]); err != nil {
 			return nil, err
 		}
 	}
+	info.PrgCrc = crc32.ChecksumIEEE(rom.prom[:info.PromSize])
+	info.Crc = crc32.Update(info.PrgCrc, crc32.IEEETable, rom.vrom[:info.VromSize])
 
 	return rom, nil
 }
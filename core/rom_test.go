@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testNes20Rom returns testRom with a nes 2.0 header of the bytes 8 to 15
+// given.
+func testNes20Rom(mapperNo byte, ext [8]byte) []byte {
+	rom := testRom(mapperNo, testProg)
+	rom[7] = rom[7]&0xf3 | 0x08
+	copy(rom[8:16], ext[:])
+	return rom
+}
+
+func TestRomNes20Header(t *testing.T) {
+	tests := []struct {
+		name string
+		ext  [8]byte
+		want RomInfo
+	}{
+		{"sizes", [8]byte{0x31, 0x00, 0x97, 0x07, 0x01},
+			RomInfo{MapperNo: 0x100, SubmapperNo: 3, PromSize: 0x8000, VromSize: 0x2000,
+				PramSize: 0x2000, PnvramSize: 0x8000, CramSize: 0x2000, Timing: RomTimingPal}},
+		{"no ram", [8]byte{0, 0, 0, 0, 0x03},
+			RomInfo{PromSize: 0x8000, VromSize: 0x2000, Timing: RomTimingDendy}},
+	}
+	for _, tt := range tests {
+		rom := testNes20Rom(0, tt.ext)
+		r, err := newRom(bytes.NewReader(rom))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := r.info
+		got.Crc, got.PrgCrc, got.Nes20 = 0, 0, false
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRomSize(t *testing.T) {
+	tests := []struct {
+		lsb, msb byte
+		unit     uint32
+		size     uint32
+		ok       bool
+	}{
+		{0x02, 0x00, 0x4000, 0x8000, true},
+		{0xff, 0x0e, 0x4000, 0xeff * 0x4000, true},
+		{0x00, 0x01, 0x2000, 0x100 * 0x2000, true},
+		{0x0e, 0x0f, 0x4000, 5 << 3, true},   // 2^3 x 5
+		{0x65, 0x0f, 0x4000, 3 << 25, false}, // 2^25 x 3
+		{0xff, 0x0f, 0x4000, 0, false},
+	}
+	for _, tt := range tests {
+		size, ok := romSize(tt.lsb, tt.msb, tt.unit)
+		if ok != tt.ok || (ok && size != tt.size) {
+			t.Errorf("romSize(%#x, %#x) = %#x, %v, want %#x, %v", tt.lsb, tt.msb, size, ok, tt.size, tt.ok)
+		}
+	}
+
+	rom := testNes20Rom(0, [8]byte{0, 0x0f})
+	rom[4] = 0xfc // 2^63
+	if _, err := newRom(bytes.NewReader(rom)); err == nil {
+		t.Error("accepted a prg rom of 2^63 bytes")
+	}
+}
+
+// The mappers banking more wram than a nes 2.0 header gives mirror it.
+func TestRomSmallWram(t *testing.T) {
+	tests := []struct {
+		mapperNo byte
+		patchTyp uint64
+		addr     uint16
+		data     byte
+		nWrite   int
+	}{
+		{1, 0x01, 0xbfff, 0x01, 5}, // the wram bank of sorom
+		{5, 0, 0x5117, 0x07, 1},
+		{5, 0, 0x5113, 0x07, 1},
+	}
+	for _, tt := range tests {
+		rom := testNes20Rom(tt.mapperNo, [8]byte{0, 0, 0x07})
+		sys, err := NewSys(bytes.NewReader(rom), &Conf{PatchTyp: tt.patchTyp, NoRomDb: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(sys.mem.wram); n != 0x2000 {
+			t.Fatalf("mapper %d: wram of %#x bytes", tt.mapperNo, n)
+		}
+		sys.SetFrameBuffer(&FrameBuffer{})
+		sys.mem.wram[0] = 0x5a
+		for i := 0; i < tt.nWrite; i++ {
+			sys.write(tt.addr, tt.data)
+		}
+		if b := sys.mem.cpuBanks[3][0]; b != 0x5a {
+			t.Errorf("mapper %d, %04x: read %#x from $6000, want the mirror of wram", tt.mapperNo, tt.addr, b)
+		}
+		sys.RunFrame()
+	}
+}
+
+func TestRomTvFormat(t *testing.T) {
+	tests := []struct {
+		conf   byte
+		timing byte
+		want   byte
+	}{
+		{TvFormatAuto, RomTimingNtsc, TvFormatNtsc},
+		{TvFormatAuto, RomTimingPal, TvFormatPal},
+		{TvFormatAuto, RomTimingDendy, TvFormatPalChina},
+		{TvFormatNtsc, RomTimingPal, TvFormatNtsc},
+		{0x80, RomTimingPal, TvFormatPal},
+	}
+	for _, tt := range tests {
+		rom := testNes20Rom(0, [8]byte{4: tt.timing})
+		sys, err := NewSys(bytes.NewReader(rom), &Conf{TvFormat: tt.conf, NoRomDb: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sys.conf.TvFormat != tt.want {
+			t.Errorf("conf %d, timing %d: got %d, want %d", tt.conf, tt.timing, sys.conf.TvFormat, tt.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	RomDbMapper byte = 1 << iota
+	RomDbPatch
+	RomDbMirror
+	RomDbRenderMode
+	RomDbTvFormat
+)
+
+const (
+	RomMirrorH byte = iota
+	RomMirrorV
+	RomMirror4
+)
+
+// romDbBuiltin uses the same text format accepted by RomDb.Load: one game per
+// line, keyed by the crc32 of prg rom followed by chr rom (no header, no
+// trainer), or of prg rom alone as prg:<crc32>, with space separated
+// key=value fields:
+//
+//	mapper=<n>               correct a bad header mapper number
+//	patch=<n>                mapper specific Conf.PatchTyp bits
+//	eeprom=24c01|24c02|both|none
+//	                         mapper 16 eeprom type, merged into patch
+//	mirror=h|v|4
+//	render=pre|post|preall|postall|tile|dot
+//	tv=ntsc|pal|palchina
+//
+// Text from a # on is a comment. The builtin entries are the games the
+// patches of the mappers were made for, keyed by prg rom as they were known.
+const romDbBuiltin = `
+# mapper 4: patch 0x01 the irq of klax, 0x02 of shougi meikan, 0x04 of dai 2
+# ji super robot taisen, 0x20 the tile renderer
+prg:5c707ac4 patch=0x20 # Mother (J)
+prg:cb106f49 patch=0x20 # F-1 Sensation (J)
+prg:14a01c70 patch=0x20 # Gun-Dec (J)
+prg:effeea40 patch=0x21 # Klax (J)
+prg:c17ae2dc patch=0x20 # God Slayer - Haruka Tenkuu no Sonata (J)
+prg:5a6860f1 patch=0x02 # Shougi Meikan '92 (J)
+prg:ae280e20 patch=0x02 # Shougi Meikan '93 (J)
+prg:e19a2473 patch=0x20 # Sugoro Quest - Dice no Senshi Tachi (J)
+prg:a9a0d729 patch=0x20 # Dai Kaijuu - Deburas (J)
+prg:c5fea9f2 patch=0x04 # Dai 2 Ji - Super Robot Taisen (J)
+prg:d852c2f7 patch=0x20 # Time Zone (J)
+prg:ecfd3c69 patch=0x20 # Taito Chase H.Q. (J)
+
+# mapper 5: patch 0x01 16 KB of sram, 0x02 32 KB, 0x04 the irq of metal
+# slader glory, 0x08 the chr banks of yakuman tengoku
+prg:95ca9ec7 render=tile # Castlevania III - Dracula's Curse (U)
+prg:cd9acf43 patch=0x04  # Metal Slader Glory (J)
+prg:e91548d8 patch=0x08  # Shin 4 Nin Uchi Mahjong - Yakuman Tengoku (J)
+prg:2b548d75 patch=0x01  # Bandit Kings of Ancient China (U)
+prg:f4cd4998 patch=0x01  # Dai Koukai Jidai (J)
+prg:8fa95456 patch=0x01  # Ishin no Arashi (J)
+prg:98c8e090 patch=0x01  # Nobunaga no Yabou - Sengoku Gunyuu Den (J)
+prg:57e3218b patch=0x01  # L'Empereur (U)
+prg:2f50bd38 patch=0x01  # L'Empereur (J)
+prg:b56958d1 patch=0x01  # Nobunaga's Ambition 2 (U)
+prg:e6c28c5f patch=0x01  # Suikoden - Tenmei no Chikai (J)
+prg:cd35e2e9 patch=0x01  # Uncharted Waters (U)
+prg:f4120e58 patch=0x02  # Aoki Ookami to Shiroki Mejika - Genchou Hishi (J)
+prg:286613d8 patch=0x02  # Nobunaga no Yabou - Bushou Fuuun Roku (J)
+prg:11eaad26 patch=0x02  # Romance of the Three Kingdoms 2 (U)
+prg:95ba5733 patch=0x02  # Sangokushi 2 (J)
+
+# mapper 16: patch 0x01 the sram of famicom jump 2, 0x20 the pre all
+# renderer
+prg:3f15d20d patch=0x01 eeprom=none    # Famicom Jump 2 (J)
+prg:1d6f27f7 eeprom=24c02              # Dragon Ball Z 2 - Gekishin Freeza!! (J)
+prg:dd8ced31 eeprom=24c02              # Dragon Ball Z 3 - Ressen Jinzou Ningen (J)
+prg:09499f4d eeprom=24c02              # Dragon Ball Z - Kyoushuu! Saiya Jin (J)
+prg:170250de patch=0x20 eeprom=24c02   # Dragon Ball Z Gaiden - Saiya Jin Zetsumetsu Keikaku (J)
+prg:81a15eb8 eeprom=24c02              # SD Gundam Gaiden - Knight Gundam Monogatari 3 (J)
+
+# mapper 19: patch 0x01 no name table banks, 0x02 and 0x04 the same with the
+# mirroring by bit 6 and bit 7 of $e000
+prg:b62a7b71 patch=0x01                # Family Circuit '91 (J)
+prg:02738c68 patch=0x04                # Wagan Land 2 (J)
+prg:14942c06 patch=0x04                # Wagan Land 3 (J)
+prg:af15338f patch=0x02                # Mindseeker (J)
+prg:b1b9e187 patch=0x02 render=preall  # Kaijuu Monogatari (J)
+prg:96533999 patch=0x02                # Dokuganryuu Masamune (J)
+prg:3296ff7a patch=0x02                # Battle Fleet (J)
+prg:dd454208 patch=0x02                # Hydlide 3 - Yami kara no Houmonsha (J)
+prg:968dcf09 render=preall             # Final Lap (J)
+prg:3deac303 render=postall            # Rolling Thunder (J)
+prg:6901346e render=tile               # Sangokushi 2 - Haou no Tairiku (J)
+`
+
+type RomDbEntry struct {
+	Flags      byte
+	MapperNo   uint16
+	PatchTyp   uint64
+	Mirror     byte
+	RenderMode byte
+	TvFormat   byte
+}
+
+type RomDb struct {
+	entries    map[uint32]*RomDbEntry
+	prgEntries map[uint32]*RomDbEntry
+}
+
+var romDbDefault = NewRomDb()
+
+// NewRomDb returns a database holding the builtin entries, which entries
+// loaded afterwards extend or replace.
+func NewRomDb() *RomDb {
+	db := &RomDb{entries: map[uint32]*RomDbEntry{}, prgEntries: map[uint32]*RomDbEntry{}}
+	if err := db.Load(strings.NewReader(romDbBuiltin)); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func (db *RomDb) Lookup(crc uint32) *RomDbEntry {
+	return db.entries[crc]
+}
+
+func (db *RomDb) Set(crc uint32, e *RomDbEntry) {
+	db.entries[crc] = e
+}
+
+// LookupPrg and SetPrg are of the entries keyed by the crc of prg rom alone,
+// looked up when there is none of the whole rom.
+func (db *RomDb) LookupPrg(crc uint32) *RomDbEntry {
+	return db.prgEntries[crc]
+}
+
+func (db *RomDb) SetPrg(crc uint32, e *RomDbEntry) {
+	db.prgEntries[crc] = e
+}
+
+var romDbEeproms = map[string]uint64{
+	"24c01": 0x00, "24c02": 0x02, "both": 0x04, "none": 0x08,
+}
+var romDbMirrors = map[string]byte{
+	"h": RomMirrorH, "v": RomMirrorV, "4": RomMirror4,
+}
+var romDbRenderModes = map[string]byte{
+	"pre": RenderModePre, "post": RenderModePost, "preall": RenderModePreAll,
+	"postall": RenderModePostAll, "tile": RenderModeTile, "dot": RenderModeDot,
+}
+var romDbTvFormats = map[string]byte{
+	"ntsc": TvFormatNtsc, "pal": TvFormatPal, "palchina": TvFormatPalChina,
+}
+
+func (db *RomDb) Load(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for iLine := 1; sc.Scan(); iLine++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		entries, key := db.entries, fields[0]
+		if strings.HasPrefix(key, "prg:") {
+			entries, key = db.prgEntries, key[4:]
+		}
+		crc, err := strconv.ParseUint(key, 16, 32)
+		if err != nil {
+			return fmt.Errorf("romdb line %d: bad crc %q", iLine, fields[0])
+		}
+		e := &RomDbEntry{}
+		for _, f := range fields[1:] {
+			if err := e.parseField(f); err != nil {
+				return fmt.Errorf("romdb line %d: %s", iLine, err.Error())
+			}
+		}
+		entries[uint32(crc)] = e
+	}
+	return sc.Err()
+}
+
+func (e *RomDbEntry) parseField(f string) error {
+	kv := strings.SplitN(f, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("bad field %q", f)
+	}
+	k, v := kv[0], strings.ToLower(kv[1])
+	ok := true
+	switch k {
+	case "mapper":
+		n, err := strconv.ParseUint(v, 0, 12)
+		e.MapperNo, ok = uint16(n), err == nil
+		e.Flags |= RomDbMapper
+	case "patch":
+		n, err := strconv.ParseUint(v, 0, 64)
+		e.PatchTyp, ok = e.PatchTyp|n, err == nil
+		e.Flags |= RomDbPatch
+	case "eeprom":
+		var n uint64
+		n, ok = romDbEeproms[v]
+		e.PatchTyp |= n
+		e.Flags |= RomDbPatch
+	case "mirror":
+		e.Mirror, ok = romDbMirrors[v]
+		e.Flags |= RomDbMirror
+	case "render":
+		e.RenderMode, ok = romDbRenderModes[v]
+		e.Flags |= RomDbRenderMode
+	case "tv":
+		e.TvFormat, ok = romDbTvFormats[v]
+		e.Flags |= RomDbTvFormat
+	default:
+		return fmt.Errorf("unknown field %q", k)
+	}
+	if !ok {
+		return fmt.Errorf("bad value in %q", f)
+	}
+	return nil
+}
+
+func (sys *Sys) applyRomDb() {
+	if sys.conf.NoRomDb {
+		return
+	}
+	db := sys.conf.RomDb
+	if db == nil {
+		db = romDbDefault
+	}
+	rom := sys.rom
+	e := db.Lookup(rom.info.Crc)
+	if e == nil {
+		if e = db.LookupPrg(rom.info.PrgCrc); e == nil {
+			return
+		}
+	}
+
+	if e.Flags&RomDbMapper != 0 {
+		rom.mapperNo, rom.info.MapperNo = e.MapperNo, e.MapperNo
+	}
+	if e.Flags&RomDbMirror != 0 {
+		rom.bVMirror, rom.b4Screen = e.Mirror == RomMirrorV, e.Mirror == RomMirror4
+		rom.info.VMirror, rom.info.FourScreen = rom.bVMirror, rom.b4Screen
+	}
+	if e.Flags&RomDbPatch != 0 && sys.conf.PatchTyp == 0 {
+		sys.conf.PatchTyp = e.PatchTyp
+	}
+	if e.Flags&RomDbRenderMode != 0 && sys.conf.RenderMode > RenderModeDot {
+		sys.conf.RenderMode = e.RenderMode
+	}
+	if e.Flags&RomDbTvFormat != 0 && sys.conf.TvFormat == TvFormatAuto {
+		sys.conf.TvFormat = e.TvFormat
+	}
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bytes"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func TestRomDbLoad(t *testing.T) {
+	db := &RomDb{entries: map[uint32]*RomDbEntry{}, prgEntries: map[uint32]*RomDbEntry{}}
+	err := db.Load(strings.NewReader(`
+# a comment
+0000abcd mapper=4 mirror=V render=tile tv=pal
+prg:1234 patch=0x21 eeprom=24c02 # the game
+
+00001234 patch=1 patch=0x10 eeprom=none
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		e    *RomDbEntry
+		want RomDbEntry
+	}{
+		{db.Lookup(0xabcd), RomDbEntry{Flags: RomDbMapper | RomDbMirror | RomDbRenderMode | RomDbTvFormat,
+			MapperNo: 4, Mirror: RomMirrorV, RenderMode: RenderModeTile, TvFormat: TvFormatPal}},
+		{db.LookupPrg(0x1234), RomDbEntry{Flags: RomDbPatch, PatchTyp: 0x23}},
+		{db.Lookup(0x1234), RomDbEntry{Flags: RomDbPatch, PatchTyp: 0x19}},
+	}
+	for i, tt := range tests {
+		if tt.e == nil {
+			t.Errorf("%d: not found", i)
+		} else if *tt.e != tt.want {
+			t.Errorf("%d: got %+v, want %+v", i, *tt.e, tt.want)
+		}
+	}
+	if db.LookupPrg(0xabcd) != nil {
+		t.Error("found an entry of the whole rom by prg rom")
+	}
+
+	for _, line := range []string{
+		"xyz patch=1",
+		"prg: patch=1",
+		"1234 patch",
+		"1234 patch=x",
+		"1234 mapper=4096",
+		"1234 mirror=d",
+		"1234 render=fast",
+		"1234 tv=secam",
+		"1234 eeprom=24c04",
+		"1234 speed=2",
+	} {
+		if err := db.Load(strings.NewReader(line)); err == nil {
+			t.Errorf("%q: no error", line)
+		}
+	}
+}
+
+func TestRomDbBuiltin(t *testing.T) {
+	db := NewRomDb()
+	if len(db.prgEntries) == 0 {
+		t.Fatal("no builtin entries")
+	}
+	// Castlevania III (U)
+	if e := db.LookupPrg(0x95ca9ec7); e == nil || e.RenderMode != RenderModeTile {
+		t.Errorf("got %+v", e)
+	}
+}
+
+func TestRomDbApply(t *testing.T) {
+	rom := testRom(0, testProg)
+	prgCrc := crc32.ChecksumIEEE(rom[16 : 16+0x8000])
+	tests := []struct {
+		name string
+		set  func(db *RomDb, e *RomDbEntry)
+	}{
+		{"rom", func(db *RomDb, e *RomDbEntry) { db.Set(crc32.ChecksumIEEE(rom[16:]), e) }},
+		{"prg", func(db *RomDb, e *RomDbEntry) { db.SetPrg(prgCrc, e) }},
+	}
+	for _, tt := range tests {
+		db := &RomDb{entries: map[uint32]*RomDbEntry{}, prgEntries: map[uint32]*RomDbEntry{}}
+		tt.set(db, &RomDbEntry{Flags: RomDbMapper | RomDbMirror | RomDbTvFormat,
+			MapperNo: 2, Mirror: RomMirrorV, TvFormat: TvFormatPal})
+		sys, err := NewSys(bytes.NewReader(rom), &Conf{TvFormat: TvFormatAuto, RomDb: db})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := sys.GetRomInfo()
+		if info.MapperNo != 2 || !info.VMirror || sys.rom.mapperNo != 2 {
+			t.Errorf("%s: got mapper %d, vertical mirroring %v", tt.name, info.MapperNo, info.VMirror)
+		}
+		if sys.conf.TvFormat != TvFormatPal {
+			t.Errorf("%s: got tv format %d", tt.name, sys.conf.TvFormat)
+		}
+	}
+}
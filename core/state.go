@@ -0,0 +1,214 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	stateMagic   uint32 = 0x54534346 // "FCST"
+	stateVersion uint32 = 5
+)
+
+type stateHeader struct {
+	Magic       uint32
+	Version     uint32
+	MapperNo    uint16
+	NProm8kPage uint32
+	NVrom1kPage uint32
+}
+
+// stateBuf serializes in both directions: every method either writes the
+// pointed value or reads into it, so a component describes its state once.
+type stateBuf struct {
+	mem  *Mem
+	load bool
+	r    io.Reader
+	w    io.Writer
+	err  error
+	buf  [8]byte
+}
+
+func (s *stateBuf) next(n int) []byte {
+	b := s.buf[:n]
+	if s.err != nil {
+		return b
+	}
+	if s.load {
+		_, s.err = io.ReadFull(s.r, b)
+	}
+	return b
+}
+
+func (s *stateBuf) flush(b []byte) {
+	if s.err == nil && !s.load {
+		_, s.err = s.w.Write(b)
+	}
+}
+
+func (s *stateBuf) u8(p *byte) {
+	b := s.next(1)
+	if s.load {
+		*p = b[0]
+	} else {
+		b[0] = *p
+		s.flush(b)
+	}
+}
+
+func (s *stateBuf) bool(p *bool) {
+	var b byte
+	if *p {
+		b = 1
+	}
+	s.u8(&b)
+	*p = b != 0
+}
+
+func (s *stateBuf) u16(p *uint16) {
+	b := s.next(2)
+	if s.load {
+		*p = binary.LittleEndian.Uint16(b)
+	} else {
+		binary.LittleEndian.PutUint16(b, *p)
+		s.flush(b)
+	}
+}
+
+func (s *stateBuf) i16(p *int16) {
+	w := uint16(*p)
+	s.u16(&w)
+	*p = int16(w)
+}
+
+func (s *stateBuf) u32(p *uint32) {
+	b := s.next(4)
+	if s.load {
+		*p = binary.LittleEndian.Uint32(b)
+	} else {
+		binary.LittleEndian.PutUint32(b, *p)
+		s.flush(b)
+	}
+}
+
+func (s *stateBuf) i32(p *int32) {
+	w := uint32(*p)
+	s.u32(&w)
+	*p = int32(w)
+}
+
+func (s *stateBuf) u64(p *uint64) {
+	b := s.next(8)
+	if s.load {
+		*p = binary.LittleEndian.Uint64(b)
+	} else {
+		binary.LittleEndian.PutUint64(b, *p)
+		s.flush(b)
+	}
+}
+
+func (s *stateBuf) i64(p *int64) {
+	w := uint64(*p)
+	s.u64(&w)
+	*p = int64(w)
+}
+
+func (s *stateBuf) f64(p *float64) {
+	w := math.Float64bits(*p)
+	s.u64(&w)
+	*p = math.Float64frombits(w)
+}
+
+func (s *stateBuf) bytes(p []byte) {
+	if s.err != nil {
+		return
+	}
+	if s.load {
+		_, s.err = io.ReadFull(s.r, p)
+	} else {
+		_, s.err = s.w.Write(p)
+	}
+}
+
+func (s *stateBuf) u32s(p []uint32) {
+	for i := range p {
+		s.u32(&p[i])
+	}
+}
+
+// bank stores a bank slice as the memory region it points into plus the
+// offset inside that region, since raw slices cannot be persisted.
+func (s *stateBuf) bank(p *[]byte, size uint32) {
+	var region byte
+	var ofs uint32
+	if !s.load && s.err == nil {
+		region, ofs, s.err = s.mem.bankRef(*p, size)
+	}
+	s.u8(&region)
+	s.u32(&ofs)
+	if s.load && s.err == nil {
+		if region == memRegionNum {
+			*p = nil
+		} else if sl := s.mem.bankSlice(region, ofs, size); sl != nil {
+			*p = sl
+		} else {
+			s.err = errors.New("invalid bank in state")
+		}
+	}
+}
+
+func (sys *Sys) serialize(s *stateBuf) {
+	s.u8(&sys.renderMode)
+	s.u16(&sys.scanline)
+	s.i64(&sys.nCycle)
+	s.i64(&sys.nCycleReq)
+	sys.mem.serialize(s)
+	sys.cpu.serialize(s)
+	sys.ppu.serialize(s)
+	sys.apu.serialize(s)
+	sys.pad.serialize(s)
+	sys.mapper.serialize(s)
+}
+
+func (sys *Sys) stateHeader() *stateHeader {
+	return &stateHeader{
+		Magic:       stateMagic,
+		Version:     stateVersion,
+		MapperNo:    sys.rom.mapperNo,
+		NProm8kPage: sys.mem.nProm8kPage,
+		NVrom1kPage: sys.mem.nVrom1kPage,
+	}
+}
+
+func (sys *Sys) SaveState(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, sys.stateHeader()); err != nil {
+		return err
+	}
+	s := &stateBuf{mem: sys.mem, w: w}
+	sys.serialize(s)
+	return s.err
+}
+
+// LoadState restores a state written by SaveState for the same rom. If an
+// error is returned after the header was accepted, the system is left
+// partially restored and should be Reset.
+func (sys *Sys) LoadState(r io.Reader) error {
+	header := &stateHeader{}
+	if err := binary.Read(r, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if header.Magic != stateMagic {
+		return errors.New("unsupported state file")
+	}
+	if header.Version != stateVersion {
+		return errors.New("unsupported state version")
+	}
+	if expect := sys.stateHeader(); *header != *expect {
+		return errors.New("state does not match rom")
+	}
+	s := &stateBuf{mem: sys.mem, load: true, r: r}
+	sys.serialize(s)
+	return s.err
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testProg loops writing the ppu and the apu, and counts the frames in its
+// nmi handler.
+var testProg = []byte{
+	0x78, 0xd8, 0xa2, 0xff, 0x9a, // sei; cld; ldx #$ff; txs
+	0xa9, 0x1e, 0x8d, 0x01, 0x20, // lda #$1e; sta $2001
+	0xa9, 0x80, 0x8d, 0x00, 0x20, // lda #$80; sta $2000
+	0xa9, 0x0f, 0x8d, 0x15, 0x40, // lda #$0f; sta $4015
+	0xe6, 0x00, 0xa5, 0x00, // $8014: inc $00; lda $00
+	0x8d, 0x07, 0x20, 0x8d, 0x00, 0x40, // sta $2007; sta $4000
+	0x4c, 0x14, 0x80, // jmp $8014
+	0xe6, 0x01, 0x40, // $8021: inc $01; rti
+}
+
+// testRom returns an ines image of 32 KB prg rom with prog at $8000, and 8 KB
+// chr rom.
+func testRom(mapperNo byte, prog []byte) []byte {
+	rom := make([]byte, 16+0x8000+0x2000)
+	copy(rom, "NES\x1a")
+	rom[4], rom[5], rom[6], rom[7] = 2, 1, mapperNo<<4, mapperNo&0xf0
+	prg := rom[16 : 16+0x8000]
+	copy(prg, prog)
+	copy(prg[0x7ffa:], []byte{0x21, 0x80, 0x00, 0x80, 0x21, 0x80})
+	for i := range rom[16+0x8000:] {
+		rom[16+0x8000+i] = byte(i * 7)
+	}
+	return rom
+}
+
+func newTestRomSys(t *testing.T, rom []byte) *Sys {
+	sys, err := NewSys(bytes.NewReader(rom), &Conf{AudioSampRate: 44100, NoRomDb: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys.SetFrameBuffer(&FrameBuffer{})
+	return sys
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	tests := []struct {
+		mapperNo   byte
+		renderMode byte
+	}{
+		{0, RenderModePre},
+		{2, RenderModePre},
+		{3, RenderModePre},
+		{0, RenderModeDot},
+	}
+	for _, tt := range tests {
+		rom := testRom(tt.mapperNo, testProg)
+		newSys := func() *Sys {
+			sys, err := NewSys(bytes.NewReader(rom),
+				&Conf{AudioSampRate: 44100, RenderMode: tt.renderMode, NoRomDb: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sys.SetFrameBuffer(&FrameBuffer{})
+			return sys
+		}
+		sys0 := newSys()
+		for i := 0; i < 5; i++ {
+			sys0.RunFrame()
+		}
+		var state bytes.Buffer
+		if err := sys0.SaveState(&state); err != nil {
+			t.Fatalf("%+v: %v", tt, err)
+		}
+		sys1 := newSys()
+		if err := sys1.LoadState(bytes.NewReader(state.Bytes())); err != nil {
+			t.Fatalf("%+v: %v", tt, err)
+		}
+		for i := 0; i < 5; i++ {
+			sys0.RunFrame()
+			sys1.RunFrame()
+		}
+		if r0, r1 := sys0.GetCpuRegs(), sys1.GetCpuRegs(); r0 != r1 {
+			t.Errorf("%+v: regs %+v, want %+v", tt, r1, r0)
+		}
+		if sys0.mem.ram != sys1.mem.ram || sys0.mem.vram != sys1.mem.vram {
+			t.Errorf("%+v: memory differs", tt)
+		}
+		if *sys0.ppu.screen != *sys1.ppu.screen {
+			t.Errorf("%+v: frame differs", tt)
+		}
+	}
+}
+
+// The dot renderer's state is saved whole, for the renderer may be in
+// the middle of a line when saved from a debugger break.
+func TestStatePpuDot(t *testing.T) {
+	rom := testRom(0, testProg)
+	sys0, sys1 := newTestRomSys(t, rom), newTestRomSys(t, rom)
+	sys0.RunFrame()
+	d := &sys0.ppu.dot
+	d.line, d.x, d.spN = 100, 300, 3
+	for i := range d.spIdx {
+		d.spIdx[i], d.spPatL[i], d.spPatH[i], d.spLine[i] = byte(i), byte(i*3), byte(i*5), byte(i*7)
+	}
+	var state bytes.Buffer
+	if err := sys0.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if err := sys1.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if sys1.ppu.dot != *d {
+		t.Errorf("got %+v, want %+v", sys1.ppu.dot, *d)
+	}
+}
+
+func TestStateErrors(t *testing.T) {
+	sys := newTestRomSys(t, testRom(0, testProg))
+	sys.RunFrame()
+	var state bytes.Buffer
+	if err := sys.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	b := state.Bytes()
+
+	other := newTestRomSys(t, testRom(2, testProg))
+	if err := other.LoadState(bytes.NewReader(b)); err == nil {
+		t.Error("loaded the state of another mapper")
+	}
+	if err := sys.LoadState(bytes.NewReader(b[:len(b)/2])); err == nil {
+		t.Error("loaded a truncated state")
+	}
+
+	// a bank out of the memory regions is not to be saved as none
+	sys.mem.cpuBanks[3] = make([]byte, 0x2000)
+	if err := sys.SaveState(&bytes.Buffer{}); err == nil {
+		t.Error("saved a bank out of the memory regions")
+	}
+}
@@ -8,6 +8,15 @@ const (
 	RenderModePreAll
 	RenderModePostAll
 	RenderModeTile
+	RenderModeDot       // the ppu run dot by dot along with the cpu
+	RenderModeAuto byte = 0xff
+)
+
+const (
+	TvFormatNtsc byte = iota
+	TvFormatPal
+	TvFormatPalChina
+	TvFormatAuto byte = 0xff // from the rom database, else the rom header
 )
 
 type Conf struct {
@@ -16,8 +25,23 @@ type Conf struct {
 	TvFormat      byte
 	RenderMode    byte
 	AudioSampRate uint16
+	RomDb         *RomDb
+	NoRomDb       bool
+	FdsBios       []byte
+
+	// the queue fill, in samples, the dynamic rate control keeps the audio
+	// at; 0 disables it
+	AudioTargetFill uint16
+	// interleaved left and right samples instead of mono, see SetAudioChanPan
+	AudioStereo bool
 }
 
+// tvFormat holds the timings of a region. framePeriod is the exact length of
+// the emulated frame, so that a host running frames at that period consumes
+// the audio as fast as it is produced. The dendy apu keeps the ntsc timings.
+// A pal dot is 3.75 sys cycles, 3.2 a cpu cycle, so a line of 341 dots is
+// 1278.75 cycles: nScanlineCycle is 1279, and every fourth line a cycle
+// shorter.
 type tvFormat struct {
 	cpuRate           float32
 	nScanline         uint16
@@ -25,13 +49,26 @@ type tvFormat struct {
 	nHDrawCycle       int64
 	nHBlankCycle      int64
 	nScanlineEndCycle int64
+	nScanlineShort    int64 // quarter cycles a line is short of nScanlineCycle
+	dotCycle4         int64 // sys cycles of 4 dots
 	framePeriod       float32
+	apuFrameCycle     int32 // a quarter frame of the apu frame counter, in half cpu cycles
+	apuNoiseFreq      *[16]int32
+	apuDpcmCycles     *[16]uint16
+}
+
+var tvFormats = [...]tvFormat{
+	TvFormatNtsc: {1789772.5, 262, 1364, 1024, 340, 4, 0, 16, 1000.0 * 262 * 1364 / 12 / 1789772.5,
+		14915, &apuNoiseFreq, &apuDpcmCycles},
+	TvFormatPal: {1662607.0, 312, 1279, 960, 319, 4, 1, 15, 1000.0 * 312 * 1278.75 / 12 / 1662607.0,
+		16626, &apuNoiseFreqPal, &apuDpcmCyclesPal},
+	TvFormatPalChina: {1773447.0, 313, 1362, 1024, 338, 2, 0, 16, 1000.0 * 313 * 1362 / 12 / 1773447.0,
+		14915, &apuNoiseFreq, &apuDpcmCycles},
 }
 
-var tvFormats = [3]tvFormat{
-	{1789772.5, 262, 1364, 1024, 340, 4, 1000.0 / 60.0}, // ntsc
-	{1773447.0, 312, 1362, 1024, 338, 4, 1000.0 / 50.0}, // pal
-	{1773447.0, 313, 1362, 1024, 338, 2, 1000.0 / 50.0}, // pal china
+// dotCycles returns the sys cycles of n dots, n a multiple of 4.
+func (tf *tvFormat) dotCycles(n int64) int64 {
+	return n * tf.dotCycle4 >> 2
 }
 
 type Sys struct {
@@ -47,10 +84,14 @@ type Sys struct {
 	renderMode byte
 	conf       Conf
 
-	scanline  uint16
-	nCycle    int64
-	nCycleReq int64
-	//logger    *os.File //ldeng7
+	scanline    uint16
+	nCycle      int64
+	nCycleReq   int64
+	saveRamSnap []byte
+	dbg         *Debugger // nil if none attached
+	trace       *tracer
+	watch       *watcher // nil if no watch
+	frameCycle  int64    // the start of the frame in cpu cycles x 12, for the trace
 }
 
 func NewSys(file io.Reader, conf *Conf) (*Sys, error) {
@@ -58,11 +99,33 @@ func NewSys(file io.Reader, conf *Conf) (*Sys, error) {
 	sys := &Sys{}
 
 	sys.conf = *conf
-	sys.renderMode = conf.RenderMode
-	sys.tvFormat = tvFormats[conf.TvFormat]
 	if sys.rom, err = newRom(file); err != nil {
 		return nil, err
 	}
+	if sys.rom.fds != nil {
+		if err = sys.rom.loadFdsBios(conf.FdsBios); err != nil {
+			return nil, err
+		}
+	}
+	if int(sys.conf.TvFormat) >= len(tvFormats) {
+		sys.conf.TvFormat = TvFormatAuto
+	}
+	sys.applyRomDb()
+	if sys.conf.RenderMode > RenderModeDot {
+		sys.conf.RenderMode = RenderModePre
+	}
+	sys.renderMode = sys.conf.RenderMode
+	if sys.conf.TvFormat == TvFormatAuto {
+		switch sys.rom.info.Timing {
+		case RomTimingPal:
+			sys.conf.TvFormat = TvFormatPal
+		case RomTimingDendy:
+			sys.conf.TvFormat = TvFormatPalChina
+		default:
+			sys.conf.TvFormat = TvFormatNtsc
+		}
+	}
+	sys.tvFormat = tvFormats[sys.conf.TvFormat]
 	sys.mem = newMem(sys)
 	if sys.mapper, err = newMapper(sys); err != nil {
 		return nil, err
@@ -73,7 +136,7 @@ func NewSys(file io.Reader, conf *Conf) (*Sys, error) {
 	sys.pad = newPad()
 
 	sys.reset(true)
-	//sys.logger, _ = os.OpenFile("loggo.txt", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	sys.saveRamSnap = sys.batteryRam()
 	return sys, nil
 }
 
@@ -81,7 +144,21 @@ func (sys *Sys) Reset() {
 	sys.reset(false)
 }
 
+func (sys *Sys) GetRomInfo() RomInfo {
+	return sys.rom.info
+}
+
+// GetFramePeriod returns the mean period of frames in ms. In dot mode it is
+// that of the ppu's frames, a bit shorter on ntsc with the odd frames' skip.
 func (sys *Sys) GetFramePeriod() float32 {
+	if sys.renderMode == RenderModeDot {
+		tf := &sys.tvFormat
+		nDot := float64(tf.nScanline) * ppuDotNum
+		if sys.conf.TvFormat == TvFormatNtsc {
+			nDot -= 0.5
+		}
+		return float32(1000 * nDot * float64(tf.dotCycle4) / 4 / 12 / float64(tf.cpuRate))
+	}
 	return sys.tvFormat.framePeriod
 }
 
@@ -100,6 +177,10 @@ func (sys *Sys) GetAudioDataQueue() *ApuDataQueue {
 func (sys *Sys) reset(init bool) {
 	sys.mem.reset(init)
 	sys.mapper.reset()
+	if sys.conf.RenderMode == RenderModeDot {
+		// the mappers' render mode hacks are for the scanline renderer
+		sys.renderMode = RenderModeDot
+	}
 	sys.cpu.reset()
 	sys.ppu.reset(init)
 	sys.apu.reset(init)
@@ -107,6 +188,9 @@ func (sys *Sys) reset(init bool) {
 }
 
 func (sys *Sys) read(addr uint16) byte {
+	if sys.watch != nil && !sys.watch.inBus {
+		return sys.watch.read(sys, addr)
+	}
 	switch addr >> 13 {
 	case 0x00:
 		return sys.mem.ram[addr&0x07ff]
@@ -137,6 +221,10 @@ func (sys *Sys) read(addr uint16) byte {
 }
 
 func (sys *Sys) write(addr uint16, b byte) {
+	if sys.watch != nil && !sys.watch.inBus {
+		sys.watch.write(sys, addr, b)
+		return
+	}
 	switch addr >> 13 {
 	case 0x00:
 		sys.mem.ram[addr&0x07ff] = b
@@ -185,43 +273,62 @@ func (sys *Sys) runCpu(nCycleReq int64) {
 	}
 }
 
+// lineEnd returns n, the cycles to the end of the line, less the quarter
+// cycles the line is short of nScanlineCycle, made whole cycles by the line
+// number.
+func (sys *Sys) lineEnd(n int64) int64 {
+	q, l := sys.tvFormat.nScanlineShort, int64(sys.scanline)
+	return n - ((l+1)*q>>2 - l*q>>2)
+}
+
 func (sys *Sys) RunFrame() {
+	if sys.trace != nil {
+		sys.trace.frameStart()
+	}
+	if sys.renderMode == RenderModeDot {
+		sys.runFrameDot()
+		return
+	}
 	ppu := sys.ppu
+	tf := &sys.tvFormat
 	bAllSprite := sys.conf.AllSprite
-	nScanline := sys.tvFormat.nScanline - 1
+	nScanline := tf.nScanline - 1
+	// sys.nCycle runs along with the cpu cycles, as cpu cycles x 12, by an
+	// offset the reset leaves
+	sys.frameCycle = sys.cpu.nCycle*12 + sys.nCycleReq - sys.nCycle
 
 	sys.scanline, ppu.iScanline = 0, 0
 	switch sys.renderMode {
 	case RenderModePostAll, RenderModePreAll:
-		sys.runCpu(sys.tvFormat.nScanlineCycle)
+		sys.runCpu(sys.lineEnd(tf.nScanlineCycle))
 		ppu.frameStart()
 		ppu.scanlineNext()
 		sys.mapper.hSync(sys.scanline)
 		ppu.scanlineStart()
 	case RenderModePost, RenderModePre:
-		sys.runCpu(sys.tvFormat.nHDrawCycle)
+		sys.runCpu(tf.nHDrawCycle)
 		ppu.frameStart()
 		ppu.scanlineNext()
 		sys.mapper.hSync(sys.scanline)
-		sys.runCpu(256)
+		sys.runCpu(tf.dotCycles(64))
 		ppu.scanlineStart()
-		sys.runCpu(80 + sys.tvFormat.nScanlineEndCycle)
+		sys.runCpu(sys.lineEnd(tf.dotCycles(20) + tf.nScanlineEndCycle))
 	case RenderModeTile:
-		sys.runCpu(1024)
+		sys.runCpu(tf.nHDrawCycle)
 		ppu.frameStart()
 		ppu.scanlineNext()
-		sys.runCpu(80)
+		sys.runCpu(tf.dotCycles(20))
 		sys.mapper.hSync(sys.scanline)
-		sys.runCpu(176)
+		sys.runCpu(tf.dotCycles(44))
 		ppu.scanlineStart()
-		sys.runCpu(80 + sys.tvFormat.nScanlineEndCycle)
+		sys.runCpu(sys.lineEnd(tf.dotCycles(20) + tf.nScanlineEndCycle))
 	}
 
 	for sys.scanline = 1; sys.scanline < 240; sys.scanline++ {
 		ppu.iScanline += ScreenWidth
 		switch sys.renderMode {
 		case RenderModePostAll:
-			sys.runCpu(sys.tvFormat.nScanlineCycle)
+			sys.runCpu(sys.lineEnd(tf.nScanlineCycle))
 			ppu.scanlineRender(byte(sys.scanline), bAllSprite)
 			ppu.scanlineNext()
 			sys.mapper.hSync(sys.scanline)
@@ -229,33 +336,33 @@ func (sys *Sys) RunFrame() {
 		case RenderModePreAll:
 			ppu.scanlineRender(byte(sys.scanline), bAllSprite)
 			ppu.scanlineNext()
-			sys.runCpu(sys.tvFormat.nScanlineCycle)
+			sys.runCpu(sys.lineEnd(tf.nScanlineCycle))
 			sys.mapper.hSync(sys.scanline)
 			ppu.scanlineStart()
 		case RenderModePost:
-			sys.runCpu(sys.tvFormat.nHDrawCycle)
+			sys.runCpu(tf.nHDrawCycle)
 			ppu.scanlineRender(byte(sys.scanline), bAllSprite)
 			ppu.scanlineNext()
 			sys.mapper.hSync(sys.scanline)
-			sys.runCpu(256)
+			sys.runCpu(tf.dotCycles(64))
 			ppu.scanlineStart()
-			sys.runCpu(80 + sys.tvFormat.nScanlineEndCycle)
+			sys.runCpu(sys.lineEnd(tf.dotCycles(20) + tf.nScanlineEndCycle))
 		case RenderModePre:
 			ppu.scanlineRender(byte(sys.scanline), bAllSprite)
-			sys.runCpu(sys.tvFormat.nHDrawCycle)
+			sys.runCpu(tf.nHDrawCycle)
 			ppu.scanlineNext()
 			sys.mapper.hSync(sys.scanline)
-			sys.runCpu(256)
+			sys.runCpu(tf.dotCycles(64))
 			ppu.scanlineStart()
-			sys.runCpu(80 + sys.tvFormat.nScanlineEndCycle)
+			sys.runCpu(sys.lineEnd(tf.dotCycles(20) + tf.nScanlineEndCycle))
 		case RenderModeTile:
 			ppu.scanlineRender(byte(sys.scanline), bAllSprite)
 			ppu.scanlineNext()
-			sys.runCpu(80)
+			sys.runCpu(tf.dotCycles(20))
 			sys.mapper.hSync(sys.scanline)
-			sys.runCpu(176)
+			sys.runCpu(tf.dotCycles(44))
 			ppu.scanlineStart()
-			sys.runCpu(80 + sys.tvFormat.nScanlineEndCycle)
+			sys.runCpu(sys.lineEnd(tf.dotCycles(20) + tf.nScanlineEndCycle))
 		}
 	}
 
@@ -273,12 +380,12 @@ func (sys *Sys) RunFrame() {
 			ppu.reg2 &^= ppuReg2VBlank | ppuReg2SpHit
 		}
 		if sys.renderMode == RenderModePreAll || sys.renderMode == RenderModePostAll {
-			sys.runCpu(sys.tvFormat.nScanlineCycle)
+			sys.runCpu(sys.lineEnd(tf.nScanlineCycle))
 			sys.mapper.hSync(sys.scanline)
 		} else {
-			sys.runCpu(sys.tvFormat.nHDrawCycle)
+			sys.runCpu(tf.nHDrawCycle)
 			sys.mapper.hSync(sys.scanline)
-			sys.runCpu(sys.tvFormat.nHBlankCycle)
+			sys.runCpu(sys.lineEnd(tf.nHBlankCycle))
 		}
 	}
 
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSysFrameCycles(t *testing.T) {
+	tests := []struct {
+		tvFormat  byte
+		nCycle    int64 // of a frame, in cpu cycles
+		nDotCycle int64 // of a frame of the dot renderer
+	}{
+		{TvFormatNtsc, 262 * 1364 / 12, (262*1364 - 2) / 12}, // a dot skipped every other frame
+		{TvFormatPal, 312 * 5115 / 48, 312 * 5115 / 48},      // lines of 1278.75 cycles
+		{TvFormatPalChina, 313 * 1362 / 12, 313 * 1364 / 12},
+	}
+	for _, tt := range tests {
+		for _, mode := range []byte{RenderModePost, RenderModePostAll, RenderModePre, RenderModePreAll, RenderModeTile, RenderModeDot} {
+			sys, err := NewSys(bytes.NewReader(testRom(0, testProg)),
+				&Conf{TvFormat: tt.tvFormat, RenderMode: mode, NoRomDb: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sys.SetFrameBuffer(&FrameBuffer{})
+			sys.RunFrame()
+			n := sys.cpu.nCycle
+			for i := 0; i < 10; i++ {
+				sys.RunFrame()
+			}
+			// within an instruction over the 10 frames
+			want := tt.nCycle
+			if mode == RenderModeDot {
+				want = tt.nDotCycle
+			}
+			if d := (sys.cpu.nCycle-n)/10 - want; d < -1 || d > 1 {
+				t.Errorf("tv format %d, render mode %d: %d cycles a frame, want %d",
+					tt.tvFormat, mode, (sys.cpu.nCycle-n)/10, want)
+			}
+			if d := sys.GetFramePeriod() - float32(want)*1000/sys.tvFormat.cpuRate; d < -0.001 || d > 0.001 {
+				t.Errorf("tv format %d, render mode %d: frame period %f ms, off by %f ms",
+					tt.tvFormat, mode, sys.GetFramePeriod(), d)
+			}
+		}
+	}
+}
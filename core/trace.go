@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ldeng7/go-fc/core/disasm"
+)
+
+// TraceConf filters the instructions traced: those with the pc in [Addr,
+// End], any if both are 0, run in the frames from FrameFrom up to before
+// FrameTo, with no end if 0. Frames are counted from 0, the one the trace
+// starts in, or the next one if started between two.
+type TraceConf struct {
+	Addr      uint16
+	End       uint16
+	FrameFrom int
+	FrameTo   int
+}
+
+type tracer struct {
+	conf TraceConf
+	w    *bufio.Writer
+	err  error
+
+	frame int
+	bRun  bool // whether the cpu ran in the frame
+}
+
+// StartTrace writes, before each instruction run, a line in the format of
+// the nestest log of nintendulator:
+//
+//	C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7
+//
+// with the scanline counted from the first visible one, the cycle from the
+// reset, and the dot, outside of the dot render mode, worked out from the
+// cycle.
+func (sys *Sys) StartTrace(w io.Writer, conf *TraceConf) error {
+	if sys.trace != nil {
+		return errors.New("already tracing")
+	}
+	t := &tracer{w: bufio.NewWriter(w)}
+	if conf != nil {
+		t.conf = *conf
+	}
+	if t.conf.End < t.conf.Addr {
+		t.conf.End = t.conf.Addr
+	}
+	sys.trace = t
+	return nil
+}
+
+// StopTrace returns the first error met writing.
+func (sys *Sys) StopTrace() error {
+	t := sys.trace
+	if t == nil {
+		return errors.New("not tracing")
+	}
+	sys.trace = nil
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
+
+func (sys *Sys) IsTracing() bool {
+	return sys.trace != nil
+}
+
+func (t *tracer) frameStart() {
+	if t.bRun {
+		t.frame, t.bRun = t.frame+1, false
+	}
+}
+
+func (t *tracer) ppuPos(sys *Sys) (int, int) {
+	if sys.renderMode == RenderModeDot {
+		d := &sys.ppu.dot
+		return int(d.line), int(d.x)
+	}
+	n := sys.cpu.nCycle*12 - sys.frameCycle
+	if n < 0 {
+		n = 0
+	}
+	return int(n / sys.tvFormat.nScanlineCycle), int(n % sys.tvFormat.nScanlineCycle * ppuDotNum / sys.tvFormat.nScanlineCycle)
+}
+
+// trace is run by the cpu before each instruction.
+func (t *tracer) trace(sys *Sys) {
+	cpu := sys.cpu
+	c := &t.conf
+	t.bRun = true
+	if t.err != nil || t.frame < c.FrameFrom || (c.FrameTo != 0 && t.frame >= c.FrameTo) ||
+		((c.Addr != 0 || c.End != 0) && (cpu.regPC < c.Addr || cpu.regPC > c.End)) {
+		return
+	}
+	in := disasm.Decode(sys, cpu.regPC)
+	var bs [3]string
+	for i := range bs {
+		if i < int(in.Len) {
+			bs[i] = fmt.Sprintf("%02X", in.Bytes[i])
+		}
+	}
+	mark := ' '
+	if !in.Op.Official {
+		mark = '*'
+	}
+	line, dot := t.ppuPos(sys)
+	_, t.err = fmt.Fprintf(t.w, "%04X  %-2s %-2s %-2s %c%-31s A:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:%3d,%3d CYC:%d\n",
+		cpu.regPC, bs[0], bs[1], bs[2], mark, in.Text(sys, nil)+t.operand(sys, &in),
+		cpu.regA, cpu.regX, cpu.regY, cpu.regP, cpu.regS, line, dot, cpu.nCycle)
+}
+
+// operand notes the memory the instruction is to access, as nestest does.
+func (t *tracer) operand(sys *Sys, in *disasm.Inst) string {
+	cpu := sys.cpu
+	a := in.Operand
+	zpWord := func(p byte) uint16 { return uint16(cpu.ram[p]) | uint16(cpu.ram[p+1])<<8 }
+	switch in.Op.Mode {
+	case disasm.ModeZp:
+		return fmt.Sprintf(" = %02X", sys.PeekCpu(a))
+	case disasm.ModeAbs:
+		if in.Op.Access == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" = %02X", sys.PeekCpu(a))
+	case disasm.ModeZpX, disasm.ModeZpY:
+		r := cpu.regX
+		if in.Op.Mode == disasm.ModeZpY {
+			r = cpu.regY
+		}
+		p := byte(a) + r
+		return fmt.Sprintf(" @ %02X = %02X", p, sys.PeekCpu(uint16(p)))
+	case disasm.ModeAbsX, disasm.ModeAbsY:
+		r := cpu.regX
+		if in.Op.Mode == disasm.ModeAbsY {
+			r = cpu.regY
+		}
+		ea := a + uint16(r)
+		return fmt.Sprintf(" @ %04X = %02X", ea, sys.PeekCpu(ea))
+	case disasm.ModeInd:
+		// the high byte is read from the same page
+		hi := (a & 0xff00) | ((a + 1) & 0x00ff)
+		return fmt.Sprintf(" = %04X", uint16(sys.PeekCpu(a))|uint16(sys.PeekCpu(hi))<<8)
+	case disasm.ModeIndX:
+		p := byte(a) + cpu.regX
+		ea := zpWord(p)
+		return fmt.Sprintf(" @ %02X = %04X = %02X", p, ea, sys.PeekCpu(ea))
+	case disasm.ModeIndY:
+		base := zpWord(byte(a))
+		ea := base + uint16(cpu.regY)
+		return fmt.Sprintf(" = %04X @ %04X = %02X", base, ea, sys.PeekCpu(ea))
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package core
+
+import "github.com/ldeng7/go-fc/core/disasm"
+
+// the accesses a watch is on
+const (
+	WatchCpuRead byte = 0x01 << iota
+	WatchCpuWrite
+	WatchPpuWrite // to the vram and the palettes, through $2007
+	WatchOamWrite // through $2004 and the dma of $4014, the address that of the oam
+)
+
+// Watch calls Fn on the accesses of the types Typ to an address in [Addr,
+// End]. On the cpu bus, those of the instructions to the zero page are
+// reported after the instruction, those to the stack and of the pointers of
+// the indirect modes are not.
+type Watch struct {
+	Typ  byte // of the Watch flags
+	Addr uint16
+	End  uint16 // Addr if less than it
+	Fn   func(ev *WatchEvent)
+}
+
+// WatchEvent is an access, as passed to a watch, not to be kept after it
+// returns.
+type WatchEvent struct {
+	Typ   byte
+	Addr  uint16
+	Value byte
+	PC    uint16 // of the instruction making the access
+	Cycle int64  // the cpu cycle the instruction started at
+}
+
+type watchEntry struct {
+	Watch
+	id int
+}
+
+// watcher is attached to Sys while there is any watch, and run before and
+// after each instruction.
+type watcher struct {
+	watches []watchEntry
+	typ     byte // the types of all the watches
+	nextId  int
+	ev      WatchEvent
+	inBus   bool
+
+	pc    uint16
+	cycle int64
+	// the zero page access of the instruction run, and the value before
+	zpAccess byte
+	zpAddr   byte
+	zpValue  byte
+}
+
+// AddWatch returns the id of the new watch.
+func (sys *Sys) AddWatch(w Watch) int {
+	if w.End < w.Addr {
+		w.End = w.Addr
+	}
+	if sys.watch == nil {
+		sys.watch = &watcher{nextId: 1}
+		sys.watch.pc, sys.watch.cycle = sys.cpu.regPC, sys.cpu.nCycle
+	}
+	wt := sys.watch
+	id := wt.nextId
+	wt.nextId++
+	wt.watches = append(wt.watches, watchEntry{w, id})
+	wt.typ |= w.Typ
+	return id
+}
+
+func (sys *Sys) RemoveWatch(id int) {
+	wt := sys.watch
+	if wt == nil {
+		return
+	}
+	wt.typ = 0
+	ws := wt.watches[:0]
+	for _, w := range wt.watches {
+		if w.id != id {
+			ws = append(ws, w)
+			wt.typ |= w.Typ
+		}
+	}
+	wt.watches = ws
+	if len(ws) == 0 {
+		sys.watch = nil
+	}
+}
+
+func (wt *watcher) report(typ byte, addr uint16, value byte) {
+	if wt.typ&typ == 0 {
+		return
+	}
+	for i := range wt.watches {
+		w := &wt.watches[i]
+		if w.Typ&typ != 0 && addr >= w.Addr && addr <= w.End {
+			wt.ev = WatchEvent{typ, addr, value, wt.pc, wt.cycle}
+			w.Fn(&wt.ev)
+		}
+	}
+}
+
+// read and write are Sys.read and Sys.write while watching, which they run
+// again with inBus set to get to the bus.
+func (wt *watcher) read(sys *Sys, addr uint16) byte {
+	wt.inBus = true
+	b := sys.read(addr)
+	wt.inBus = false
+	wt.report(WatchCpuRead, addr, b)
+	return b
+}
+
+func (wt *watcher) write(sys *Sys, addr uint16, b byte) {
+	wt.inBus = true
+	sys.write(addr, b)
+	wt.inBus = false
+	wt.report(WatchCpuWrite, addr, b)
+}
+
+// opStart is run before the instruction at pc.
+func (wt *watcher) opStart(cpu *Cpu) {
+	wt.pc, wt.cycle = cpu.regPC, cpu.nCycleOp
+	if wt.typ&(WatchCpuRead|WatchCpuWrite) == 0 {
+		return
+	}
+	// the cpu goes to the zero page without the bus
+	op := &disasm.Ops[cpu.sys.PeekCpu(cpu.regPC)]
+	if op.Access == 0 {
+		return
+	}
+	addr := cpu.sys.PeekCpu(cpu.regPC + 1)
+	switch op.Mode {
+	case disasm.ModeZp:
+	case disasm.ModeZpX:
+		addr += cpu.regX
+	case disasm.ModeZpY:
+		addr += cpu.regY
+	default:
+		return
+	}
+	wt.zpAccess, wt.zpAddr, wt.zpValue = op.Access, addr, cpu.ram[addr]
+}
+
+// opEnd is run after the instruction, before any interrupt.
+func (wt *watcher) opEnd(cpu *Cpu) {
+	if wt.zpAccess == 0 {
+		return
+	}
+	if wt.zpAccess&disasm.AccessRead != 0 {
+		wt.report(WatchCpuRead, uint16(wt.zpAddr), wt.zpValue)
+	}
+	if wt.zpAccess&disasm.AccessWrite != 0 {
+		wt.report(WatchCpuWrite, uint16(wt.zpAddr), cpu.ram[wt.zpAddr])
+	}
+	wt.zpAccess = 0
+}
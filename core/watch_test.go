@@ -0,0 +1,60 @@
+package core
+
+import "testing"
+
+func TestWatch(t *testing.T) {
+	// at $5000 in the xram of mapper 4
+	xprog := []byte{
+		0xe6, 0x10, // inc $10
+		0xa9, 0x03, // lda #$03
+		0x8d, 0x14, 0x40, // sta $4014
+		0x60, // rts
+	}
+	prog := []byte{
+		0xa2, 0xff, 0x9a, // ldx #$ff; txs
+		0xa9, 0x02, 0x85, 0x10, // lda #$02; sta $10
+		0x20, 0x00, 0x50, // jsr $5000
+		0x4c, 0x0a, 0x80, // jmp $800a
+	}
+	tests := []struct {
+		name string
+		w    Watch
+		evs  []WatchEvent
+	}{
+		{"zero page", Watch{Typ: WatchCpuRead | WatchCpuWrite, Addr: 0x10}, []WatchEvent{
+			{WatchCpuWrite, 0x10, 0x02, 0x8005, 0},
+			{WatchCpuRead, 0x10, 0x02, 0x5000, 0},
+			{WatchCpuWrite, 0x10, 0x03, 0x5000, 0},
+		}},
+		{"dma", Watch{Typ: WatchOamWrite, Addr: 0x00, End: 0x02}, []WatchEvent{
+			{WatchOamWrite, 0x00, 0x11, 0x5004, 0},
+			{WatchOamWrite, 0x01, 0x22, 0x5004, 0},
+			{WatchOamWrite, 0x02, 0x33, 0x5004, 0},
+		}},
+		{"dma source", Watch{Typ: WatchCpuRead, Addr: 0x0300}, []WatchEvent{
+			{WatchCpuRead, 0x0300, 0x11, 0x5004, 0},
+		}},
+	}
+	for _, tt := range tests {
+		sys := newTestRomSys(t, testRom(4, prog))
+		copy(sys.mem.xram[0x1000:], xprog)
+		copy(sys.mem.ram[0x0300:], []byte{0x11, 0x22, 0x33})
+		var evs []WatchEvent
+		tt.w.Fn = func(ev *WatchEvent) {
+			e := *ev
+			e.Cycle = 0
+			evs = append(evs, e)
+		}
+		sys.AddWatch(tt.w)
+		sys.cpu.run(60)
+		if len(evs) != len(tt.evs) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, evs, tt.evs)
+			continue
+		}
+		for i := range evs {
+			if evs[i] != tt.evs[i] {
+				t.Errorf("%s: event %d: got %+v, want %+v", tt.name, i, evs[i], tt.evs[i])
+			}
+		}
+	}
+}
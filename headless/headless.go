@@ -0,0 +1,134 @@
+// Package headless runs the emulation as fast as it goes, with no window nor
+// audio device, the input taken from a script or a movie, for tests and
+// batch jobs.
+package headless
+
+import (
+	"errors"
+	"hash/crc32"
+	"image"
+	"io"
+	"time"
+
+	"github.com/ldeng7/go-fc/core"
+)
+
+type Conf struct {
+	Sys    core.Conf // AudioSampRate defaults to 44100
+	NFrame int
+	Input  *Input // no key pressed if nil
+
+	// OnFrame is passed the picture of the frames listed in DumpFrames,
+	// counted from 0
+	DumpFrames []int
+	OnFrame    func(iFrame int, img *image.RGBA) error
+
+	// the wav of the whole run, if not nil
+	Audio io.Writer
+	// the instruction trace, if not nil, filtered by TraceConf if not nil
+	Trace     io.Writer
+	TraceConf *core.TraceConf
+}
+
+type Result struct {
+	NFrame    int
+	Elapsed   time.Duration
+	FrameHash uint32 // crc32 of the last frame as in Image
+}
+
+const defaultSampRate = 44100
+
+// Run loads a rom and runs it for conf.NFrame frames.
+func Run(rom io.Reader, conf *Conf) (*Result, error) {
+	if conf.NFrame <= 0 {
+		return nil, errors.New("invalid frame number")
+	}
+	sc := conf.Sys
+	if sc.AudioSampRate == 0 {
+		sc.AudioSampRate = defaultSampRate
+	}
+	// the rate is to follow the emulation, not a device
+	sc.AudioTargetFill = 0
+	sys, err := core.NewSys(rom, &sc)
+	if err != nil {
+		return nil, err
+	}
+	fb := &core.FrameBuffer{}
+	sys.SetFrameBuffer(fb)
+	if conf.Audio != nil {
+		if err = sys.StartAudioRecord(conf.Audio, nil, core.AudioRecordWav); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Trace != nil {
+		if err = sys.StartTrace(conf.Trace, conf.TraceConf); err != nil {
+			return nil, err
+		}
+	}
+	dumps := make(map[int]bool, len(conf.DumpFrames))
+	for _, i := range conf.DumpFrames {
+		dumps[i] = true
+	}
+
+	dq := sys.GetAudioDataQueue()
+	audioBuf := make([]float32, dq.Cap()*dq.NChan())
+	var pads [2]byte
+	t := time.Now()
+	for i := 0; i < conf.NFrame; i++ {
+		if conf.Input != nil {
+			f := conf.Input.At(i)
+			if f.Reset {
+				// the reset releases the keys
+				sys.Reset()
+				pads = [2]byte{}
+			}
+			for p := range pads {
+				if f.Pads[p] != pads[p] {
+					setPad(sys, byte(p+1), f.Pads[p])
+					pads[p] = f.Pads[p]
+				}
+			}
+		}
+		sys.RunFrame()
+		// nothing plays the queue, so keep it from filling
+		dq.Dequeue(audioBuf[:dq.Len()*dq.NChan()])
+		if dumps[i] && conf.OnFrame != nil {
+			if err = conf.OnFrame(i, Image(fb)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	res := &Result{NFrame: conf.NFrame, Elapsed: time.Since(t)}
+	res.FrameHash = crc32.ChecksumIEEE(Image(fb).Pix)
+	if conf.Audio != nil {
+		if err = sys.StopAudioRecord(); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Trace != nil {
+		if err = sys.StopTrace(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func setPad(sys *core.Sys, p byte, keys byte) {
+	for k := byte(1); k != 0; k <<= 1 {
+		sys.SetPadKey(p, k, keys&k != 0)
+	}
+}
+
+// Image returns the picture of a frame, without the borders.
+func Image(fb *core.FrameBuffer) *image.RGBA {
+	const w = core.ScreenWidth - 16
+	img := image.NewRGBA(image.Rect(0, 0, w, core.ScreenHeight))
+	for y := 0; y < core.ScreenHeight; y++ {
+		line, pix := fb[y*core.ScreenWidth+8:][:w], img.Pix[y*img.Stride:]
+		for x, c := range line {
+			// colors are kept as little endian rgba
+			pix[x<<2], pix[x<<2+1], pix[x<<2+2], pix[x<<2+3] = byte(c), byte(c>>8), byte(c>>16), byte(c>>24)
+		}
+	}
+	return img
+}
@@ -0,0 +1,149 @@
+package headless
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ldeng7/go-fc/core"
+)
+
+// Frame is the input of one frame: the keys held on each pad, and whether
+// the machine is reset before it.
+type Frame struct {
+	Pads  [2]byte
+	Reset bool
+}
+
+// Input lists the input frame by frame; past the end, the pads stay as in
+// the last frame.
+type Input struct {
+	frames []Frame
+}
+
+func (in *Input) At(i int) Frame {
+	if len(in.frames) == 0 {
+		return Frame{}
+	}
+	if i >= len(in.frames) {
+		f := in.frames[len(in.frames)-1]
+		f.Reset = false
+		return f
+	}
+	return in.frames[i]
+}
+
+func (in *Input) Len() int {
+	return len(in.frames)
+}
+
+var scriptKeys = map[string]byte{
+	"a": core.PadKeyA, "b": core.PadKeyB, "select": core.PadKeySelect, "start": core.PadKeyStart,
+	"up": core.PadKeyUp, "down": core.PadKeyDown, "left": core.PadKeyLeft, "right": core.PadKeyRight,
+}
+
+// ParseScript reads an input script, of lines either
+//
+//	<frame> <pad> <keys>
+//	<frame> reset
+//
+// the first holding from the frame on the keys, joined by "+", of pad 1 or
+// 2, none if "-", and the second resetting before the frame. Frames are
+// counted from 0 and are not to go back. Text after a "#" is ignored.
+func ParseScript(r io.Reader) (*Input, error) {
+	in := &Input{}
+	var cur Frame
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fs := strings.Fields(line)
+		if len(fs) == 0 {
+			continue
+		}
+		i, err := strconv.Atoi(fs[0])
+		if err != nil || i < len(in.frames)-1 || i < 0 {
+			return nil, fmt.Errorf("line %d: invalid frame", ln)
+		}
+		for len(in.frames) <= i {
+			in.frames = append(in.frames, cur)
+		}
+		f := &in.frames[i]
+		switch {
+		case len(fs) == 2 && fs[1] == "reset":
+			f.Reset = true
+		case len(fs) == 3 && (fs[1] == "1" || fs[1] == "2"):
+			var keys byte
+			if fs[2] != "-" {
+				for _, k := range strings.Split(fs[2], "+") {
+					b, ok := scriptKeys[strings.ToLower(k)]
+					if !ok {
+						return nil, fmt.Errorf("line %d: invalid key %s", ln, k)
+					}
+					keys |= b
+				}
+			}
+			f.Pads[fs[1][0]-'1'] = keys
+			cur.Pads = f.Pads
+		default:
+			return nil, fmt.Errorf("line %d: invalid command", ln)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// the keys of an fm2 pad field, in order
+var fm2Keys = [8]byte{
+	core.PadKeyRight, core.PadKeyLeft, core.PadKeyDown, core.PadKeyUp,
+	core.PadKeyStart, core.PadKeySelect, core.PadKeyB, core.PadKeyA,
+}
+
+// ParseFm2 reads a movie in the text fm2 format of fceux, of which only the
+// gamepads and the reset commands are played. The pads are released after
+// the last frame.
+func ParseFm2(r io.Reader) (*Input, error) {
+	in := &Input{}
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "|") {
+			if fs := strings.Fields(line); len(fs) == 2 && fs[0] == "binary" && fs[1] != "0" {
+				return nil, fmt.Errorf("binary fm2 not supported")
+			}
+			continue
+		}
+		fs := strings.Split(line, "|")
+		if len(fs) < 4 {
+			return nil, fmt.Errorf("line %d: invalid input", ln)
+		}
+		var f Frame
+		cmd, err := strconv.Atoi(fs[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid command", ln)
+		}
+		// soft or hard reset
+		f.Reset = cmd&0x03 != 0
+		for p := 0; p < 2; p++ {
+			if pf := fs[2+p]; len(pf) == len(fm2Keys) {
+				for i, k := range fm2Keys {
+					if pf[i] != ' ' && pf[i] != '.' {
+						f.Pads[p] |= k
+					}
+				}
+			}
+		}
+		in.frames = append(in.frames, f)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	in.frames = append(in.frames, Frame{})
+	return in, nil
+}
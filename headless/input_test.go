@@ -0,0 +1,98 @@
+package headless
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ldeng7/go-fc/core"
+)
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		frames []Frame
+		err    bool
+	}{
+		{"keys", "0 1 a+B # jump\n2 2 up\n3 1 -\n", []Frame{
+			{Pads: [2]byte{core.PadKeyA | core.PadKeyB}},
+			{Pads: [2]byte{core.PadKeyA | core.PadKeyB}},
+			{Pads: [2]byte{core.PadKeyA | core.PadKeyB, core.PadKeyUp}},
+			{Pads: [2]byte{0, core.PadKeyUp}},
+		}, false},
+		{"reset", "\n1 reset\n1 1 start\n", []Frame{
+			{},
+			{Pads: [2]byte{core.PadKeyStart}, Reset: true},
+		}, false},
+		{"empty", "# nothing\n", nil, false},
+		{"back", "3 1 a\n1 1 b\n", nil, true},
+		{"bad frame", "x 1 a\n", nil, true},
+		{"bad pad", "0 3 a\n", nil, true},
+		{"bad key", "0 1 a+turbo\n", nil, true},
+		{"bad command", "0 power\n", nil, true},
+	}
+	for _, tt := range tests {
+		in, err := ParseScript(strings.NewReader(tt.script))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if err == nil {
+			checkFrames(t, tt.name, in, tt.frames)
+		}
+	}
+}
+
+func TestParseFm2(t *testing.T) {
+	tests := []struct {
+		name   string
+		movie  string
+		frames []Frame
+		err    bool
+	}{
+		{"pads", "version 3\nbinary 0\n|0|R......A|........||\n|0|.L..T.B.|..D.....||\n", []Frame{
+			{Pads: [2]byte{core.PadKeyRight | core.PadKeyA}},
+			{Pads: [2]byte{core.PadKeyLeft | core.PadKeyStart | core.PadKeyB, core.PadKeyDown}},
+			{},
+		}, false},
+		{"reset", "|1|........|||\n|2|...U....|||\n|4|........|||\n", []Frame{
+			{Reset: true},
+			{Pads: [2]byte{core.PadKeyUp}, Reset: true},
+			{},
+			{},
+		}, false},
+		{"binary", "binary 1\n", nil, true},
+		{"short", "|0|........\n", nil, true},
+		{"bad command", "|x|........|||\n", nil, true},
+	}
+	for _, tt := range tests {
+		in, err := ParseFm2(strings.NewReader(tt.movie))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if err == nil {
+			checkFrames(t, tt.name, in, tt.frames)
+		}
+	}
+}
+
+func checkFrames(t *testing.T, name string, in *Input, frames []Frame) {
+	if in.Len() != len(frames) {
+		t.Errorf("%s: %d frames, want %d", name, in.Len(), len(frames))
+		return
+	}
+	for i, f := range frames {
+		if got := in.At(i); got != f {
+			t.Errorf("%s: frame %d: got %+v, want %+v", name, i, got, f)
+		}
+	}
+	// past the end the pads stay, without the reset
+	if len(frames) != 0 {
+		f := frames[len(frames)-1]
+		f.Reset = false
+		if got := in.At(len(frames) + 5); got != f {
+			t.Errorf("%s: past the end: got %+v, want %+v", name, got, f)
+		}
+	}
+}